@@ -1,19 +1,40 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/apex/go-apex"
+	"github.com/tmaiaroto/discfg/audit"
+	"github.com/tmaiaroto/discfg/auth"
 	"github.com/tmaiaroto/discfg/commands"
 	"github.com/tmaiaroto/discfg/config"
 	"github.com/tmaiaroto/discfg/version"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // To change these settings for DynamoDB, deploy with a different environment variable.
 // apex deploy -s DISCFG_DB_REGION=us-west-1
 var discfgDBRegion = os.Getenv("DISCFG_REGION")
+
+// Storage calls are canceled after this many seconds (the apex context has no deadline of its own). Keep it under
+// the function's timeout so an error can still be returned.
+var discfgTimeout, _ = strconv.Atoi(os.Getenv("DISCFG_TIMEOUT"))
+
+// Requests need an API token allowed access when set, the configuration the tokens are stored in.
+// apex deploy -s DISCFG_TOKEN_CFG=discfg_tokens
+var discfgTokenCfg = os.Getenv("DISCFG_TOKEN_CFG")
+
+// Changes are recorded to this audit log sink (file|dynamodb), none if empty. The dynamodb sink's table can be set too.
+var discfgAuditSink = os.Getenv("DISCFG_AUDIT_SINK")
+var discfgAuditTable = os.Getenv("DISCFG_AUDIT_TABLE")
 var discfgDBTable = os.Getenv("DISCFG_TABLE")
 
+// Keys beginning with any of these (comma separated) prefixes are redacted unless revealed.
+var discfgSensitivePrefixes = os.Getenv("DISCFG_SENSITIVE_PREFIXES")
+
 // The JSON message passd to the Lambda (should include key, value, etc.)
 type message struct {
 	Name string `json:"name"`
@@ -22,6 +43,10 @@ type message struct {
 	Key   string `json:"key"`
 	Value string `json:"value"`
 	Raw   string `json:"raw"`
+	// Comes in as string, "true" reveals sensitive values in the response
+	Reveal string `json:"reveal"`
+	// The Authorization header, an API token (optionally prefixed with "Bearer ")
+	Authorization string `json:"authorization"`
 }
 
 var options = config.Options{StorageInterfaceName: "dynamodb", Version: version.Semantic}
@@ -31,6 +56,10 @@ func main() {
 	if discfgDBRegion == "" {
 		discfgDBRegion = "us-east-1"
 	}
+	options.Auth.Cfg = discfgTokenCfg
+	options.Audit.Sink = discfgAuditSink
+	options.Audit.Table = discfgAuditTable
+	options.Audit.Source = audit.SourceLambda
 
 	apex.HandleFunc(func(event json.RawMessage, ctx *apex.Context) (interface{}, error) {
 		var m message
@@ -40,6 +69,15 @@ func main() {
 		}
 
 		options.Storage.AWS.Region = discfgDBRegion
+		if discfgTimeout > 0 {
+			var cancel context.CancelFunc
+			options.Context, cancel = context.WithTimeout(context.Background(), time.Duration(discfgTimeout)*time.Second)
+			defer cancel()
+		}
+		if discfgSensitivePrefixes != "" {
+			options.SensitivePrefixes = strings.Split(discfgSensitivePrefixes, ",")
+		}
+		options.Reveal = m.Reveal == "true"
 		// Each discfg API can be configured with a default table name.
 		options.CfgName = discfgDBTable
 		// Overwritten by the message passed to the Lambda.
@@ -48,8 +86,14 @@ func main() {
 		}
 		options.Key = m.Key
 
+		token, err := auth.Authorize(options, m.Authorization, auth.Request{Cfg: options.CfgName, Key: options.Key, Access: auth.Write})
+		if err != nil {
+			return config.ResponseObject{Action: "delete", Error: err.Error(), ErrorCode: auth.ErrorCode(err)}, nil
+		}
+		options.Author = auth.Identity(token)
+
 		resp := commands.DeleteKey(options)
 
-		return commands.FormatJSONValue(resp), nil
+		return commands.FormatJSONValue(options, resp), nil
 	})
 }
@@ -1,20 +1,40 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/apex/go-apex"
+	"github.com/tmaiaroto/discfg/auth"
+	"github.com/tmaiaroto/discfg/cache"
 	"github.com/tmaiaroto/discfg/commands"
 	"github.com/tmaiaroto/discfg/config"
 	"github.com/tmaiaroto/discfg/version"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
 // To change these settings for DynamoDB, deploy with a different environment variable.
 // apex deploy -s DISCFG_DB_REGION=us-west-1
 var discfgDBRegion = os.Getenv("DISCFG_REGION")
+
+// Storage calls are canceled after this many seconds (the apex context has no deadline of its own). Keep it under
+// the function's timeout so an error can still be returned.
+var discfgTimeout, _ = strconv.Atoi(os.Getenv("DISCFG_TIMEOUT"))
+
+// Requests need an API token allowed access when set, the configuration the tokens are stored in.
+// apex deploy -s DISCFG_TOKEN_CFG=discfg_tokens
+var discfgTokenCfg = os.Getenv("DISCFG_TOKEN_CFG")
 var discfgDBTable = os.Getenv("DISCFG_TABLE")
 
+// Items are cached (in memory, across invocations of the same Lambda container) for up to this many seconds
+// before the config is checked for changes. Caching is off unless set.
+var discfgCacheMaxAge = os.Getenv("DISCFG_CACHE_MAX_AGE")
+
+// Keys beginning with any of these (comma separated) prefixes are redacted unless revealed.
+var discfgSensitivePrefixes = os.Getenv("DISCFG_SENSITIVE_PREFIXES")
+
 // The JSON message passd to the Lambda (should include key, value, etc.)
 type message struct {
 	Name string `json:"name"`
@@ -23,6 +43,10 @@ type message struct {
 	Key   string `json:"key"`
 	Value string `json:"value"`
 	Raw   string `json:"raw"`
+	// Comes in as string, "true" reveals sensitive values in the response
+	Reveal string `json:"reveal"`
+	// The Authorization header, an API token (optionally prefixed with "Bearer ")
+	Authorization string `json:"authorization"`
 }
 
 var options = config.Options{StorageInterfaceName: "dynamodb", Version: version.Semantic}
@@ -32,6 +56,12 @@ func main() {
 	if discfgDBRegion == "" {
 		discfgDBRegion = "us-east-1"
 	}
+	options.Auth.Cfg = discfgTokenCfg
+	if maxAge, err := strconv.ParseInt(discfgCacheMaxAge, 10, 64); err == nil && maxAge > 0 {
+		if _, err := cache.Register(options.StorageInterfaceName, time.Duration(maxAge)*time.Second); err == nil {
+			options.StorageInterfaceName = cache.RegisteredName(options.StorageInterfaceName)
+		}
+	}
 
 	apex.HandleFunc(func(event json.RawMessage, ctx *apex.Context) (interface{}, error) {
 		var m message
@@ -41,6 +71,15 @@ func main() {
 		}
 
 		options.Storage.AWS.Region = discfgDBRegion
+		if discfgTimeout > 0 {
+			var cancel context.CancelFunc
+			options.Context, cancel = context.WithTimeout(context.Background(), time.Duration(discfgTimeout)*time.Second)
+			defer cancel()
+		}
+		if discfgSensitivePrefixes != "" {
+			options.SensitivePrefixes = strings.Split(discfgSensitivePrefixes, ",")
+		}
+		options.Reveal = m.Reveal == "true"
 		// Each discfg API can be configured with a default table name.
 		options.CfgName = discfgDBTable
 		// Overwritten by the message passed to the Lambda.
@@ -49,20 +88,19 @@ func main() {
 		}
 		options.Key = m.Key
 
-		resp := commands.GetKey(options)
-
-		// Format the expiration time (if applicable). This prevents output like "0001-01-01T00:00:00Z" when empty
-		// and allows for the time.RFC3339Nano format to be used whereas time.Time normally marshals to a different format.
-		if resp.Item.TTL > 0 {
-			resp.Item.OutputExpiration = resp.Item.Expiration.Format(time.RFC3339Nano)
+		_, err := auth.Authorize(options, m.Authorization, auth.Request{Cfg: options.CfgName, Key: options.Key, Access: auth.Read})
+		if err != nil {
+			return config.ResponseObject{Action: "get", Error: err.Error(), ErrorCode: auth.ErrorCode(err)}, nil
 		}
 
+		resp := commands.GetKey(options)
+
 		// Just return the raw value for the given key if raw was passed as true
 		// if m.Raw == "true" {
 		// 	return resp.Item.Value, nil
 		// }
 
-		r := commands.FormatJSONValue(resp)
+		r := commands.FormatJSONValue(options, resp)
 		return r, nil
 	})
 }
@@ -1,21 +1,35 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/apex/go-apex"
+	"github.com/tmaiaroto/discfg/auth"
 	"github.com/tmaiaroto/discfg/commands"
 	"github.com/tmaiaroto/discfg/config"
 	"github.com/tmaiaroto/discfg/version"
 	"os"
+	"strconv"
+	"time"
 )
 
 // To change these settings for DynamoDB, deploy with a different environment variable.
 // apex deploy -s DISCFG_DB_REGION=us-west-1
 var discfgDBRegion = os.Getenv("DISCFG_REGION")
 
+// Storage calls are canceled after this many seconds (the apex context has no deadline of its own). Keep it under
+// the function's timeout so an error can still be returned.
+var discfgTimeout, _ = strconv.Atoi(os.Getenv("DISCFG_TIMEOUT"))
+
+// Requests need an API token allowed access when set, the configuration the tokens are stored in.
+// apex deploy -s DISCFG_TOKEN_CFG=discfg_tokens
+var discfgTokenCfg = os.Getenv("DISCFG_TOKEN_CFG")
+
 // The JSON message passed to the Lambda
 type message struct {
 	Name string `json:"name"`
+	// The Authorization header, an API token (optionally prefixed with "Bearer ")
+	Authorization string `json:"authorization"`
 }
 
 var options = config.Options{StorageInterfaceName: "dynamodb", Version: version.Semantic}
@@ -25,6 +39,7 @@ func main() {
 	if discfgDBRegion == "" {
 		discfgDBRegion = "us-east-1"
 	}
+	options.Auth.Cfg = discfgTokenCfg
 
 	apex.HandleFunc(func(event json.RawMessage, ctx *apex.Context) (interface{}, error) {
 		var m message
@@ -34,13 +49,23 @@ func main() {
 		}
 
 		options.Storage.AWS.Region = discfgDBRegion
+		if discfgTimeout > 0 {
+			var cancel context.CancelFunc
+			options.Context, cancel = context.WithTimeout(context.Background(), time.Duration(discfgTimeout)*time.Second)
+			defer cancel()
+		}
 
 		if m.Name != "" {
 			options.CfgName = m.Name
 		}
 
+		_, err := auth.Authorize(options, m.Authorization, auth.Request{Cfg: options.CfgName, Access: auth.Read})
+		if err != nil {
+			return config.ResponseObject{Action: "info", Error: err.Error(), ErrorCode: auth.ErrorCode(err)}, nil
+		}
+
 		resp := commands.Info(options)
 
-		return commands.FormatJSONValue(resp), nil
+		return commands.FormatJSONValue(options, resp), nil
 	})
 }
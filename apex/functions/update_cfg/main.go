@@ -1,23 +1,42 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/apex/go-apex"
+	"github.com/tmaiaroto/discfg/audit"
+	"github.com/tmaiaroto/discfg/auth"
 	"github.com/tmaiaroto/discfg/commands"
 	"github.com/tmaiaroto/discfg/config"
 	"github.com/tmaiaroto/discfg/version"
 	"os"
+	"strconv"
+	"time"
 )
 
 // To change these settings for DynamoDB, deploy with a different environment variable.
 // apex deploy -s DISCFG_DB_REGION=us-west-1
 var discfgDBRegion = os.Getenv("DISCFG_REGION")
 
+// Storage calls are canceled after this many seconds (the apex context has no deadline of its own). Keep it under
+// the function's timeout so an error can still be returned.
+var discfgTimeout, _ = strconv.Atoi(os.Getenv("DISCFG_TIMEOUT"))
+
+// Requests need an API token allowed access when set, the configuration the tokens are stored in.
+// apex deploy -s DISCFG_TOKEN_CFG=discfg_tokens
+var discfgTokenCfg = os.Getenv("DISCFG_TOKEN_CFG")
+
+// Changes are recorded to this audit log sink (file|dynamodb), none if empty. The dynamodb sink's table can be set too.
+var discfgAuditSink = os.Getenv("DISCFG_AUDIT_SINK")
+var discfgAuditTable = os.Getenv("DISCFG_AUDIT_TABLE")
+
 // The JSON message passed to the Lambda
 type message struct {
 	Name string `json:"name"`
 	// ...is actually the POST body (for now) but didn't want to call it "Value" in this function
 	Settings string `json:"settings"`
+	// The Authorization header, an API token (optionally prefixed with "Bearer ")
+	Authorization string `json:"authorization"`
 }
 
 var options = config.Options{StorageInterfaceName: "dynamodb", Version: version.Semantic}
@@ -27,6 +46,10 @@ func main() {
 	if discfgDBRegion == "" {
 		discfgDBRegion = "us-east-1"
 	}
+	options.Auth.Cfg = discfgTokenCfg
+	options.Audit.Sink = discfgAuditSink
+	options.Audit.Table = discfgAuditTable
+	options.Audit.Source = audit.SourceLambda
 
 	apex.HandleFunc(func(event json.RawMessage, ctx *apex.Context) (interface{}, error) {
 		var m message
@@ -36,19 +59,29 @@ func main() {
 		}
 
 		options.Storage.AWS.Region = discfgDBRegion
+		if discfgTimeout > 0 {
+			var cancel context.CancelFunc
+			options.Context, cancel = context.WithTimeout(context.Background(), time.Duration(discfgTimeout)*time.Second)
+			defer cancel()
+		}
 
 		if m.Name != "" {
 			options.CfgName = m.Name
 		}
 
-		var settings map[string]interface{}
-		err := json.Unmarshal([]byte(m.Settings), &settings)
+		token, err := auth.Authorize(options, m.Authorization, auth.Request{Cfg: options.CfgName, Access: auth.Admin})
 		if err != nil {
+			return config.ResponseObject{Action: "update cfg", Error: err.Error(), ErrorCode: auth.ErrorCode(err)}, nil
+		}
+		options.Author = auth.Identity(token)
+
+		var settings map[string]interface{}
+		if err := json.Unmarshal([]byte(m.Settings), &settings); err != nil {
 			return nil, err
 		}
 
 		resp := commands.UpdateCfg(options, settings)
 
-		return commands.FormatJSONValue(resp), nil
+		return commands.FormatJSONValue(options, resp), nil
 	})
 }
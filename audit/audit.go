@@ -0,0 +1,142 @@
+// Package audit records changes made to configurations (who changed what, and when) to a Sink, such as a local
+// JSONL file or a DynamoDB table. Events are chained per config by hash, so an event changed or removed on its own
+// is caught. The hashes aren't keyed though, so anyone who can write to the sink can rewrite the chain from that
+// event on; the log isn't tamper-proof, access to the sink still needs to be limited.
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/tmaiaroto/discfg/config"
+	"strconv"
+	"time"
+)
+
+// Sources of changes
+const (
+	SourceCLI    = "cli"
+	SourceHTTP   = "http"
+	SourceLambda = "lambda"
+)
+
+// Sink stores audit events. Much like the storage Shipper interface, anyone importing discfg can register their own.
+type Sink interface {
+	// Append adds an event after the config's last one, chaining it (see Chain) and returning it as stored
+	Append(config.Options, config.AuditEvent) (config.AuditEvent, error)
+	// Query returns a config's events matching the filter, oldest first
+	Query(config.Options, Filter) ([]config.AuditEvent, error)
+}
+
+// Filter narrows down the events queried. Empty fields match everything.
+type Filter struct {
+	Key  string
+	From time.Time
+	To   time.Time
+}
+
+// Error message constants, reduce repetition.
+const (
+	errMsgInvalidSink = "Invalid audit sink."
+)
+
+// A map of all Sinks available for use (with some defaults).
+var sinks = map[string]Sink{
+	"file":     FileSink{},
+	"dynamodb": DynamoDBSink{},
+}
+
+// RegisterSink allows anyone importing discfg into their own project to register new sinks or overwrite the defaults.
+func RegisterSink(name string, sink Sink) {
+	sinks[name] = sink
+}
+
+// ListSinks returns the list of available sinks.
+func ListSinks() map[string]Sink {
+	return sinks
+}
+
+// Record appends an event for the config in the options to the audit sink set in the options, filling in the time
+// and source. Without a sink, nothing is recorded.
+func Record(opts config.Options, e config.AuditEvent) error {
+	if opts.Audit.Sink == "" {
+		return nil
+	}
+	s, ok := sinks[opts.Audit.Sink]
+	if !ok {
+		return errors.New(errMsgInvalidSink)
+	}
+	e.Cfg = opts.CfgName
+	e.Time = time.Now().UnixNano()
+	e.Source = opts.Audit.Source
+	_, err := s.Append(opts, e)
+	return err
+}
+
+// Query returns the events for the config in the options from the audit sink set in the options, oldest first
+func Query(opts config.Options, f Filter) ([]config.AuditEvent, error) {
+	s, ok := sinks[opts.Audit.Sink]
+	if !ok {
+		return nil, errors.New(errMsgInvalidSink)
+	}
+	return s.Query(opts, f)
+}
+
+// Chain numbers an event and links it to the event before it (nil for a config's first event), setting its hash
+func Chain(prev *config.AuditEvent, e config.AuditEvent) config.AuditEvent {
+	e.Seq, e.PrevHash = 1, ""
+	if prev != nil {
+		e.Seq, e.PrevHash = prev.Seq+1, prev.Hash
+	}
+	e.Hash = EventHash(e)
+	return e
+}
+
+// EventHash returns the hash of an event, everything but the hash itself
+func EventHash(e config.AuditEvent) string {
+	e.Hash = ""
+	b, _ := json.Marshal(e)
+	return Hash(b)
+}
+
+// Hash returns the SHA-256 of a value (hex encoded), empty for no value
+func Hash(value []byte) string {
+	if value == nil {
+		return ""
+	}
+	sum := sha256.Sum256(value)
+	return hex.EncodeToString(sum[:])
+}
+
+// Verify checks that events, oldest first, haven't been changed and that each one follows the one before it.
+// Filtered events have gaps, so only when complete (every event for a config) are missing events an error.
+func Verify(events []config.AuditEvent, complete bool) error {
+	for i, e := range events {
+		if EventHash(e) != e.Hash {
+			return errors.New("Audit event " + strconv.FormatInt(e.Seq, 10) + " has been changed")
+		}
+		follows := (i == 0 && e.Seq == 1) || (i > 0 && e.Seq == events[i-1].Seq+1)
+		if complete && !follows {
+			return errors.New("Audit events before " + strconv.FormatInt(e.Seq, 10) + " are missing")
+		}
+		if follows && i > 0 && e.PrevHash != events[i-1].Hash {
+			return errors.New("Audit event " + strconv.FormatInt(e.Seq, 10) + " doesn't follow the one before it")
+		}
+	}
+	return nil
+}
+
+// matches returns whether or not an event matches the filter
+func (f Filter) matches(e config.AuditEvent) bool {
+	if f.Key != "" && e.Key != f.Key {
+		return false
+	}
+	if !f.From.IsZero() && e.Time < f.From.UnixNano() {
+		return false
+	}
+	if !f.To.IsZero() && e.Time > f.To.UnixNano() {
+		return false
+	}
+	return true
+}
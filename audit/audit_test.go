@@ -0,0 +1,96 @@
+package audit
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/tmaiaroto/discfg/config"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestChain(t *testing.T) {
+	Convey("Chained events should be numbered and linked to the one before", t, func() {
+		first := Chain(nil, config.AuditEvent{Cfg: "mockcfg", Action: "set", Key: "a"})
+		So(first.Seq, ShouldEqual, 1)
+		So(first.PrevHash, ShouldEqual, "")
+		So(first.Hash, ShouldEqual, EventHash(first))
+
+		second := Chain(&first, config.AuditEvent{Cfg: "mockcfg", Action: "delete", Key: "a"})
+		So(second.Seq, ShouldEqual, 2)
+		So(second.PrevHash, ShouldEqual, first.Hash)
+		So(Verify([]config.AuditEvent{first, second}, true), ShouldBeNil)
+
+		Convey("A changed event should be caught", func() {
+			changed := second
+			changed.Key = "b"
+			So(Verify([]config.AuditEvent{first, changed}, true), ShouldNotBeNil)
+		})
+
+		Convey("A missing event should be caught unless the events were filtered", func() {
+			third := Chain(&second, config.AuditEvent{Cfg: "mockcfg", Action: "set", Key: "b"})
+			So(Verify([]config.AuditEvent{first, third}, true), ShouldNotBeNil)
+			So(Verify([]config.AuditEvent{first, third}, false), ShouldBeNil)
+			So(Verify([]config.AuditEvent{second, third}, false), ShouldBeNil)
+		})
+
+		Convey("A rewritten chain should be caught", func() {
+			forged := Chain(&first, config.AuditEvent{Cfg: "mockcfg", Action: "set", Key: "a"})
+			forged.PrevHash = "abc"
+			forged.Hash = EventHash(forged)
+			So(Verify([]config.AuditEvent{first, forged}, false), ShouldNotBeNil)
+		})
+	})
+
+	Convey("Hashing no value should be empty", t, func() {
+		So(Hash(nil), ShouldEqual, "")
+		So(Hash([]byte("")), ShouldNotEqual, "")
+	})
+}
+
+func TestFileSink(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "discfg-audit")
+	defer os.RemoveAll(dir)
+	opts := config.Options{CfgName: "mockcfg"}
+	opts.Audit.Sink = "file"
+	opts.Audit.File = filepath.Join(dir, "audit.jsonl")
+	opts.Audit.Source = SourceCLI
+
+	Convey("Without a sink nothing should be recorded", t, func() {
+		none := opts
+		none.Audit.Sink = ""
+		So(Record(none, config.AuditEvent{Action: "set", Key: "a"}), ShouldBeNil)
+		_, err := os.Stat(opts.Audit.File)
+		So(os.IsNotExist(err), ShouldBeTrue)
+	})
+
+	Convey("Events should be recorded per config and queried back in order", t, func() {
+		So(Record(opts, config.AuditEvent{Action: "set", Key: "a", NewVersion: 1}), ShouldBeNil)
+		other := opts
+		other.CfgName = "othercfg"
+		So(Record(other, config.AuditEvent{Action: "set", Key: "a", NewVersion: 1}), ShouldBeNil)
+		So(Record(opts, config.AuditEvent{Action: "set", Key: "b", NewVersion: 1}), ShouldBeNil)
+
+		events, err := Query(opts, Filter{})
+		So(err, ShouldBeNil)
+		So(len(events), ShouldEqual, 2)
+		So(events[0].Cfg, ShouldEqual, "mockcfg")
+		So(events[0].Source, ShouldEqual, SourceCLI)
+		So(events[1].Seq, ShouldEqual, 2)
+		So(Verify(events, true), ShouldBeNil)
+
+		events, _ = Query(opts, Filter{Key: "b"})
+		So(len(events), ShouldEqual, 1)
+		events, _ = Query(opts, Filter{From: time.Now().Add(time.Hour)})
+		So(len(events), ShouldEqual, 0)
+	})
+
+	Convey("An unknown sink should be an error", t, func() {
+		bad := opts
+		bad.Audit.Sink = "nope"
+		So(Record(bad, config.AuditEvent{}), ShouldNotBeNil)
+		_, err := Query(bad, Filter{})
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,166 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/tmaiaroto/discfg/config"
+	ddb "github.com/tmaiaroto/discfg/storage/dynamodb"
+	"strconv"
+	"strings"
+)
+
+// DefaultTable is used by the DynamoDB sink when no table was given
+const DefaultTable = "discfg_audit"
+
+// appendAttempts is how many times an append is tried when others append to the same config at the same time
+const appendAttempts = 5
+
+// DynamoDBSink stores events in a DynamoDB table keyed by config name and sequence number, created on first use.
+// Each event is put on the condition its sequence number is free, so concurrent changes can't fork a chain.
+type DynamoDBSink struct {
+}
+
+// Append adds an event to the table after the config's last event
+func (s DynamoDBSink) Append(opts config.Options, e config.AuditEvent) (config.AuditEvent, error) {
+	ctx := opts.ContextOrBackground()
+	svc, err := ddb.Svc(opts)
+	if err != nil {
+		return e, err
+	}
+	for attempt := 0; attempt < appendAttempts; attempt++ {
+		var prev *config.AuditEvent
+		last, err := s.query(ctx, svc, opts, Filter{}, true)
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeResourceNotFoundException {
+			err = createTable(ctx, svc, table(opts))
+		}
+		if err != nil {
+			return e, err
+		}
+		if len(last) > 0 {
+			prev = &last[0]
+		}
+		chained := Chain(prev, e)
+
+		item, err := dynamodbattribute.MarshalMap(chained)
+		if err != nil {
+			return e, err
+		}
+		_, err = svc.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+			TableName:                aws.String(table(opts)),
+			Item:                     item,
+			ConditionExpression:      aws.String("attribute_not_exists(#s)"),
+			ExpressionAttributeNames: map[string]*string{"#s": aws.String("seq")},
+		})
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			continue
+		}
+		return chained, err
+	}
+	return e, errors.New("Couldn't append the audit event after " + strconv.Itoa(appendAttempts) + " attempts, the config is changing too often")
+}
+
+// Query returns the config's events in the table matching the filter
+func (s DynamoDBSink) Query(opts config.Options, f Filter) ([]config.AuditEvent, error) {
+	svc, err := ddb.Svc(opts)
+	if err != nil {
+		return nil, err
+	}
+	events, err := s.query(opts.ContextOrBackground(), svc, opts, f, false)
+	// No table means nothing has been audited yet
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeResourceNotFoundException {
+		return []config.AuditEvent{}, nil
+	}
+	return events, err
+}
+
+// query returns the config's events matching the filter, oldest first, or only the latest event
+func (s DynamoDBSink) query(ctx context.Context, svc *dynamodb.DynamoDB, opts config.Options, f Filter, latest bool) ([]config.AuditEvent, error) {
+	events := []config.AuditEvent{}
+	params := &dynamodb.QueryInput{
+		TableName:                 aws.String(table(opts)),
+		KeyConditionExpression:    aws.String("#c = :cfg"),
+		ExpressionAttributeNames:  map[string]*string{"#c": aws.String("cfg")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":cfg": {S: aws.String(opts.CfgName)}},
+		ConsistentRead:            aws.Bool(true),
+	}
+	if latest {
+		params.ScanIndexForward = aws.Bool(false)
+		params.Limit = aws.Int64(1)
+		resp, err := svc.QueryWithContext(ctx, params)
+		if err == nil {
+			err = dynamodbattribute.UnmarshalListOfMaps(resp.Items, &events)
+		}
+		return events, err
+	}
+
+	// Filtered by DynamoDB so fewer events come back, though the whole config's events are still read
+	filters := []string{}
+	if f.Key != "" {
+		params.ExpressionAttributeNames["#k"] = aws.String("key")
+		params.ExpressionAttributeValues[":key"] = &dynamodb.AttributeValue{S: aws.String(f.Key)}
+		filters = append(filters, "#k = :key")
+	}
+	if !f.From.IsZero() {
+		params.ExpressionAttributeNames["#t"] = aws.String("time")
+		params.ExpressionAttributeValues[":from"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(f.From.UnixNano(), 10))}
+		filters = append(filters, "#t >= :from")
+	}
+	if !f.To.IsZero() {
+		params.ExpressionAttributeNames["#t"] = aws.String("time")
+		params.ExpressionAttributeValues[":to"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(f.To.UnixNano(), 10))}
+		filters = append(filters, "#t <= :to")
+	}
+	if len(filters) > 0 {
+		params.FilterExpression = aws.String(strings.Join(filters, " AND "))
+	}
+
+	var unmarshalErr error
+	err := svc.QueryPagesWithContext(ctx, params, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		pageEvents := []config.AuditEvent{}
+		if unmarshalErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &pageEvents); unmarshalErr != nil {
+			return false
+		}
+		events = append(events, pageEvents...)
+		return true
+	})
+	if err == nil {
+		err = unmarshalErr
+	}
+	return events, err
+}
+
+// createTable creates the audit table (with on-demand billing, audit writes come in bursts) and waits for it
+func createTable(ctx context.Context, svc *dynamodb.DynamoDB, name string) error {
+	_, err := svc.CreateTableWithContext(ctx, &dynamodb.CreateTableInput{
+		TableName: aws.String(name),
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{
+			{AttributeName: aws.String("cfg"), AttributeType: aws.String("S")},
+			{AttributeName: aws.String("seq"), AttributeType: aws.String("N")},
+		},
+		KeySchema: []*dynamodb.KeySchemaElement{
+			{AttributeName: aws.String("cfg"), KeyType: aws.String("HASH")},
+			{AttributeName: aws.String("seq"), KeyType: aws.String("RANGE")},
+		},
+		BillingMode: aws.String(dynamodb.BillingModePayPerRequest),
+	})
+	// Someone else may have just created it
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeResourceInUseException {
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+	return svc.WaitUntilTableExistsWithContext(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(name)})
+}
+
+// table returns the audit table from the options, or the default
+func table(opts config.Options) string {
+	if opts.Audit.Table != "" {
+		return opts.Audit.Table
+	}
+	return DefaultTable
+}
@@ -0,0 +1,92 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/tmaiaroto/discfg/config"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// DefaultFile is used by the file sink when no file was given. It's relative to the user's home directory.
+const DefaultFile = ".discfg-audit.jsonl"
+
+// fileLock serializes appends, so events are chained in order. Other processes appending to the same file at the
+// same time could still fork a chain (which Verify would catch), use the DynamoDB sink for shared audit logs.
+var fileLock sync.Mutex
+
+// FileSink appends events to a local file, one JSON object per line
+type FileSink struct {
+}
+
+// Append adds an event to the file after the config's last event
+func (s FileSink) Append(opts config.Options, e config.AuditEvent) (config.AuditEvent, error) {
+	fileLock.Lock()
+	defer fileLock.Unlock()
+
+	var prev *config.AuditEvent
+	events, err := s.read(opts, Filter{})
+	if err != nil {
+		return e, err
+	}
+	if len(events) > 0 {
+		prev = &events[len(events)-1]
+	}
+	e = Chain(prev, e)
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		return e, err
+	}
+	f, err := os.OpenFile(filePath(opts), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return e, err
+	}
+	if _, err = f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return e, err
+	}
+	return e, f.Close()
+}
+
+// Query returns the config's events in the file matching the filter
+func (s FileSink) Query(opts config.Options, f Filter) ([]config.AuditEvent, error) {
+	fileLock.Lock()
+	defer fileLock.Unlock()
+	return s.read(opts, f)
+}
+
+// read returns the config's events in the file matching the filter, none when there's no file yet
+func (s FileSink) read(opts config.Options, f Filter) ([]config.AuditEvent, error) {
+	events := []config.AuditEvent{}
+	file, err := os.Open(filePath(opts))
+	if os.IsNotExist(err) {
+		return events, nil
+	}
+	if err != nil {
+		return events, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e config.AuditEvent
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return events, err
+		}
+		if e.Cfg == opts.CfgName && f.matches(e) {
+			events = append(events, e)
+		}
+	}
+	return events, scanner.Err()
+}
+
+// filePath returns the audit file from the options, or the default
+func filePath(opts config.Options) string {
+	if opts.Audit.File != "" {
+		return opts.Audit.File
+	}
+	return filepath.Join(os.Getenv("HOME"), DefaultFile)
+}
@@ -0,0 +1,276 @@
+// Package auth provides API tokens for the HTTP APIs (the server and Lambda functions). Tokens are granted read,
+// write or admin access to configs and keys by policies. They're stored hashed in their own configuration, under a
+// reserved namespace, which can't be accessed through the APIs at all.
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/tmaiaroto/discfg/config"
+	"github.com/tmaiaroto/discfg/storage"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// KeyPrefix defines the reserved namespace for tokens, the rest of a token's key is its hash
+const KeyPrefix = "/_token/"
+
+// DefaultCfg is the configuration tokens are stored in when creating them from the CLI
+const DefaultCfg = "discfg_tokens"
+
+// Access levels, each one includes the ones before it
+const (
+	Read  = "read"
+	Write = "write"
+	Admin = "admin"
+)
+
+var levels = map[string]int{Read: 1, Write: 2, Admin: 3}
+
+// ErrUnauthorized is returned for a missing, invalid or revoked token
+var ErrUnauthorized = errors.New("Invalid or revoked API token")
+
+// ErrForbidden is returned when a token's policies don't allow a request
+var ErrForbidden = errors.New("API token is not allowed access")
+
+// ErrMissingCfg is returned when no token configuration was set
+var ErrMissingCfg = errors.New("Token configuration is required")
+
+// ErrMissingName is returned when no token name was given
+var ErrMissingName = errors.New("Token name is required")
+
+// ErrExists is returned when creating a token with a name already in use
+var ErrExists = errors.New("A token with that name already exists")
+
+// ErrNotFound is returned when revoking a token that doesn't exist
+var ErrNotFound = errors.New("Token not found")
+
+// Request is what a token needs access to. Requests for a config itself (creating one for example) have no key.
+type Request struct {
+	Cfg    string
+	Key    string
+	Access string
+}
+
+// Authorize checks a token (optionally prefixed with "Bearer ") against the tokens stored in the token configuration
+// set in the options, returning the token when one of its policies allows the request. Without a token
+// configuration every request is allowed.
+func Authorize(opts config.Options, token string, r Request) (config.Token, error) {
+	var t config.Token
+	if opts.Auth.Cfg == "" {
+		return t, nil
+	}
+	// Tokens would otherwise be able to grant themselves anything
+	if r.Cfg == opts.Auth.Cfg {
+		return t, ErrForbidden
+	}
+	token = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(token), "Bearer "))
+	if token == "" {
+		return t, ErrUnauthorized
+	}
+	tokenOpts := tokenOptions(opts)
+	tokenOpts.Key = KeyPrefix + Hash(token)
+	item, err := storage.Get(tokenOpts)
+	if err != nil {
+		return t, err
+	}
+	value, _ := item.Value.([]byte)
+	if len(value) == 0 {
+		return t, ErrUnauthorized
+	}
+	if err := json.Unmarshal(value, &t); err != nil {
+		return t, err
+	}
+	for _, p := range t.Policies {
+		if Allows(p, r) {
+			return t, nil
+		}
+	}
+	return t, ErrForbidden
+}
+
+// Allows returns whether or not a policy allows a request
+func Allows(p config.Policy, r Request) bool {
+	if levels[p.Access] < levels[r.Access] || levels[r.Access] == 0 {
+		return false
+	}
+	if ok, _ := path.Match(p.Cfg, r.Cfg); !ok {
+		return false
+	}
+	// Policies limited to some keys don't extend to the config itself
+	if r.Key == "" {
+		return p.Key == ""
+	}
+	// Prefixes match whole path segments, so "services" doesn't extend to "services-secrets"
+	key, prefix := strings.TrimPrefix(r.Key, "/"), strings.TrimPrefix(p.Key, "/")
+	if prefix == "" || strings.HasSuffix(prefix, "/") {
+		return strings.HasPrefix(key, prefix)
+	}
+	return key == prefix || strings.HasPrefix(key, prefix+"/")
+}
+
+// Create creates a token with the policies in the token configuration set in the options. The returned token's
+// Secret is the token itself, it can't be recovered later.
+func Create(opts config.Options, name string, policies []config.Policy) (config.Token, error) {
+	t := config.Token{Name: name, Policies: policies, Created: time.Now().UnixNano()}
+	if opts.Auth.Cfg == "" {
+		return t, ErrMissingCfg
+	}
+	if name == "" {
+		return t, ErrMissingName
+	}
+	if _, err := find(opts, name); err != ErrNotFound {
+		if err == nil {
+			err = ErrExists
+		}
+		return t, err
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return t, err
+	}
+	secret := "discfg_" + hex.EncodeToString(b)
+
+	value, err := json.Marshal(t)
+	if err != nil {
+		return t, err
+	}
+	tokenOpts := tokenOptions(opts)
+	tokenOpts.Key = KeyPrefix + Hash(secret)
+	tokenOpts.Value = value
+	tokenOpts.ValueType = config.ValueTypeJSON
+	if _, err := storage.Update(tokenOpts); err != nil {
+		return t, err
+	}
+	t.Hash, t.Secret = Hash(secret), secret
+	return t, nil
+}
+
+// Revoke deletes a token by name from the token configuration set in the options, returning the revoked token
+func Revoke(opts config.Options, name string) (config.Token, error) {
+	if name == "" {
+		return config.Token{}, ErrMissingName
+	}
+	t, err := find(opts, name)
+	if err != nil {
+		return t, err
+	}
+	tokenOpts := tokenOptions(opts)
+	tokenOpts.Key = KeyPrefix + t.Hash
+	_, err = storage.Delete(tokenOpts)
+	return t, err
+}
+
+// List returns the tokens in the token configuration set in the options, by name
+func List(opts config.Options) ([]config.Token, error) {
+	tokens := []config.Token{}
+	if opts.Auth.Cfg == "" {
+		return tokens, ErrMissingCfg
+	}
+	tokenOpts := tokenOptions(opts)
+	tokenOpts.Key = KeyPrefix
+	items, err := storage.List(tokenOpts)
+	if err != nil {
+		return tokens, err
+	}
+	for _, item := range items {
+		value, _ := item.Value.([]byte)
+		if !strings.HasPrefix(item.Key, KeyPrefix) || len(value) == 0 {
+			continue
+		}
+		var t config.Token
+		if err := json.Unmarshal(value, &t); err != nil {
+			return tokens, err
+		}
+		t.Hash = strings.TrimPrefix(item.Key, KeyPrefix)
+		tokens = append(tokens, t)
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].Name < tokens[j].Name
+	})
+	return tokens, nil
+}
+
+// ParsePolicy parses a policy in the form access:cfg[:key], ie. "write:prod-*:services/" or "read:*"
+func ParsePolicy(s string) (config.Policy, error) {
+	parts := strings.SplitN(s, ":", 3)
+	p := config.Policy{Access: parts[0]}
+	if len(parts) > 1 {
+		p.Cfg = parts[1]
+	}
+	if len(parts) > 2 {
+		p.Key = parts[2]
+	}
+	if levels[p.Access] == 0 {
+		return p, errors.New("Invalid policy access " + p.Access + ", must be read, write or admin")
+	}
+	if _, err := path.Match(p.Cfg, ""); err != nil || p.Cfg == "" {
+		return p, errors.New("Invalid policy config pattern in " + s)
+	}
+	return p, nil
+}
+
+// Identity returns who made a request with a token, for key metadata and the audit log (empty for no token)
+func Identity(t config.Token) string {
+	if t.Name == "" {
+		return ""
+	}
+	return "token:" + t.Name
+}
+
+// ErrorCode returns the status code for an error from Authorize, 0 for other errors
+func ErrorCode(err error) int {
+	switch err {
+	case ErrUnauthorized:
+		return config.StatusUnauthorized
+	case ErrForbidden:
+		return config.StatusForbidden
+	}
+	return 0
+}
+
+// Hash returns the SHA-256 of a token (hex encoded). Tokens are random, so there's no need for a slow hash.
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// find returns a token by name, ErrNotFound if there isn't one
+func find(opts config.Options, name string) (config.Token, error) {
+	tokens, err := List(opts)
+	if err != nil {
+		return config.Token{}, err
+	}
+	for _, t := range tokens {
+		if t.Name == name {
+			return t, nil
+		}
+	}
+	return config.Token{}, ErrNotFound
+}
+
+// tokenOptions returns options for working with the token configuration's keys
+func tokenOptions(opts config.Options) config.Options {
+	opts.CfgName = opts.Auth.Cfg
+	opts.Key = ""
+	opts.TTL = 0
+	opts.Value = nil
+	opts.ValueType = ""
+	opts.Encrypt = false
+	opts.Decrypt = false
+	opts.Sensitive = nil
+	opts.Description = ""
+	opts.Tags = nil
+	opts.ConditionalValue = ""
+	opts.ConditionalVersion = 0
+	opts.ConditionalUnset = false
+	opts.ConditionalNotExists = false
+	opts.ConditionalExpired = false
+	opts.Unversioned = false
+	return opts
+}
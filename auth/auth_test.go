@@ -0,0 +1,117 @@
+package auth
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/tmaiaroto/discfg/config"
+	"github.com/tmaiaroto/discfg/storage"
+	"github.com/tmaiaroto/discfg/storage/mockdb"
+	"testing"
+)
+
+func TestTokens(t *testing.T) {
+	storage.RegisterShipper("mock", mockdb.MockShipper{})
+	opts := config.Options{StorageInterfaceName: "mock", Version: "0.0.0"}
+	opts.Auth.Cfg = "mocktokens"
+	storage.CreateConfig(config.Options{StorageInterfaceName: "mock", CfgName: "mocktokens"}, nil)
+	defer storage.DeleteConfig(config.Options{StorageInterfaceName: "mock", CfgName: "mocktokens"})
+
+	var tok config.Token
+	Convey("Tokens should be created with a secret that's only stored hashed", t, func() {
+		var err error
+		tok, err = Create(opts, "ci", []config.Policy{{Cfg: "prod-*", Key: "services/", Access: Write}, {Cfg: "*", Access: Read}})
+		So(err, ShouldBeNil)
+		So(tok.Secret, ShouldStartWith, "discfg_")
+		So(tok.Hash, ShouldEqual, Hash(tok.Secret))
+		stored := mockdb.MockCfg["mocktokens"][KeyPrefix+tok.Hash]
+		So(string(stored.Value.([]byte)), ShouldNotContainSubstring, tok.Secret)
+
+		_, err = Create(opts, "ci", []config.Policy{{Cfg: "*", Access: Read}})
+		So(err, ShouldEqual, ErrExists)
+
+		tokens, err := List(opts)
+		So(err, ShouldBeNil)
+		So(len(tokens), ShouldEqual, 1)
+		So(tokens[0].Name, ShouldEqual, "ci")
+		So(tokens[0].Secret, ShouldEqual, "")
+	})
+
+	Convey("Requests should be allowed by the token's policies", t, func() {
+		found, err := Authorize(opts, "Bearer "+tok.Secret, Request{Cfg: "prod-api", Key: "services/api/port", Access: Write})
+		So(err, ShouldBeNil)
+		So(Identity(found), ShouldEqual, "token:ci")
+		_, err = Authorize(opts, tok.Secret, Request{Cfg: "dev", Key: "anything", Access: Read})
+		So(err, ShouldBeNil)
+
+		_, err = Authorize(opts, tok.Secret, Request{Cfg: "prod-api", Key: "flags/beta", Access: Write})
+		So(err, ShouldEqual, ErrForbidden)
+		_, err = Authorize(opts, tok.Secret, Request{Cfg: "prod-api", Access: Admin})
+		So(err, ShouldEqual, ErrForbidden)
+		_, err = Authorize(opts, tok.Secret, Request{Cfg: "mocktokens", Key: KeyPrefix, Access: Read})
+		So(err, ShouldEqual, ErrForbidden)
+		_, err = Authorize(opts, "discfg_wrong", Request{Cfg: "dev", Access: Read})
+		So(err, ShouldEqual, ErrUnauthorized)
+		_, err = Authorize(opts, "", Request{Cfg: "dev", Access: Read})
+		So(ErrorCode(err), ShouldEqual, config.StatusUnauthorized)
+	})
+
+	Convey("Revoked tokens should no longer be allowed", t, func() {
+		revoked, err := Revoke(opts, "ci")
+		So(err, ShouldBeNil)
+		So(revoked.Name, ShouldEqual, "ci")
+		_, err = Authorize(opts, tok.Secret, Request{Cfg: "dev", Access: Read})
+		So(err, ShouldEqual, ErrUnauthorized)
+		_, err = Revoke(opts, "ci")
+		So(err, ShouldEqual, ErrNotFound)
+	})
+
+	Convey("Without a token configuration everything should be allowed", t, func() {
+		_, err := Authorize(config.Options{StorageInterfaceName: "mock"}, "", Request{Cfg: "prod", Access: Admin})
+		So(err, ShouldBeNil)
+		_, err = List(config.Options{StorageInterfaceName: "mock"})
+		So(err, ShouldEqual, ErrMissingCfg)
+	})
+}
+
+func TestPolicies(t *testing.T) {
+	Convey("Policies should be parsed from access:cfg[:key]", t, func() {
+		p, err := ParsePolicy("write:prod-*:services/")
+		So(err, ShouldBeNil)
+		So(p, ShouldResemble, config.Policy{Access: Write, Cfg: "prod-*", Key: "services/"})
+		So(p.String(), ShouldEqual, "write:prod-*:services/")
+
+		p, err = ParsePolicy("read:*")
+		So(err, ShouldBeNil)
+		So(p.Key, ShouldEqual, "")
+
+		_, err = ParsePolicy("delete:*")
+		So(err, ShouldNotBeNil)
+		_, err = ParsePolicy("read")
+		So(err, ShouldNotBeNil)
+		_, err = ParsePolicy("read:[")
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Higher access should include lower access", t, func() {
+		admin := config.Policy{Cfg: "*", Access: Admin}
+		So(Allows(admin, Request{Cfg: "prod", Key: "a", Access: Read}), ShouldBeTrue)
+		So(Allows(admin, Request{Cfg: "prod", Access: Admin}), ShouldBeTrue)
+		So(Allows(config.Policy{Cfg: "*", Access: Read}, Request{Cfg: "prod", Key: "a", Access: Write}), ShouldBeFalse)
+		So(Allows(admin, Request{Cfg: "prod", Access: "unknown"}), ShouldBeFalse)
+	})
+
+	Convey("Key prefixes should match with or without a leading slash", t, func() {
+		p := config.Policy{Cfg: "prod", Key: "/services/", Access: Read}
+		So(Allows(p, Request{Cfg: "prod", Key: "services/api", Access: Read}), ShouldBeTrue)
+		So(Allows(p, Request{Cfg: "prod", Key: "/services/api", Access: Read}), ShouldBeTrue)
+		So(Allows(p, Request{Cfg: "prod", Key: "flags", Access: Read}), ShouldBeFalse)
+		So(Allows(p, Request{Cfg: "staging", Key: "services/api", Access: Read}), ShouldBeFalse)
+	})
+
+	Convey("Key prefixes should only match whole path segments", t, func() {
+		p := config.Policy{Cfg: "prod", Key: "services", Access: Read}
+		So(Allows(p, Request{Cfg: "prod", Key: "services", Access: Read}), ShouldBeTrue)
+		So(Allows(p, Request{Cfg: "prod", Key: "services/api", Access: Read}), ShouldBeTrue)
+		So(Allows(p, Request{Cfg: "prod", Key: "services-secrets/db", Access: Read}), ShouldBeFalse)
+		So(Allows(config.Policy{Cfg: "prod", Key: "services/", Access: Read}, Request{Cfg: "prod", Key: "services-secrets/db", Access: Read}), ShouldBeFalse)
+	})
+}
@@ -0,0 +1,103 @@
+// Package awssession builds the AWS sessions used for storage (DynamoDB) and encryption (KMS) from the discfg options.
+// Credentials come from the AWS SDK's default chain; environment variables, the shared credentials and config files
+// (including assume role and SSO profiles) and then container or instance roles. Keys set on the options take
+// priority and a role can be assumed on top of whichever credentials are found.
+package awssession
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/tmaiaroto/discfg/config"
+	"sync"
+)
+
+// sessions are the sessions built so far. A session caches its credentials (refreshing them as needed) and is
+// safe to share between goroutines, so there's one per set of credential options.
+var sessions = struct {
+	sync.Mutex
+	m map[config.AWS]*session.Session
+}{m: map[config.AWS]*session.Session{}}
+
+// Get returns the session for the options, building it the first time
+func Get(opts config.Options) (*session.Session, error) {
+	key := opts.Storage.AWS
+	// The endpoint is set on the service client, the session is the same without it
+	key.Endpoint = ""
+	sessions.Lock()
+	defer sessions.Unlock()
+	if sess, ok := sessions.m[key]; ok {
+		return sess, nil
+	}
+	sess, err := New(opts)
+	if err != nil {
+		return nil, err
+	}
+	sessions.m[key] = sess
+	return sess, nil
+}
+
+// New builds a session for the options
+func New(opts config.Options) (*session.Session, error) {
+	awsOpts := opts.Storage.AWS
+	sessOpts := session.Options{
+		Config:  aws.Config{Region: aws.String(awsOpts.Region)},
+		Profile: awsOpts.CredProfile,
+		// Read ~/.aws/config too, for assume role and SSO profiles
+		SharedConfigState: session.SharedConfigEnable,
+	}
+	// Keys passed via options take priority over the default chain. A session token goes with them
+	// (AWS Lambda's credentials are temporary for example).
+	if awsOpts.AccessKeyID != "" && awsOpts.SecretAccessKey != "" {
+		sessOpts.Config.Credentials = credentials.NewStaticCredentials(awsOpts.AccessKeyID, awsOpts.SecretAccessKey, awsOpts.SessionToken)
+	}
+	sess, err := session.NewSessionWithOptions(sessOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	if awsOpts.RoleARN != "" {
+		creds := stscreds.NewCredentials(sess, awsOpts.RoleARN, func(p *stscreds.AssumeRoleProvider) {
+			if awsOpts.ExternalID != "" {
+				p.ExternalID = aws.String(awsOpts.ExternalID)
+			}
+		})
+		sess = sess.Copy(&aws.Config{Credentials: creds})
+	}
+	return sess, nil
+}
+
+// identities are the identities found so far, per set of credential options like sessions
+var identities = struct {
+	sync.Mutex
+	m map[config.AWS]string
+}{m: map[config.AWS]string{}}
+
+// Identity returns the ARN of the identity the options resolve to (the user or assumed role). It's looked up once
+// per set of credential options, since keys are set with it.
+func Identity(ctx context.Context, opts config.Options) (string, error) {
+	key := opts.Storage.AWS
+	key.Endpoint = ""
+	identities.Lock()
+	arn, ok := identities.m[key]
+	identities.Unlock()
+	if ok {
+		return arn, nil
+	}
+
+	sess, err := Get(opts)
+	if err != nil {
+		return "", err
+	}
+	resp, err := sts.New(sess).GetCallerIdentityWithContext(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", err
+	}
+	identities.Lock()
+	identities.m[key] = aws.StringValue(resp.Arn)
+	identities.Unlock()
+	return aws.StringValue(resp.Arn), nil
+}
@@ -0,0 +1,70 @@
+package awssession
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/tmaiaroto/discfg/config"
+	"os"
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	opts := config.Options{}
+	opts.Storage.AWS.Region = "us-east-1"
+
+	Convey("A session should be reused for the same options", t, func() {
+		sess, err := Get(opts)
+		So(err, ShouldBeNil)
+		again, _ := Get(opts)
+		So(again, ShouldEqual, sess)
+
+		endpoint := opts
+		endpoint.Storage.AWS.Endpoint = "http://localhost:8000"
+		again, _ = Get(endpoint)
+		So(again, ShouldEqual, sess)
+
+		west := opts
+		west.Storage.AWS.Region = "us-west-2"
+		again, _ = Get(west)
+		So(again, ShouldNotEqual, sess)
+	})
+}
+
+func TestNew(t *testing.T) {
+	opts := config.Options{}
+	opts.Storage.AWS.Region = "us-east-1"
+
+	Convey("Keys passed via options should be used along with the session token", t, func() {
+		static := opts
+		static.Storage.AWS.AccessKeyID = "id"
+		static.Storage.AWS.SecretAccessKey = "secret"
+		static.Storage.AWS.SessionToken = "token"
+		sess, err := New(static)
+		So(err, ShouldBeNil)
+		creds, err := sess.Config.Credentials.Get()
+		So(err, ShouldBeNil)
+		So(creds.AccessKeyID, ShouldEqual, "id")
+		So(creds.SessionToken, ShouldEqual, "token")
+	})
+
+	Convey("The session token should not be put in the environment", t, func() {
+		token := opts
+		token.Storage.AWS.SessionToken = "token"
+		os.Unsetenv("AWS_SESSION_TOKEN")
+		_, err := New(token)
+		So(err, ShouldBeNil)
+		So(os.Getenv("AWS_SESSION_TOKEN"), ShouldEqual, "")
+	})
+
+	Convey("A role should be assumed when given", t, func() {
+		role := opts
+		role.Storage.AWS.AccessKeyID = "id"
+		role.Storage.AWS.SecretAccessKey = "secret"
+		base, _ := New(role)
+		role.Storage.AWS.RoleARN = "arn:aws:iam::123456789012:role/discfg"
+		role.Storage.AWS.ExternalID = "external"
+		sess, err := New(role)
+		So(err, ShouldBeNil)
+		// The role's credentials come from STS (so aren't retrieved here), on top of the keys
+		So(sess.Config.Credentials, ShouldNotEqual, base.Config.Credentials)
+	})
+}
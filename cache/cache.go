@@ -0,0 +1,306 @@
+// Package cache provides a caching Shipper that keeps items in memory so repeated reads don't each hit storage.
+// Cached items are served for up to a max age per config. After that, the config version on the root key "/" is
+// read (one read for the whole config) and should it have changed, every cached item for the config is dropped.
+// Items are never served past their expiration. With Refresh, config versions are checked in the background instead
+// (and the items of changed configs read again) so reads don't wait on it.
+//
+// Items are read after the config version and both reads are consistent, so an item is never older than the
+// version it's cached under. That relies on the version changing no earlier than the item, which is the case for
+// every storage engine (see storage.AtomicVersioner).
+package cache
+
+import (
+	"context"
+	"errors"
+	"github.com/tmaiaroto/discfg/config"
+	"github.com/tmaiaroto/discfg/storage"
+	"sync"
+	"time"
+)
+
+// Error message constants, reduce repetition.
+const (
+	errMsgInvalidShipper = "Invalid shipper interface."
+)
+
+// Stats about the cache
+type Stats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+	// Reads of the config version and how many of those dropped the config's cached items
+	Revalidations uint64 `json:"revalidations"`
+	Invalidations uint64 `json:"invalidations"`
+	Items         int    `json:"items"`
+}
+
+// Shipper wraps another Shipper, caching Get. Everything else goes straight to the wrapped Shipper.
+type Shipper struct {
+	shipper storage.Shipper
+	// How long cached items are served before the config version is checked
+	maxAge time.Duration
+
+	mu       sync.Mutex
+	items    map[string]map[string]config.Item
+	versions map[string]cfgVersion
+	stats    Stats
+}
+
+// cfgVersion is a config's version as of the last time it was checked, with the options to check it again
+type cfgVersion struct {
+	version int64
+	checked time.Time
+	opts    config.Options
+}
+
+// New returns a Shipper caching items from the given Shipper
+func New(shipper storage.Shipper, maxAge time.Duration) *Shipper {
+	return &Shipper{
+		shipper:  shipper,
+		maxAge:   maxAge,
+		items:    map[string]map[string]config.Item{},
+		versions: map[string]cfgVersion{},
+	}
+}
+
+// RegisteredName returns the name a cached storage engine is registered as
+func RegisteredName(name string) string {
+	return name + "+cache"
+}
+
+// Register wraps the Shipper registered under the name with a cache and registers it (see RegisteredName).
+// Registering the same name again returns the existing cache, so there is one cache per storage engine in a process.
+func Register(name string, maxAge time.Duration) (*Shipper, error) {
+	shippers := storage.ListShippers()
+	if s, ok := shippers[RegisteredName(name)].(*Shipper); ok {
+		return s, nil
+	}
+	shipper, ok := shippers[name]
+	if !ok {
+		return nil, errors.New(errMsgInvalidShipper)
+	}
+	s := New(shipper, maxAge)
+	storage.RegisterShipper(RegisteredName(name), s)
+	return s, nil
+}
+
+// Stats returns the cache hit/miss stats
+func (s *Shipper) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := s.stats
+	for _, items := range s.items {
+		stats.Items += len(items)
+	}
+	return stats
+}
+
+// Flush drops every cached item
+func (s *Shipper) Flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items = map[string]map[string]config.Item{}
+	s.versions = map[string]cfgVersion{}
+}
+
+// Get returns a cached item, getting it from storage on a miss. The root key "/" is never cached since it holds
+// the config version (which is checked for changes), neither are unversioned keys.
+func (s *Shipper) Get(opts config.Options) (config.Item, error) {
+	return s.GetWithContext(context.Background(), opts)
+}
+
+// GetWithContext is Get with a context for the wrapped Shipper
+func (s *Shipper) GetWithContext(ctx context.Context, opts config.Options) (config.Item, error) {
+	cfg := cfgID(opts)
+	shipper := storage.WithContext(s.shipper)
+	// A stale read could otherwise be cached as the item as of the config version
+	opts.ConsistentRead = true
+	if opts.Key == "/" {
+		item, err := shipper.GetWithContext(ctx, opts)
+		if err == nil {
+			s.checked(opts, item.CfgVersion, time.Now())
+		}
+		return item, err
+	}
+
+	// Changes to unversioned keys can't be seen from the config version
+	if opts.Unversioned {
+		return shipper.GetWithContext(ctx, opts)
+	}
+
+	if err := s.revalidate(ctx, opts); err != nil {
+		return config.Item{}, err
+	}
+	s.mu.Lock()
+	item, ok := s.items[cfg][opts.Key]
+	if ok && item.TTL > 0 && !item.Expiration.After(time.Now()) {
+		delete(s.items[cfg], opts.Key)
+		ok = false
+	}
+	if ok {
+		s.stats.Hits++
+		s.mu.Unlock()
+		return item, nil
+	}
+	s.stats.Misses++
+	s.mu.Unlock()
+
+	item, err := shipper.GetWithContext(ctx, opts)
+	if err == nil {
+		s.mu.Lock()
+		if s.items[cfg] == nil {
+			s.items[cfg] = map[string]config.Item{}
+		}
+		s.items[cfg][opts.Key] = item
+		s.mu.Unlock()
+	}
+	return item, err
+}
+
+// revalidate checks the config version once the max age has passed, dropping the config's items if it changed
+func (s *Shipper) revalidate(ctx context.Context, opts config.Options) error {
+	cfg := cfgID(opts)
+	now := time.Now()
+	s.mu.Lock()
+	v, ok := s.versions[cfg]
+	s.mu.Unlock()
+	if ok && now.Sub(v.checked) < s.maxAge {
+		return nil
+	}
+
+	rootOpts := opts
+	rootOpts.Key = "/"
+	root, err := storage.WithContext(s.shipper).GetWithContext(ctx, rootOpts)
+	if err != nil {
+		return err
+	}
+	s.checked(opts, root.CfgVersion, now)
+	return nil
+}
+
+// checked records a config's version, dropping its items if the version changed
+func (s *Shipper) checked(opts config.Options, version int64, now time.Time) {
+	cfg := cfgID(opts)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.Revalidations++
+	if v, ok := s.versions[cfg]; !ok || v.version != version {
+		if len(s.items[cfg]) > 0 {
+			s.stats.Invalidations++
+		}
+		delete(s.items, cfg)
+	}
+	s.versions[cfg] = cfgVersion{version: version, checked: now, opts: versionOptions(opts)}
+}
+
+// versionOptions returns the options for reading a config again later, outside of the request they came from
+func versionOptions(opts config.Options) config.Options {
+	opts.Context = nil
+	opts.Key = "/"
+	opts.Value = nil
+	return opts
+}
+
+// Refresh checks the version of every cached config each interval in the background, until the returned function is
+// called. The items of a changed config are read again before they replace the cached ones, so reads neither wait
+// on the config version nor miss after a change. An interval under the max age keeps reads from checking at all.
+func (s *Shipper) Refresh(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	var once sync.Once
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				s.refresh(context.Background())
+			}
+		}
+	}()
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+// refresh checks the version of every cached config, reading the items of those that changed again
+func (s *Shipper) refresh(ctx context.Context) {
+	s.mu.Lock()
+	versions := make(map[string]cfgVersion, len(s.versions))
+	keys := map[string][]string{}
+	for cfg, v := range s.versions {
+		versions[cfg] = v
+		for key := range s.items[cfg] {
+			keys[cfg] = append(keys[cfg], key)
+		}
+	}
+	s.mu.Unlock()
+
+	for cfg, v := range versions {
+		// Errors are left for reads to run into
+		s.refreshCfg(ctx, cfg, v, keys[cfg])
+	}
+}
+
+// refreshCfg checks a config's version, reading its cached keys again should it have changed. Nothing is replaced
+// if the config was invalidated or checked by a read in the meantime.
+func (s *Shipper) refreshCfg(ctx context.Context, cfg string, v cfgVersion, keys []string) error {
+	shipper := storage.WithContext(s.shipper)
+	opts := v.opts
+	opts.ConsistentRead = true
+	now := time.Now()
+	root, err := shipper.GetWithContext(ctx, opts)
+	if err != nil {
+		return err
+	}
+	items := map[string]config.Item{}
+	if root.CfgVersion != v.version {
+		for _, key := range keys {
+			keyOpts := opts
+			keyOpts.Key = key
+			item, err := shipper.GetWithContext(ctx, keyOpts)
+			if err != nil {
+				return err
+			}
+			items[key] = item
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if current, ok := s.versions[cfg]; !ok || current.version != v.version || !current.checked.Equal(v.checked) {
+		return nil
+	}
+	s.stats.Revalidations++
+	if root.CfgVersion != v.version {
+		if len(keys) > 0 {
+			s.stats.Invalidations++
+		}
+		s.items[cfg] = items
+	}
+	s.versions[cfg] = cfgVersion{version: root.CfgVersion, checked: now, opts: v.opts}
+	return nil
+}
+
+// invalidate drops a cached item, along with the config version since the change advances it
+func (s *Shipper) invalidate(opts config.Options) {
+	cfg := cfgID(opts)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.items[cfg], opts.Key)
+	delete(s.versions, cfg)
+}
+
+// invalidateCfg drops every cached item for a config
+func (s *Shipper) invalidateCfg(opts config.Options) {
+	cfg := cfgID(opts)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.items, cfg)
+	delete(s.versions, cfg)
+}
+
+// cfgID identifies a config, configs with the same name may be in different regions
+func cfgID(opts config.Options) string {
+	return opts.Storage.AWS.Region + "/" + opts.CfgName
+}
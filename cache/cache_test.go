@@ -0,0 +1,136 @@
+package cache
+
+import (
+	"context"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/tmaiaroto/discfg/config"
+	"github.com/tmaiaroto/discfg/storage"
+	"github.com/tmaiaroto/discfg/storage/mockdb"
+	"testing"
+	"time"
+)
+
+// readShipper records whether reads were consistent
+type readShipper struct {
+	mockdb.MockShipper
+	consistent *[]bool
+}
+
+func (r readShipper) Get(opts config.Options) (config.Item, error) {
+	*r.consistent = append(*r.consistent, opts.ConsistentRead)
+	return r.MockShipper.Get(opts)
+}
+
+func TestGet(t *testing.T) {
+	// Changing keys advances the mock config version, which other tests rely on.
+	root := mockdb.MockCfg["mockcfg"]["/"]
+	defer func() {
+		mockdb.MockCfg["mockcfg"]["/"] = root
+		delete(mockdb.MockCfg["mockcfg"], "cached")
+	}()
+	opts := config.Options{StorageInterfaceName: "mock", CfgName: "mockcfg", Key: "cached"}
+
+	Convey("Should serve items from the cache until the config version changes", t, func() {
+		s := New(mockdb.MockShipper{}, time.Hour)
+		mockdb.MockCfg["mockcfg"]["cached"] = config.Item{Key: "cached", Value: []byte("a"), Version: 1}
+
+		item, err := s.Get(opts)
+		So(err, ShouldBeNil)
+		So(string(item.Value.([]byte)), ShouldEqual, "a")
+		So(s.Stats().Misses, ShouldEqual, uint64(1))
+
+		// Changed behind the cache's back, without advancing the config version
+		mockdb.MockCfg["mockcfg"]["cached"] = config.Item{Key: "cached", Value: []byte("b"), Version: 2}
+		item, _ = s.Get(opts)
+		So(string(item.Value.([]byte)), ShouldEqual, "a")
+		So(s.Stats().Hits, ShouldEqual, uint64(1))
+		So(s.Stats().Items, ShouldEqual, 1)
+
+		// Reading the root key checks the config version
+		_ = mockdb.MockShipper{}.UpdateConfigVersion(opts)
+		rootOpts := opts
+		rootOpts.Key = "/"
+		_, _ = s.Get(rootOpts)
+		item, _ = s.Get(opts)
+		So(string(item.Value.([]byte)), ShouldEqual, "b")
+		So(s.Stats().Invalidations, ShouldEqual, uint64(1))
+	})
+
+	Convey("Should check the config version once the max age has passed", t, func() {
+		s := New(mockdb.MockShipper{}, 0)
+		mockdb.MockCfg["mockcfg"]["cached"] = config.Item{Key: "cached", Value: []byte("a"), Version: 1}
+		_, _ = s.Get(opts)
+
+		mockdb.MockCfg["mockcfg"]["cached"] = config.Item{Key: "cached", Value: []byte("b"), Version: 2}
+		_ = mockdb.MockShipper{}.UpdateConfigVersion(opts)
+		item, _ := s.Get(opts)
+		So(string(item.Value.([]byte)), ShouldEqual, "b")
+	})
+
+	Convey("Should not serve expired items", t, func() {
+		s := New(mockdb.MockShipper{}, time.Hour)
+		mockdb.MockCfg["mockcfg"]["cached"] = config.Item{Key: "cached", Value: []byte("a"), Version: 1, TTL: 1, Expiration: time.Now().Add(50 * time.Millisecond)}
+		item, _ := s.Get(opts)
+		So(string(item.Value.([]byte)), ShouldEqual, "a")
+
+		time.Sleep(60 * time.Millisecond)
+		item, _ = s.Get(opts)
+		So(item.Value, ShouldBeNil)
+		So(s.Stats().Hits, ShouldEqual, uint64(0))
+	})
+
+	Convey("Should drop items when they're changed through the cache", t, func() {
+		storage.RegisterShipper("mock", mockdb.MockShipper{})
+		s, err := Register("mock", time.Hour)
+		So(err, ShouldBeNil)
+		again, _ := Register("mock", time.Hour)
+		So(again, ShouldEqual, s)
+
+		cachedOpts := opts
+		cachedOpts.StorageInterfaceName = RegisteredName("mock")
+		cachedOpts.Value = []byte("c")
+		_, _ = storage.Get(cachedOpts)
+		_, err = storage.Update(cachedOpts)
+		So(err, ShouldBeNil)
+		item, _ := storage.Get(cachedOpts)
+		So(string(item.Value.([]byte)), ShouldEqual, "c")
+
+		_, err = Register("invalid", time.Hour)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Should read the config version and items consistently", t, func() {
+		consistent := []bool{}
+		s := New(readShipper{consistent: &consistent}, time.Hour)
+		_, _ = s.Get(opts)
+		So(consistent, ShouldResemble, []bool{true, true})
+		So(s.Unwrap(), ShouldHaveSameTypeAs, readShipper{})
+	})
+
+	Convey("Should read the items of changed configs again when refreshing", t, func() {
+		s := New(mockdb.MockShipper{}, time.Hour)
+		mockdb.MockCfg["mockcfg"]["cached"] = config.Item{Key: "cached", Value: []byte("a"), Version: 1}
+		_, _ = s.Get(opts)
+
+		// Nothing changed, the items stay cached
+		s.refresh(context.Background())
+		So(s.Stats().Revalidations, ShouldEqual, uint64(2))
+		So(s.Stats().Items, ShouldEqual, 1)
+
+		mockdb.MockCfg["mockcfg"]["cached"] = config.Item{Key: "cached", Value: []byte("b"), Version: 2}
+		_ = mockdb.MockShipper{}.UpdateConfigVersion(opts)
+		s.refresh(context.Background())
+		So(s.Stats().Invalidations, ShouldEqual, uint64(1))
+		item, _ := s.Get(opts)
+		So(string(item.Value.([]byte)), ShouldEqual, "b")
+		So(s.Stats().Hits, ShouldEqual, uint64(1))
+		So(s.Stats().Misses, ShouldEqual, uint64(1))
+	})
+
+	Convey("Should stop refreshing", t, func() {
+		s := New(mockdb.MockShipper{}, time.Hour)
+		stop := s.Refresh(time.Hour)
+		stop()
+		stop()
+	})
+}
@@ -0,0 +1,176 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"github.com/tmaiaroto/discfg/config"
+	"github.com/tmaiaroto/discfg/storage"
+)
+
+// Name returns the wrapped Shipper's name, noting it's cached
+func (s *Shipper) Name(opts config.Options) string {
+	return s.shipper.Name(opts) + " (cached)"
+}
+
+// Options returns the wrapped Shipper's options along with the cache's
+func (s *Shipper) Options(opts config.Options) map[string]interface{} {
+	options := map[string]interface{}{}
+	for k, v := range s.shipper.Options(opts) {
+		options[k] = v
+	}
+	options["CacheMaxAge"] = s.maxAge.String()
+	return options
+}
+
+// Unwrap returns the wrapped Shipper
+func (s *Shipper) Unwrap() storage.Shipper {
+	return s.shipper
+}
+
+// Identity returns the wrapped Shipper's identity, if it has one
+func (s *Shipper) Identity(opts config.Options) (string, error) {
+	if i, ok := s.shipper.(storage.Identifier); ok {
+		return i.Identity(opts)
+	}
+	return "", nil
+}
+
+// CopyItemsWithContext copies a config's items with the wrapped Shipper, if it can
+func (s *Shipper) CopyItemsWithContext(ctx context.Context, from config.Options, to config.Options) (int, error) {
+	if c, ok := s.shipper.(storage.ItemCopier); ok {
+		return c.CopyItemsWithContext(ctx, from, to)
+	}
+	return 0, errors.New(s.shipper.Name(from) + " can't copy configurations")
+}
+
+// FreezeWithContext freezes (or unfreezes) a config with the wrapped Shipper, if it can. The root key isn't cached,
+// so there's nothing to invalidate.
+func (s *Shipper) FreezeWithContext(ctx context.Context, opts config.Options, frozen bool) error {
+	if f, ok := s.shipper.(storage.Freezer); ok {
+		return f.FreezeWithContext(ctx, opts, frozen)
+	}
+	return errors.New(s.shipper.Name(opts) + " can't freeze configurations")
+}
+
+// AtomicVersions returns whether or not the wrapped Shipper checks whether the config is frozen and updates its
+// version itself when a key changes
+func (s *Shipper) AtomicVersions() bool {
+	a, ok := s.shipper.(storage.AtomicVersioner)
+	return ok && a.AtomicVersions()
+}
+
+// CreateConfig creates a config
+func (s *Shipper) CreateConfig(opts config.Options, settings map[string]interface{}) (interface{}, error) {
+	return s.CreateConfigWithContext(context.Background(), opts, settings)
+}
+
+// CreateConfigWithContext is CreateConfig with a context for the wrapped Shipper
+func (s *Shipper) CreateConfigWithContext(ctx context.Context, opts config.Options, settings map[string]interface{}) (interface{}, error) {
+	s.invalidateCfg(opts)
+	return storage.WithContext(s.shipper).CreateConfigWithContext(ctx, opts, settings)
+}
+
+// DeleteConfig deletes a config, dropping its cached items
+func (s *Shipper) DeleteConfig(opts config.Options) (interface{}, error) {
+	return s.DeleteConfigWithContext(context.Background(), opts)
+}
+
+// DeleteConfigWithContext is DeleteConfig with a context for the wrapped Shipper
+func (s *Shipper) DeleteConfigWithContext(ctx context.Context, opts config.Options) (interface{}, error) {
+	s.invalidateCfg(opts)
+	return storage.WithContext(s.shipper).DeleteConfigWithContext(ctx, opts)
+}
+
+// UpdateConfig updates a config
+func (s *Shipper) UpdateConfig(opts config.Options, settings map[string]interface{}) (interface{}, error) {
+	return s.UpdateConfigWithContext(context.Background(), opts, settings)
+}
+
+// UpdateConfigWithContext is UpdateConfig with a context for the wrapped Shipper
+func (s *Shipper) UpdateConfigWithContext(ctx context.Context, opts config.Options, settings map[string]interface{}) (interface{}, error) {
+	return storage.WithContext(s.shipper).UpdateConfigWithContext(ctx, opts, settings)
+}
+
+// ConfigState returns the state of the config
+func (s *Shipper) ConfigState(opts config.Options) (string, error) {
+	return s.ConfigStateWithContext(context.Background(), opts)
+}
+
+// ConfigStateWithContext is ConfigState with a context for the wrapped Shipper
+func (s *Shipper) ConfigStateWithContext(ctx context.Context, opts config.Options) (string, error) {
+	return storage.WithContext(s.shipper).ConfigStateWithContext(ctx, opts)
+}
+
+// ListConfigs lists configs, which isn't cached
+func (s *Shipper) ListConfigs(opts config.Options) ([]config.CfgSummary, error) {
+	return s.ListConfigsWithContext(context.Background(), opts)
+}
+
+// ListConfigsWithContext is ListConfigs with a context for the wrapped Shipper
+func (s *Shipper) ListConfigsWithContext(ctx context.Context, opts config.Options) ([]config.CfgSummary, error) {
+	return storage.WithContext(s.shipper).ListConfigsWithContext(ctx, opts)
+}
+
+// Update updates a key, dropping it from the cache
+func (s *Shipper) Update(opts config.Options) (config.Item, error) {
+	return s.UpdateWithContext(context.Background(), opts)
+}
+
+// UpdateWithContext is Update with a context for the wrapped Shipper
+func (s *Shipper) UpdateWithContext(ctx context.Context, opts config.Options) (config.Item, error) {
+	defer s.invalidate(opts)
+	return storage.WithContext(s.shipper).UpdateWithContext(ctx, opts)
+}
+
+// List lists items, which isn't cached
+func (s *Shipper) List(opts config.Options) ([]config.Item, error) {
+	return s.ListWithContext(context.Background(), opts)
+}
+
+// ListWithContext is List with a context for the wrapped Shipper
+func (s *Shipper) ListWithContext(ctx context.Context, opts config.Options) ([]config.Item, error) {
+	return storage.WithContext(s.shipper).ListWithContext(ctx, opts)
+}
+
+// Delete deletes a key, dropping it from the cache
+func (s *Shipper) Delete(opts config.Options) (config.Item, error) {
+	return s.DeleteWithContext(context.Background(), opts)
+}
+
+// DeleteWithContext is Delete with a context for the wrapped Shipper
+func (s *Shipper) DeleteWithContext(ctx context.Context, opts config.Options) (config.Item, error) {
+	defer s.invalidate(opts)
+	return storage.WithContext(s.shipper).DeleteWithContext(ctx, opts)
+}
+
+// Touch resets a key's TTL, dropping it from the cache
+func (s *Shipper) Touch(opts config.Options) (config.Item, error) {
+	return s.TouchWithContext(context.Background(), opts)
+}
+
+// TouchWithContext is Touch with a context for the wrapped Shipper
+func (s *Shipper) TouchWithContext(ctx context.Context, opts config.Options) (config.Item, error) {
+	defer s.invalidate(opts)
+	return storage.WithContext(s.shipper).TouchWithContext(ctx, opts)
+}
+
+// Increment adds to a key's value, dropping it from the cache
+func (s *Shipper) Increment(opts config.Options, delta float64) (config.Item, error) {
+	return s.IncrementWithContext(context.Background(), opts, delta)
+}
+
+// IncrementWithContext is Increment with a context for the wrapped Shipper
+func (s *Shipper) IncrementWithContext(ctx context.Context, opts config.Options, delta float64) (config.Item, error) {
+	defer s.invalidate(opts)
+	return storage.WithContext(s.shipper).IncrementWithContext(ctx, opts, delta)
+}
+
+// UpdateConfigVersion updates the config version
+func (s *Shipper) UpdateConfigVersion(opts config.Options) error {
+	return s.UpdateConfigVersionWithContext(context.Background(), opts)
+}
+
+// UpdateConfigVersionWithContext is UpdateConfigVersion with a context for the wrapped Shipper
+func (s *Shipper) UpdateConfigVersionWithContext(ctx context.Context, opts config.Options) error {
+	return storage.WithContext(s.shipper).UpdateConfigVersionWithContext(ctx, opts)
+}
@@ -0,0 +1,279 @@
+// Package client is a Go client for applications working with a discfg configuration. Unlike the commands package,
+// it returns typed items and Go errors. A client works with storage directly (DynamoDB by default) or through the
+// discfg HTTP API (see NewHTTP).
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/tmaiaroto/discfg/cache"
+	"github.com/tmaiaroto/discfg/config"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ErrNotFound is returned when a key has no value
+var ErrNotFound = errors.New("Key not found")
+
+// ErrNotSupported is returned for operations the HTTP API doesn't have
+var ErrNotSupported = errors.New("Operation not supported by the HTTP API")
+
+// Item is a key and its value
+type Item struct {
+	Key     string
+	Value   []byte
+	Version int64
+	// The value type (see the config.ValueType constants), empty for untyped values
+	Type       string
+	TTL        time.Duration
+	Expiration time.Time
+	Encrypted  bool
+	Sensitive  bool
+}
+
+// String returns the value as a string
+func (i Item) String() string {
+	return string(i.Value)
+}
+
+// Int returns the value as an integer
+func (i Item) Int() (int64, error) {
+	return strconv.ParseInt(string(i.Value), 10, 64)
+}
+
+// Float returns the value as a float
+func (i Item) Float() (float64, error) {
+	return strconv.ParseFloat(string(i.Value), 64)
+}
+
+// Bool returns the value as a bool
+func (i Item) Bool() (bool, error) {
+	return strconv.ParseBool(string(i.Value))
+}
+
+// JSON decodes a JSON value into v
+func (i Item) JSON(v interface{}) error {
+	return json.Unmarshal(i.Value, v)
+}
+
+// SetOptions are options for setting a key
+type SetOptions struct {
+	// The key expires after this long (rounded down to seconds), 0 never expires
+	TTL time.Duration
+	// The value type (see the config.ValueType constants)
+	Type string
+	// Mark the key as sensitive (redacted in output), false leaves the key's flag as it was
+	Sensitive bool
+	// Only set the value if the current value matches (not supported by the HTTP API)
+	ConditionalValue string
+}
+
+// WatchEvent is sent by Watch each time a key changes. Err is ErrNotFound once a key has been deleted (or expired).
+type WatchEvent struct {
+	Item Item
+	Err  error
+}
+
+// Client works with the keys in a discfg configuration
+type Client struct {
+	opts    config.Options
+	backend backend
+	cache   *cache.Shipper
+}
+
+// backend does the work for a client. Responses are the same as the commands package (and the HTTP API) return.
+type backend interface {
+	get(context.Context, config.Options) (config.ResponseObject, error)
+	set(context.Context, config.Options) (config.ResponseObject, error)
+	delete(context.Context, config.Options) (config.ResponseObject, error)
+	list(context.Context, config.Options) (config.ResponseObject, error)
+}
+
+// New returns a client working with storage directly. The options set the storage engine ("dynamodb" by default),
+// the AWS region ("us-east-1" by default), the config name and anything else such as credentials or encryption.
+func New(opts config.Options) *Client {
+	if opts.StorageInterfaceName == "" {
+		opts.StorageInterfaceName = "dynamodb"
+	}
+	if opts.Storage.AWS.Region == "" {
+		opts.Storage.AWS.Region = "us-east-1"
+	}
+	return &Client{opts: opts, backend: storageBackend{}}
+}
+
+// NewCached returns a client working with storage directly like New, with items cached in memory for up to the max
+// age before checking the config for changes (see the cache package). Cached clients for the same storage engine
+// share one cache.
+func NewCached(opts config.Options, maxAge time.Duration) (*Client, error) {
+	c := New(opts)
+	s, err := cache.Register(c.opts.StorageInterfaceName, maxAge)
+	if err != nil {
+		return nil, err
+	}
+	c.opts.StorageInterfaceName = cache.RegisteredName(c.opts.StorageInterfaceName)
+	c.cache = s
+	return c, nil
+}
+
+// CacheStats returns the cache hit/miss stats for a cached client
+func (c *Client) CacheStats() cache.Stats {
+	if c.cache == nil {
+		return cache.Stats{}
+	}
+	return c.cache.Stats()
+}
+
+// RefreshCache checks the cached configs for changes in the background each interval until the returned function is
+// called, so reads don't wait on it (see cache.Shipper.Refresh). It does nothing for a client that isn't cached.
+func (c *Client) RefreshCache(interval time.Duration) (stop func()) {
+	if c.cache == nil {
+		return func() {}
+	}
+	return c.cache.Refresh(interval)
+}
+
+// NewHTTP returns a client working through the discfg HTTP API at the base URL (API Gateway for example).
+// The options set the config name, the API token (Auth.Token) and whether or not sensitive values are revealed.
+// A nil HTTP client uses http.DefaultClient.
+func NewHTTP(baseURL string, opts config.Options, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{opts: opts, backend: httpBackend{baseURL: baseURL, client: httpClient}}
+}
+
+// Get returns the item for a key, ErrNotFound if the key has no value
+func (c *Client) Get(ctx context.Context, key string) (Item, error) {
+	opts := c.opts
+	opts.Key = key
+	resp, err := c.backend.get(ctx, opts)
+	if err = responseError(resp, err); err != nil {
+		return Item{}, err
+	}
+	if resp.Item.Value == nil {
+		return Item{}, ErrNotFound
+	}
+	return itemFromConfig(resp.Item), nil
+}
+
+// Set sets the value for a key, returning the updated item
+func (c *Client) Set(ctx context.Context, key string, value []byte) (Item, error) {
+	return c.SetWithOptions(ctx, key, value, SetOptions{})
+}
+
+// SetWithOptions sets the value for a key with a TTL, type, etc. returning the updated item
+func (c *Client) SetWithOptions(ctx context.Context, key string, value []byte, setOpts SetOptions) (Item, error) {
+	opts := c.opts
+	opts.Key = key
+	opts.Value = value
+	opts.TTL = int64(setOpts.TTL / time.Second)
+	opts.ValueType = setOpts.Type
+	if setOpts.Sensitive {
+		opts.Sensitive = &setOpts.Sensitive
+	}
+	opts.ConditionalValue = setOpts.ConditionalValue
+	resp, err := c.backend.set(ctx, opts)
+	if err = responseError(resp, err); err != nil {
+		return Item{}, err
+	}
+	return itemFromConfig(resp.Item), nil
+}
+
+// Delete deletes a key, returning the deleted item. ErrNotFound is returned if the key had no value.
+func (c *Client) Delete(ctx context.Context, key string) (Item, error) {
+	opts := c.opts
+	opts.Key = key
+	resp, err := c.backend.delete(ctx, opts)
+	if err = responseError(resp, err); err != nil {
+		return Item{}, err
+	}
+	if resp.PrevItem.Value == nil {
+		return Item{}, ErrNotFound
+	}
+	return itemFromConfig(resp.PrevItem), nil
+}
+
+// List returns the items with keys beginning with the prefix (all items for an empty prefix)
+func (c *Client) List(ctx context.Context, prefix string) ([]Item, error) {
+	opts := c.opts
+	opts.Key = prefix
+	resp, err := c.backend.list(ctx, opts)
+	if err = responseError(resp, err); err != nil {
+		return nil, err
+	}
+	items := make([]Item, len(resp.Items))
+	for i := range resp.Items {
+		items[i] = itemFromConfig(resp.Items[i])
+	}
+	return items, nil
+}
+
+// Watch checks a key every interval and sends an event with its current item, and then again each time it changes.
+// The channel is closed once the context is done.
+func (c *Client) Watch(ctx context.Context, key string, interval time.Duration) <-chan WatchEvent {
+	events := make(chan WatchEvent)
+	go func() {
+		defer close(events)
+		var last *Item
+		for {
+			item, err := c.Get(ctx, key)
+			if ctx.Err() != nil {
+				return
+			}
+			var event *WatchEvent
+			switch {
+			case err == ErrNotFound:
+				if last == nil || last.Version != 0 {
+					event = &WatchEvent{Err: err}
+					last = &Item{}
+				}
+			case err != nil:
+				event = &WatchEvent{Err: err}
+			case last == nil || item.Version != last.Version:
+				event = &WatchEvent{Item: item}
+				last = &item
+			}
+			if event != nil {
+				select {
+				case events <- *event:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case <-time.After(interval):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events
+}
+
+// responseError returns an error for a failed request or a response with an error message
+func responseError(resp config.ResponseObject, err error) error {
+	if err != nil {
+		return err
+	}
+	if resp.Error != "" {
+		return errors.New(resp.Error)
+	}
+	return nil
+}
+
+// itemFromConfig converts a response item
+func itemFromConfig(item config.Item) Item {
+	value, _ := item.Value.([]byte)
+	return Item{
+		Key:        item.Key,
+		Value:      value,
+		Version:    item.Version,
+		Type:       item.Type,
+		TTL:        time.Duration(item.TTL) * time.Second,
+		Expiration: item.Expiration,
+		Encrypted:  item.Encrypted,
+		Sensitive:  item.Sensitive,
+	}
+}
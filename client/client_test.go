@@ -0,0 +1,154 @@
+package client
+
+import (
+	"context"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/tmaiaroto/discfg/config"
+	"github.com/tmaiaroto/discfg/storage"
+	"github.com/tmaiaroto/discfg/storage/mockdb"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStorageClient(t *testing.T) {
+	// Setting keys advances the mock config version, which other tests rely on.
+	root := mockdb.MockCfg["mockcfg"]["/"]
+	defer func() {
+		mockdb.MockCfg["mockcfg"]["/"] = root
+		delete(mockdb.MockCfg["mockcfg"], "/client")
+	}()
+	storage.RegisterShipper("mock", mockdb.MockShipper{})
+	c := New(config.Options{StorageInterfaceName: "mock", CfgName: "mockcfg"})
+	ctx := context.Background()
+
+	Convey("Should get, set, list and delete keys", t, func() {
+		item, err := c.Get(ctx, "initial")
+		So(err, ShouldBeNil)
+		So(item.String(), ShouldEqual, "initial value for test")
+
+		_, err = c.Get(ctx, "missing")
+		So(err, ShouldEqual, ErrNotFound)
+
+		item, err = c.SetWithOptions(ctx, "client", []byte("42"), SetOptions{Type: config.ValueTypeNumber})
+		So(err, ShouldBeNil)
+		So(item.Type, ShouldEqual, config.ValueTypeNumber)
+		n, err := item.Int()
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, int64(42))
+
+		items, err := c.List(ctx, "initial")
+		So(err, ShouldBeNil)
+		So(len(items), ShouldEqual, 2)
+
+		item, err = c.Delete(ctx, "client")
+		So(err, ShouldBeNil)
+		So(item.String(), ShouldEqual, "42")
+		_, err = c.Delete(ctx, "client")
+		So(err, ShouldEqual, ErrNotFound)
+	})
+
+	Convey("Should return errors as Go errors", t, func() {
+		_, err := c.Set(ctx, "", []byte("value"))
+		So(err, ShouldNotBeNil)
+
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err = c.Get(canceled, "initial")
+		So(err, ShouldEqual, context.Canceled)
+	})
+}
+
+func TestHTTPClient(t *testing.T) {
+	var method, path, query, body, authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path, query = r.Method, r.URL.EscapedPath(), r.URL.RawQuery
+		authorization = r.Header.Get("Authorization")
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+		switch r.Method {
+		case "GET":
+			w.Write([]byte(`{"action": "get", "item": {"version": 2, "key": "/hosts", "value": ["a", "b"], "type": "json"}}`))
+		case "PUT":
+			w.Write([]byte(`{"action": "set", "item": {"version": 3, "key": "/icon", "value": "aWNvbg==", "type": "binary"}}`))
+		case "DELETE":
+			w.Write([]byte(`{"action": "delete", "error": "The conditional request failed"}`))
+		}
+	}))
+	defer server.Close()
+	opts := config.Options{CfgName: "mycfg"}
+	opts.Auth.Token = "discfg_abc"
+	c := NewHTTP(server.URL, opts, nil)
+	ctx := context.Background()
+
+	Convey("Should get keys from the HTTP API", t, func() {
+		item, err := c.Get(ctx, "hosts")
+		So(err, ShouldBeNil)
+		So(method, ShouldEqual, "GET")
+		So(path, ShouldEqual, "/mycfg/keys/hosts")
+		So(authorization, ShouldEqual, "Bearer discfg_abc")
+		So(item.Version, ShouldEqual, int64(2))
+		var hosts []string
+		So(item.JSON(&hosts), ShouldBeNil)
+		So(hosts, ShouldResemble, []string{"a", "b"})
+	})
+
+	Convey("Should set keys with the HTTP API", t, func() {
+		item, err := c.SetWithOptions(ctx, "icon", []byte("icon"), SetOptions{Type: config.ValueTypeBinary, TTL: time.Minute})
+		So(err, ShouldBeNil)
+		So(method, ShouldEqual, "PUT")
+		So(query, ShouldEqual, "ttl=60&type=binary")
+		So(body, ShouldEqual, "icon")
+		So(item.String(), ShouldEqual, "icon")
+
+		_, err = c.SetWithOptions(ctx, "icon", []byte("icon"), SetOptions{ConditionalValue: "old"})
+		So(err, ShouldEqual, ErrNotSupported)
+	})
+
+	Convey("Should return API errors as Go errors", t, func() {
+		_, err := c.Delete(ctx, "hosts")
+		So(err.Error(), ShouldEqual, "The conditional request failed")
+
+		_, err = c.List(ctx, "")
+		So(err, ShouldEqual, ErrNotSupported)
+	})
+
+	Convey("Should send the current item and then each change when watching", t, func() {
+		// The version is read by the server while the client watches
+		var mu sync.Mutex
+		version := 0
+		watchServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			if version == 0 {
+				w.Write([]byte(`{"action": "get", "item": {"key": "/watched"}}`))
+				return
+			}
+			w.Write([]byte(`{"action": "get", "item": {"key": "/watched", "value": "v` + strconv.Itoa(version) + `", "version": ` + strconv.Itoa(version) + `}}`))
+		}))
+		defer watchServer.Close()
+		watchCtx, cancel := context.WithCancel(ctx)
+		events := NewHTTP(watchServer.URL, config.Options{CfgName: "mycfg"}, nil).Watch(watchCtx, "watched", 10*time.Millisecond)
+		So((<-events).Err, ShouldEqual, ErrNotFound)
+
+		mu.Lock()
+		version = 1
+		mu.Unlock()
+		event := <-events
+		So(event.Err, ShouldBeNil)
+		So(event.Item.String(), ShouldEqual, "v1")
+
+		mu.Lock()
+		version = 2
+		mu.Unlock()
+		So((<-events).Item.Version, ShouldEqual, int64(2))
+
+		cancel()
+		for range events {
+		}
+	})
+}
@@ -0,0 +1,162 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/tmaiaroto/discfg/config"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// httpBackend works through the discfg HTTP API (/{name}/keys/{key})
+type httpBackend struct {
+	baseURL string
+	client  *http.Client
+}
+
+// httpItem is an item in an HTTP API response, values are formatted for JSON (see commands.FormatJSONValue)
+type httpItem struct {
+	Version    int64           `json:"version"`
+	Key        string          `json:"key"`
+	Value      json.RawMessage `json:"value"`
+	Type       string          `json:"type"`
+	TTL        int64           `json:"ttl"`
+	Expiration string          `json:"expiration"`
+	Encrypted  bool            `json:"encrypted"`
+	Sensitive  bool            `json:"sensitive"`
+}
+
+// httpResponse is an HTTP API response, Lambda errors come back as an errorMessage
+type httpResponse struct {
+	Action       string   `json:"action"`
+	Item         httpItem `json:"item"`
+	PrevItem     httpItem `json:"prevItem"`
+	Error        string   `json:"error"`
+	Message      string   `json:"message"`
+	ErrorMessage string   `json:"errorMessage"`
+}
+
+func (b httpBackend) get(ctx context.Context, opts config.Options) (config.ResponseObject, error) {
+	return b.do(ctx, "GET", opts, url.Values{}, nil)
+}
+
+func (b httpBackend) set(ctx context.Context, opts config.Options) (config.ResponseObject, error) {
+	if opts.ConditionalValue != "" {
+		return config.ResponseObject{}, ErrNotSupported
+	}
+	query := url.Values{}
+	if opts.TTL > 0 {
+		query.Set("ttl", strconv.FormatInt(opts.TTL, 10))
+	}
+	if opts.ValueType != "" {
+		query.Set("type", opts.ValueType)
+	}
+	if opts.Sensitive != nil {
+		query.Set("sensitive", strconv.FormatBool(*opts.Sensitive))
+	}
+	return b.do(ctx, "PUT", opts, query, bytes.NewReader(opts.Value))
+}
+
+func (b httpBackend) delete(ctx context.Context, opts config.Options) (config.ResponseObject, error) {
+	return b.do(ctx, "DELETE", opts, url.Values{}, nil)
+}
+
+func (b httpBackend) list(ctx context.Context, opts config.Options) (config.ResponseObject, error) {
+	return config.ResponseObject{}, ErrNotSupported
+}
+
+// do makes a request for a key and converts the response
+func (b httpBackend) do(ctx context.Context, method string, opts config.Options, query url.Values, body io.Reader) (config.ResponseObject, error) {
+	var resp config.ResponseObject
+	if opts.Reveal {
+		query.Set("reveal", "true")
+	}
+	u := strings.TrimSuffix(b.baseURL, "/") + "/" + url.PathEscape(opts.CfgName) + "/keys/" + url.PathEscape(strings.TrimPrefix(opts.Key, "/"))
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return resp, err
+	}
+	if opts.Auth.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+opts.Auth.Token)
+	}
+	res, err := b.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return resp, err
+	}
+	defer res.Body.Close()
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return resp, err
+	}
+
+	var r httpResponse
+	if err := json.Unmarshal(data, &r); err != nil {
+		if res.StatusCode >= 400 {
+			return resp, errors.New(res.Status)
+		}
+		return resp, err
+	}
+	if r.ErrorMessage != "" {
+		return resp, errors.New(r.ErrorMessage)
+	}
+	if res.StatusCode >= 400 && r.Error == "" {
+		return resp, errors.New(res.Status)
+	}
+
+	resp.Action = r.Action
+	resp.Error = r.Error
+	resp.Message = r.Message
+	if resp.Item, err = configItem(r.Item); err != nil {
+		return resp, err
+	}
+	resp.PrevItem, err = configItem(r.PrevItem)
+	return resp, err
+}
+
+// configItem converts an item from an HTTP API response. JSON strings are unquoted (and base64 decoded for binary
+// values), anything else (numbers, bools, objects) is the JSON itself.
+func configItem(item httpItem) (config.Item, error) {
+	i := config.Item{
+		Key:       item.Key,
+		Version:   item.Version,
+		Type:      item.Type,
+		TTL:       item.TTL,
+		Encrypted: item.Encrypted,
+		Sensitive: item.Sensitive,
+	}
+	if item.Expiration != "" {
+		i.Expiration, _ = time.Parse(time.RFC3339Nano, item.Expiration)
+	}
+	if len(item.Value) == 0 || string(item.Value) == "null" {
+		return i, nil
+	}
+	if item.Value[0] != '"' {
+		i.Value = []byte(item.Value)
+		return i, nil
+	}
+	var s string
+	if err := json.Unmarshal(item.Value, &s); err != nil {
+		return i, err
+	}
+	if item.Type == config.ValueTypeBinary {
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return i, err
+		}
+		i.Value = b
+		return i, nil
+	}
+	i.Value = []byte(s)
+	return i, nil
+}
@@ -0,0 +1,39 @@
+package client
+
+import (
+	"context"
+	"github.com/tmaiaroto/discfg/commands"
+	"github.com/tmaiaroto/discfg/config"
+)
+
+// storageBackend works with storage directly through the commands package, so keys are validated, values encrypted
+// and so on just as they are from the CLI.
+type storageBackend struct{}
+
+func (b storageBackend) get(ctx context.Context, opts config.Options) (config.ResponseObject, error) {
+	opts.Context = ctx
+	return b.response(ctx, commands.GetKey(opts))
+}
+
+func (b storageBackend) set(ctx context.Context, opts config.Options) (config.ResponseObject, error) {
+	opts.Context = ctx
+	return b.response(ctx, commands.SetKey(opts))
+}
+
+func (b storageBackend) delete(ctx context.Context, opts config.Options) (config.ResponseObject, error) {
+	opts.Context = ctx
+	return b.response(ctx, commands.DeleteKey(opts))
+}
+
+func (b storageBackend) list(ctx context.Context, opts config.Options) (config.ResponseObject, error) {
+	opts.Context = ctx
+	return b.response(ctx, commands.ListKeys(opts))
+}
+
+// response returns the context's error (rather than the message in the response) should the context be done
+func (b storageBackend) response(ctx context.Context, resp config.ResponseObject) (config.ResponseObject, error) {
+	if err := ctx.Err(); err != nil {
+		return config.ResponseObject{}, err
+	}
+	return resp, nil
+}
@@ -0,0 +1,50 @@
+package commands
+
+import (
+	"github.com/tmaiaroto/discfg/audit"
+	"github.com/tmaiaroto/discfg/config"
+)
+
+// Audit returns a configuration's audit log events matching the filter, oldest first, verifying they haven't been
+// changed. Without a filter, every event is returned and missing events are also caught.
+func Audit(opts config.Options, f audit.Filter) config.ResponseObject {
+	resp := config.ResponseObject{
+		Action: "audit",
+	}
+	if opts.CfgName == "" {
+		resp.Error = MissingCfgNameMsg
+		return resp
+	}
+	if opts.Audit.Sink == "" {
+		resp.Error = "No audit sink set, events are only recorded with one"
+		return resp
+	}
+	events, err := audit.Query(opts, f)
+	if err != nil {
+		resp.Error = err.Error()
+		resp.Message = "Error querying the audit log"
+		return resp
+	}
+	resp.AuditEvents = events
+	if err := audit.Verify(events, f == audit.Filter{}); err != nil {
+		resp.Error = err.Error()
+	}
+	if len(events) == 0 {
+		resp.Message = "No audit events found"
+	}
+	return resp
+}
+
+// recordAudit records a change that was made to the audit log, if there is one. The change was made either way, so
+// failing to record it is noted on the response rather than undoing it.
+func recordAudit(opts config.Options, e config.AuditEvent, resp *config.ResponseObject) {
+	if opts.Audit.Sink == "" {
+		return
+	}
+	if e.Identity == "" {
+		e.Identity = author(opts)
+	}
+	if err := audit.Record(opts, e); err != nil {
+		resp.Error = "The change was made, but couldn't be recorded in the audit log: " + err.Error()
+	}
+}
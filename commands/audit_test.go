@@ -0,0 +1,84 @@
+package commands
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/tmaiaroto/discfg/audit"
+	"github.com/tmaiaroto/discfg/config"
+	"github.com/tmaiaroto/discfg/storage"
+	"github.com/tmaiaroto/discfg/storage/mockdb"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAudit(t *testing.T) {
+	storage.RegisterShipper("mock", mockdb.MockShipper{})
+	dir, _ := ioutil.TempDir("", "discfg-audit")
+	defer os.RemoveAll(dir)
+	opts := config.Options{StorageInterfaceName: "mock", CfgName: "mockcfg", Version: "0.0.0", Author: "tester"}
+	opts.Audit.Sink = "file"
+	opts.Audit.File = filepath.Join(dir, "audit.jsonl")
+	opts.Audit.Source = audit.SourceCLI
+	root := mockdb.MockCfg["mockcfg"]["/"]
+	defer func() {
+		mockdb.MockCfg["mockcfg"]["/"] = root
+		delete(mockdb.MockCfg["mockcfg"], "audited")
+		delete(mockdb.MockCfg["mockcfg"], "counted")
+		delete(mockdb.MockCfg, "mockcfg_audited")
+	}()
+
+	Convey("Changes should be recorded in the audit log", t, func() {
+		opts.Key = "audited"
+		opts.Value = []byte("one")
+		So(SetKey(opts).Error, ShouldEqual, "")
+		opts.Value = []byte("two")
+		So(SetKey(opts).Error, ShouldEqual, "")
+		So(DeleteKey(opts).Error, ShouldEqual, "")
+
+		r := Audit(opts, audit.Filter{})
+		So(r.Action, ShouldEqual, "audit")
+		So(r.Error, ShouldEqual, "")
+		So(len(r.AuditEvents), ShouldEqual, 3)
+		So(r.AuditEvents[1].Action, ShouldEqual, "set")
+		So(r.AuditEvents[1].Identity, ShouldEqual, "tester")
+		So(r.AuditEvents[1].NewVersion, ShouldEqual, r.AuditEvents[1].OldVersion+1)
+		So(r.AuditEvents[1].ValueHash, ShouldEqual, audit.Hash([]byte("two")))
+		So(r.AuditEvents[2].Action, ShouldEqual, "delete")
+		So(r.AuditEvents[2].ValueHash, ShouldEqual, audit.Hash([]byte("two")))
+		So(auditEventLine(r.AuditEvents[2]), ShouldContainSubstring, "delete audited v2 -> v3 by tester (cli)")
+	})
+
+	Convey("Every other change should be recorded too", t, func() {
+		opts.Key = "counted"
+		So(IncrementKey(opts, 2).Error, ShouldEqual, "")
+		opts.TTL = 60
+		So(TouchKey(opts).Error, ShouldEqual, "")
+		opts.TTL = 0
+		mockdb.MockCfg["mockcfg"]["audited"] = config.Item{Key: "audited", Value: []byte("gone"), Version: 1, TTL: 1, Expiration: time.Now().Add(-time.Second)}
+		So(GC(opts).Error, ShouldEqual, "")
+		So(SetCfgMeta(opts, []byte(`{"owner": "ops"}`)).Error, ShouldEqual, "")
+		So(CloneCfg(opts, "mockcfg_audited", 0).Error, ShouldEqual, "")
+
+		events := Audit(opts, audit.Filter{}).AuditEvents
+		actions := []string{}
+		for _, e := range events[3:] {
+			actions = append(actions, e.Action)
+		}
+		So(actions, ShouldResemble, []string{"incr", "touch", "gc", "set cfg meta"})
+		So(events[3].ValueHash, ShouldEqual, audit.Hash([]byte("2")))
+		So(events[5].Key, ShouldEqual, "audited")
+		So(events[5].ValueHash, ShouldEqual, audit.Hash([]byte("gone")))
+
+		clone := opts
+		clone.CfgName = "mockcfg_audited"
+		So(Audit(clone, audit.Filter{}).AuditEvents[0].Action, ShouldEqual, "clone cfg")
+	})
+
+	Convey("Querying the audit log needs a sink", t, func() {
+		none := opts
+		none.Audit.Sink = ""
+		So(Audit(none, audit.Filter{}).Error, ShouldNotBeEmpty)
+	})
+}
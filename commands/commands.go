@@ -3,10 +3,16 @@ package commands
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/tmaiaroto/discfg/audit"
 	"github.com/tmaiaroto/discfg/config"
+	"github.com/tmaiaroto/discfg/encryption"
 	"github.com/tmaiaroto/discfg/storage"
 	"io/ioutil"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -22,6 +28,7 @@ func CreateCfg(opts config.Options, settings map[string]interface{}) config.Resp
 			resp.Message = "Error creating the configuration"
 		} else {
 			resp.Message = "Successfully created the configuration"
+			recordAudit(opts, config.AuditEvent{Action: resp.Action, ValueHash: settingsHash(settings)}, &resp)
 		}
 	} else {
 		resp.Error = NotEnoughArgsMsg
@@ -31,6 +38,34 @@ func CreateCfg(opts config.Options, settings map[string]interface{}) config.Resp
 	return resp
 }
 
+// ListCfgs lists the configurations in storage
+func ListCfgs(opts config.Options) config.ResponseObject {
+	resp := config.ResponseObject{
+		Action: "list cfg",
+	}
+	cfgs, err := storage.ListConfigs(opts)
+	if err != nil {
+		resp.Error = err.Error()
+		resp.Message = "Error listing configurations"
+		return resp
+	}
+	resp.Cfgs = []config.CfgSummary{}
+	for _, cfg := range cfgs {
+		if cfg.ModifiedNanoseconds > 0 {
+			cfg.Modified = cfg.ModifiedNanoseconds / int64(time.Second)
+			cfg.ModifiedParsed = time.Unix(0, cfg.ModifiedNanoseconds).Format(time.RFC3339)
+		}
+		resp.Cfgs = append(resp.Cfgs, cfg)
+	}
+	sort.Slice(resp.Cfgs, func(i, j int) bool {
+		return resp.Cfgs[i].Name < resp.Cfgs[j].Name
+	})
+	if len(resp.Cfgs) == 0 {
+		resp.Message = "No configurations found"
+	}
+	return resp
+}
+
 // DeleteCfg deletes a configuration
 func DeleteCfg(opts config.Options) config.ResponseObject {
 	resp := config.ResponseObject{
@@ -40,9 +75,11 @@ func DeleteCfg(opts config.Options) config.ResponseObject {
 		_, err := storage.DeleteConfig(opts)
 		if err != nil {
 			resp.Error = err.Error()
+			resp.ErrorCode = errorCode(err)
 			resp.Message = "Error deleting the configuration"
 		} else {
 			resp.Message = "Successfully deleted the configuration"
+			recordAudit(opts, config.AuditEvent{Action: resp.Action}, &resp)
 		}
 	} else {
 		resp.Error = NotEnoughArgsMsg
@@ -66,6 +103,7 @@ func UpdateCfg(opts config.Options, settings map[string]interface{}) config.Resp
 			resp.Message = "Error updating the configuration"
 		} else {
 			resp.Message = "Successfully updated the configuration"
+			recordAudit(opts, config.AuditEvent{Action: resp.Action, ValueHash: settingsHash(settings)}, &resp)
 		}
 	} else {
 		resp.Error = NotEnoughArgsMsg
@@ -74,6 +112,255 @@ func UpdateCfg(opts config.Options, settings map[string]interface{}) config.Resp
 	return resp
 }
 
+// MigrateCfg copies a configuration to another storage engine, such as from its own DynamoDB table into a
+// shared table, optionally deleting it from the original storage engine once copied. To delete it, the original is
+// frozen while it's copied (so no change is lost) and the copy is checked first, it's unfrozen again on any error.
+func MigrateCfg(opts config.Options, to string, deleteSource bool) config.ResponseObject {
+	resp := config.ResponseObject{
+		Action: "migrate cfg",
+	}
+	if len(opts.CfgName) == 0 || len(to) == 0 {
+		resp.Error = NotEnoughArgsMsg
+		return resp
+	}
+	opts.ConsistentRead = true
+	dstOpts := opts
+	dstOpts.StorageInterfaceName = to
+
+	// An already frozen original stays frozen, as does its copy
+	var err error
+	frozen := false
+	if deleteSource {
+		rootOpts := opts
+		rootOpts.Key = "/"
+		root, getErr := storage.Get(rootOpts)
+		err = getErr
+		if err == nil && !root.Frozen {
+			err = storage.Freeze(opts, true)
+			if err == nil {
+				frozen = true
+				defer func() {
+					if resp.Error != "" && frozen {
+						storage.Freeze(opts, false)
+					}
+				}()
+			}
+		}
+	}
+	n := 0
+	if err == nil {
+		n, err = storage.Migrate(opts, to)
+	}
+	if err == nil && deleteSource {
+		err = verifyCopy(opts, dstOpts)
+	}
+	if err != nil {
+		resp.Error = err.Error()
+		resp.ErrorCode = errorCode(err)
+		resp.Message = "Error migrating the configuration"
+		return resp
+	}
+	resp.Message = "Successfully migrated " + strconv.Itoa(n) + " items to " + to
+	recordAudit(opts, config.AuditEvent{Action: resp.Action}, &resp)
+	if deleteSource {
+		deleteOpts := opts
+		deleteOpts.DeleteFrozen = true
+		if _, err := storage.DeleteConfig(deleteOpts); err != nil {
+			resp.Error = err.Error()
+			resp.Message += ", but there was an error deleting the original"
+			return resp
+		}
+		recordAudit(opts, config.AuditEvent{Action: "delete original cfg"}, &resp)
+		// The copy was frozen along with the original
+		if frozen {
+			frozen = false
+			if err := storage.Freeze(dstOpts, false); err != nil {
+				resp.Error = err.Error()
+				resp.Message += ", but there was an error unfreezing the copy"
+				return resp
+			}
+		}
+	}
+	return resp
+}
+
+// FreezeCfg freezes (or unfreezes) a configuration. A frozen configuration's keys can't be set or deleted, nor can
+// it be deleted, until it's unfrozen.
+func FreezeCfg(opts config.Options, frozen bool) config.ResponseObject {
+	resp := config.ResponseObject{
+		Action: "freeze cfg",
+	}
+	if !frozen {
+		resp.Action = "unfreeze cfg"
+	}
+	if opts.CfgName == "" {
+		resp.Error = MissingCfgNameMsg
+		return resp
+	}
+	if err := storage.Freeze(opts, frozen); err != nil {
+		resp.Error = err.Error()
+		resp.Message = "Error freezing the configuration"
+		if !frozen {
+			resp.Message = "Error unfreezing the configuration"
+		}
+		return resp
+	}
+	resp.CfgFrozen = frozen
+	resp.Message = "Successfully froze the configuration"
+	if !frozen {
+		resp.Message = "Successfully unfroze the configuration"
+	}
+	recordAudit(opts, config.AuditEvent{Action: resp.Action}, &resp)
+	return resp
+}
+
+// settingsHash returns the hash of a config's settings for the audit log, empty for none
+func settingsHash(settings map[string]interface{}) string {
+	if len(settings) == 0 {
+		return ""
+	}
+	// Maps are marshaled with sorted keys, so the same settings always hash the same
+	b, _ := json.Marshal(settings)
+	return audit.Hash(b)
+}
+
+// CloneCfg copies a configuration to a new one, named dst, created with the same settings. Every item is copied
+// as stored, including the root key's config version (encrypted values stay bound to the original config name, so
+// they don't decrypt in the copy). With a wait timeout, the copy waits for the new configuration to be ACTIVE first
+// (0 copies right away).
+func CloneCfg(opts config.Options, dst string, waitTimeout time.Duration) config.ResponseObject {
+	resp := config.ResponseObject{
+		Action: "clone cfg",
+	}
+	n, err := cloneCfg(opts, dst, waitTimeout)
+	if err != nil {
+		resp.Error = err.Error()
+		resp.Message = "Error cloning the configuration"
+		return resp
+	}
+	resp.Message = "Successfully cloned " + strconv.Itoa(n) + " items to " + dst
+	dstOpts := opts
+	dstOpts.CfgName = dst
+	recordAudit(dstOpts, config.AuditEvent{Action: resp.Action}, &resp)
+	return resp
+}
+
+// RenameCfg clones a configuration to dst, deleting the original once the copy is verified to have every item
+// at the same version. The original is frozen while it's copied, so no change to it is lost. Should renaming fail,
+// it's unfrozen again.
+func RenameCfg(opts config.Options, dst string, waitTimeout time.Duration) config.ResponseObject {
+	resp := config.ResponseObject{
+		Action: "rename cfg",
+	}
+	// A frozen original is left alone, it's frozen for a reason
+	var err error
+	rootOpts := opts
+	rootOpts.Key = "/"
+	if len(opts.CfgName) == 0 || len(dst) == 0 {
+		err = errors.New(NotEnoughArgsMsg)
+	} else if root, getErr := storage.Get(rootOpts); getErr == nil && root.Frozen {
+		err = config.ErrFrozen
+	}
+	if err == nil {
+		err = storage.Freeze(opts, true)
+		if err == nil {
+			defer func() {
+				if resp.Error != "" {
+					storage.Freeze(opts, false)
+				}
+			}()
+		}
+	}
+	if err == nil {
+		opts.ConsistentRead = true
+		_, err = cloneCfg(opts, dst, waitTimeout)
+	}
+	if err == nil {
+		dstOpts := opts
+		dstOpts.CfgName = dst
+		err = verifyCopy(opts, dstOpts)
+	}
+	if err == nil {
+		deleteOpts := opts
+		deleteOpts.DeleteFrozen = true
+		_, err = storage.DeleteConfig(deleteOpts)
+	}
+	if err != nil {
+		resp.Error = err.Error()
+		resp.ErrorCode = errorCode(err)
+		resp.Message = "Error renaming the configuration"
+		return resp
+	}
+	resp.Message = "Successfully renamed the configuration to " + dst
+	// Recorded for both names, the original's log ends here and the copy's starts
+	recordAudit(opts, config.AuditEvent{Action: resp.Action}, &resp)
+	dstOpts := opts
+	dstOpts.CfgName = dst
+	recordAudit(dstOpts, config.AuditEvent{Action: resp.Action}, &resp)
+	return resp
+}
+
+// cloneCfg creates dst with the source config's settings and copies every item to it
+func cloneCfg(opts config.Options, dst string, waitTimeout time.Duration) (int, error) {
+	if len(opts.CfgName) == 0 || len(dst) == 0 {
+		return 0, errors.New(NotEnoughArgsMsg)
+	}
+	settings, err := cfgSettings(opts)
+	if err != nil {
+		return 0, err
+	}
+	dstOpts := opts
+	dstOpts.CfgName = dst
+	if _, err := storage.CreateConfig(dstOpts, settings); err != nil {
+		return 0, err
+	}
+	if waitTimeout > 0 {
+		if _, err := WaitForCfg(dstOpts, false, waitTimeout, nil); err != nil {
+			return 0, err
+		}
+	}
+	return storage.CopyItems(opts, dstOpts)
+}
+
+// cfgSettings returns the settings a config was created (or last updated) with, as the storage engine reports them
+// under "Settings" in its options. They go through JSON so they're in the same form as settings from the CLI.
+func cfgSettings(opts config.Options) (map[string]interface{}, error) {
+	settings := map[string]interface{}{}
+	s, ok := storage.Options(opts)["Settings"]
+	if !ok {
+		return settings, nil
+	}
+	b, err := json.Marshal(s)
+	if err == nil {
+		err = json.Unmarshal(b, &settings)
+	}
+	return settings, err
+}
+
+// verifyCopy checks that a copied config has every item of the original, at the same version
+func verifyCopy(from config.Options, to config.Options) error {
+	from.Key, to.Key = "", ""
+	from.ConsistentRead, to.ConsistentRead = true, true
+	original, err := storage.List(from)
+	if err != nil {
+		return err
+	}
+	copied, err := storage.List(to)
+	if err != nil {
+		return err
+	}
+	versions := map[string]int64{}
+	for _, item := range copied {
+		versions[item.Key] = item.Version
+	}
+	for _, item := range original {
+		if v, ok := versions[item.Key]; !ok || v != item.Version {
+			return errors.New("The copy of " + from.CfgName + " in " + to.CfgName + " doesn't match for key " + item.Key)
+		}
+	}
+	return nil
+}
+
 // Use sets a discfg configuration to use for all future commands until unset (it is optional, but conveniently saves a CLI argument - kinda like MongoDB's use)
 func Use(opts config.Options) config.ResponseObject {
 	resp := config.ResponseObject{
@@ -125,25 +412,84 @@ func SetKey(opts config.Options) config.ResponseObject {
 		return resp
 	}
 
-	key, keyErr := formatKeyName(opts.Key)
+	key, keyErr := formatChangedKeyName(opts.Key)
 	if keyErr == nil {
 		opts.Key = key
+		if err := validateValueType(opts.ValueType, opts.Value); err != nil {
+			resp.Error = err.Error()
+			return resp
+		}
+		// Validate against any JSON Schemas for the key (note: finding them means listing the schema keys on each set)
+		if err := validateKeyValue(opts, key, opts.Value); err != nil {
+			resp.Error = err.Error()
+			resp.Message = "Error validating key value"
+			return resp
+		}
+
+		plaintext := opts.Value
+		if opts.Encrypt {
+			encrypted, err := encryption.Encrypt(opts, opts.Value)
+			if err != nil {
+				resp.Error = err.Error()
+				resp.Message = "Error encrypting key value"
+				return resp
+			}
+			opts.Value = encrypted
+		}
+
+		opts.Author = author(opts)
+		modified := time.Now()
 		storageResponse, err := storage.Update(opts)
 		if err != nil {
 			resp.Error = err.Error()
+			resp.ErrorCode = errorCode(err)
 			resp.Message = "Error updating key value"
 		} else {
 			resp.Item.Key = key
 			resp.Item.Value = opts.Value
 			resp.Item.Version = 1
+			resp.Item.Encrypted = opts.Encrypt
+			resp.Item.Sensitive = storageResponse.Sensitive
+			if opts.Sensitive != nil {
+				resp.Item.Sensitive = *opts.Sensitive
+			}
+			resp.Item.Type = opts.ValueType
+			resp.Item.Modified = modified.UnixNano()
+			resp.Item.ModifiedBy = opts.Author
+			// The description and tags stay as they were unless given
+			resp.Item.Description = storageResponse.Description
+			if opts.Description != "" {
+				resp.Item.Description = opts.Description
+			}
+			resp.Item.Tags = storageResponse.Tags
+			if len(opts.Tags) > 0 {
+				resp.Item.Tags = opts.Tags
+			}
+			if opts.TTL > 0 {
+				resp.Item.TTL = opts.TTL
+				resp.Item.Expiration = time.Now().Add(time.Duration(opts.TTL) * time.Second)
+			}
+			// Encrypted values are never returned in plaintext unless asked for.
+			if opts.Encrypt && opts.Decrypt {
+				resp.Item.Value = plaintext
+			}
 
 			// Only set PrevItem if there was a previous value
 			if storageResponse.Value != nil {
-				resp.PrevItem = storageResponse
+				// The previous value stays encrypted if it can't be decrypted, the update itself still succeeded.
+				resp.PrevItem, _ = decryptItem(opts, storageResponse)
 				resp.PrevItem.Key = key
 				// Update the current item's value if there was a previous version
 				resp.Item.Version = resp.PrevItem.Version + 1
 			}
+			recordAudit(opts, config.AuditEvent{
+				Action:     resp.Action,
+				Key:        key,
+				OldVersion: resp.PrevItem.Version,
+				NewVersion: resp.Item.Version,
+				ValueHash:  audit.Hash(opts.Value),
+				Identity:   opts.Author,
+			}, &resp)
 		}
 	} else {
 		resp.Error = keyErr.Error()
@@ -163,7 +509,11 @@ func GetKey(opts config.Options) config.ResponseObject {
 		if err != nil {
 			resp.Error = err.Error()
 		} else {
-			resp.Item = storageResponse
+			resp.Item, err = decryptItem(opts, storageResponse)
+			if err != nil {
+				resp.Error = err.Error()
+				resp.Message = "Error decrypting key value"
+			}
 		}
 	} else {
 		resp.Error = keyErr.Error()
@@ -171,18 +521,186 @@ func GetKey(opts config.Options) config.ResponseObject {
 	return resp
 }
 
+// TouchKey resets the TTL for a key without changing its value (or version), useful for leases and heartbeats
+func TouchKey(opts config.Options) config.ResponseObject {
+	resp := config.ResponseObject{
+		Action: "touch",
+	}
+	if opts.CfgName == "" {
+		resp.Error = MissingCfgNameMsg
+		return resp
+	}
+	key, keyErr := formatChangedKeyName(opts.Key)
+	if keyErr != nil {
+		resp.Error = keyErr.Error()
+		return resp
+	}
+	opts.Key = key
+
+	storageResponse, err := storage.Touch(opts)
+	if err != nil {
+		resp.Error = err.Error()
+		resp.ErrorCode = errorCode(err)
+		resp.Message = "Error touching key"
+		return resp
+	}
+	// The value stays encrypted if it can't be decrypted, the touch itself still succeeded.
+	resp.Item, _ = decryptItem(opts, storageResponse)
+	resp.Item.Key = key
+	recordAudit(opts, config.AuditEvent{
+		Action:     resp.Action,
+		Key:        key,
+		OldVersion: storageResponse.Version,
+		NewVersion: storageResponse.Version,
+	}, &resp)
+	return resp
+}
+
+// IncrementKey atomically adds delta to a key's numeric value, the key's new value is returned. Keys that don't
+// exist start at 0. Only number values (see config.ValueTypeNumber) can be incremented.
+func IncrementKey(opts config.Options, delta float64) config.ResponseObject {
+	resp := config.ResponseObject{
+		Action: "incr",
+	}
+	if opts.CfgName == "" {
+		resp.Error = MissingCfgNameMsg
+		return resp
+	}
+	key, keyErr := formatChangedKeyName(opts.Key)
+	if keyErr != nil {
+		resp.Error = keyErr.Error()
+		return resp
+	}
+	opts.Key = key
+	opts.Author = author(opts)
+
+	storageResponse, err := storage.Increment(opts, delta)
+	if err != nil {
+		resp.Error = err.Error()
+		resp.ErrorCode = errorCode(err)
+		resp.Message = "Error incrementing key, only number values can be incremented"
+		if resp.ErrorCode != 0 {
+			resp.Message = "Error incrementing key"
+		}
+		return resp
+	}
+	resp.Item = storageResponse
+	resp.Item.Key = key
+	value, _ := storageResponse.Value.([]byte)
+	recordAudit(opts, config.AuditEvent{
+		Action:     resp.Action,
+		Key:        key,
+		OldVersion: storageResponse.Version - 1,
+		NewVersion: storageResponse.Version,
+		ValueHash:  audit.Hash(value),
+		Identity:   opts.Author,
+	}, &resp)
+	return resp
+}
+
+// ListKeys lists the keys in a configuration that begin with a given prefix (opts.Key), an empty prefix lists all keys.
+// With tags in the options, only keys with all of those tags are listed.
+func ListKeys(opts config.Options) config.ResponseObject {
+	resp := config.ResponseObject{
+		Action: "ls",
+	}
+	if opts.CfgName == "" {
+		resp.Error = MissingCfgNameMsg
+		return resp
+	}
+
+	storageResponse, err := storage.List(opts)
+	if err != nil {
+		resp.Error = err.Error()
+		return resp
+	}
+
+	now := time.Now()
+	resp.Items = []config.Item{}
+	for _, item := range storageResponse {
+		// The root key "/" holds information about the config itself, it isn't a key users set.
+		// Expired keys may also still be in storage, but they're as good as gone.
+		if item.Key == "/" || isExpired(item, now) || !hasTags(item, opts.Tags) {
+			continue
+		}
+		resp.Items = append(resp.Items, item)
+	}
+	// Not every storage engine will return keys in order (DynamoDB scans certainly won't).
+	sort.Slice(resp.Items, func(i, j int) bool {
+		return resp.Items[i].Key < resp.Items[j].Key
+	})
+	return resp
+}
+
+// GC deletes expired keys from a configuration. Storage engines may remove expired keys on their own (DynamoDB does,
+// eventually), but until then they still take up space. This only uses the Shipper interface, so it works with any storage.
+func GC(opts config.Options) config.ResponseObject {
+	resp := config.ResponseObject{
+		Action: "gc",
+	}
+	if opts.CfgName == "" {
+		resp.Error = MissingCfgNameMsg
+		return resp
+	}
+
+	opts.Key = ""
+	opts.ConditionalValue = ""
+	storageResponse, err := storage.List(opts)
+	if err != nil {
+		resp.Error = err.Error()
+		return resp
+	}
+
+	now := time.Now()
+	var errs []string
+	resp.Items = []config.Item{}
+	for _, item := range storageResponse {
+		if item.Key == "/" || !isExpired(item, now) {
+			continue
+		}
+		// The key may have been set again since it was listed
+		opts.Key = item.Key
+		opts.ConditionalExpired = true
+		deleted, err := storage.Delete(opts)
+		if err != nil {
+			errs = append(errs, item.Key+": "+err.Error())
+			continue
+		}
+		resp.Items = append(resp.Items, config.Item{Key: item.Key, TTL: item.TTL, Expiration: item.Expiration})
+		value, _ := deleted.Value.([]byte)
+		recorded := config.ResponseObject{}
+		recordAudit(opts, config.AuditEvent{
+			Action:     resp.Action,
+			Key:        item.Key,
+			OldVersion: deleted.Version,
+			NewVersion: deleted.Version + 1,
+			ValueHash:  audit.Hash(value),
+		}, &recorded)
+		if recorded.Error != "" {
+			errs = append(errs, item.Key+": "+recorded.Error)
+		}
+	}
+
+	resp.Message = "Deleted " + strconv.Itoa(len(resp.Items)) + " expired keys"
+	if len(errs) > 0 {
+		resp.Error = strings.Join(errs, "\n")
+	}
+	return resp
+}
+
 // DeleteKey deletes a key from a configuration
 func DeleteKey(opts config.Options) config.ResponseObject {
 	resp := config.ResponseObject{
 		Action: "delete",
 	}
-	key, keyErr := formatKeyName(opts.Key)
+	key, keyErr := formatChangedKeyName(opts.Key)
 	if keyErr == nil {
 		opts.Key = key
 		storageResponse, err := storage.Delete(opts)
 		if err != nil {
 			resp.Error = err.Error()
-			resp.Message = "Error getting key value"
+			resp.ErrorCode = errorCode(err)
+			resp.Message = "Error deleting key value"
 		} else {
 			resp.Item = storageResponse
 			resp.Item.Key = opts.Key
@@ -192,13 +710,43 @@ func DeleteKey(opts config.Options) config.ResponseObject {
 			resp.PrevItem.Version = storageResponse.Version
 			resp.PrevItem.Value = storageResponse.Value
 			// log.Println(storageResponse)
+			deleted, _ := storageResponse.Value.([]byte)
+			recordAudit(opts, config.AuditEvent{
+				Action:     resp.Action,
+				Key:        key,
+				OldVersion: resp.PrevItem.Version,
+				NewVersion: resp.Item.Version,
+				ValueHash:  audit.Hash(deleted),
+			}, &resp)
 		}
-	} else {
+	} else if keyErr.Error() == MissingKeyNameMsg {
 		resp.Error = NotEnoughArgsMsg
+	} else {
+		resp.Error = keyErr.Error()
 	}
 	return resp
 }
 
+// decryptItem decrypts an item's value if it is encrypted and decryption was asked for. Otherwise the value is left
+// encrypted, so plaintext is never returned by accident. The item stays flagged as encrypted either way.
+func decryptItem(opts config.Options, item config.Item) (config.Item, error) {
+	if !item.Encrypted || !opts.Decrypt {
+		return item, nil
+	}
+	// Values only decrypt for the key they were set for
+	if item.Key != "" {
+		opts.Key = item.Key
+	}
+	if b, ok := item.Value.([]byte); ok {
+		plaintext, err := encryption.Decrypt(opts, b)
+		if err != nil {
+			return item, err
+		}
+		item.Value = plaintext
+	}
+	return item, nil
+}
+
 // Info about the configuration including global version/state and modified time
 func Info(opts config.Options) config.ResponseObject {
 	resp := config.ResponseObject{
@@ -213,11 +761,12 @@ func Info(opts config.Options) config.ResponseObject {
 		if err != nil {
 			resp.Error = err.Error()
 		} else {
-			// Debating putting the item value on here... (allowing users to store values on the config or "root")
-			// resp.Item = storageResponse
+			// The root key's value holds the config's metadata (see SetCfgMeta)
+			resp.CfgMeta = config.ParseCfgMeta(storageResponse.Value)
 			// Set the configuration version and modified time on the response
 			// Item.CfgVersion and Item.CfgModifiedNanoseconds are not included in the JSON output
 			resp.CfgVersion = storageResponse.CfgVersion
+			resp.CfgFrozen = storageResponse.Frozen
 			resp.CfgModified = 0
 			resp.CfgModifiedNanoseconds = storageResponse.CfgModifiedNanoseconds
 			// Modified in seconds
@@ -230,6 +779,8 @@ func Info(opts config.Options) config.ResponseObject {
 			resp.CfgStorage.InterfaceName = opts.StorageInterfaceName
 			resp.CfgStorage.Name = storage.Name(opts)
 			resp.CfgStorage.Options = storage.Options(opts)
+			// Not knowing who credentials belong to shouldn't fail info (it's the only call needing STS access)
+			resp.CfgStorage.Identity, _ = storage.Identity(opts)
 
 			// Get the status (only applicable for some storage interfaces, such as DynamoDB)
 			resp.CfgState, err = storage.ConfigState(opts)
@@ -247,6 +798,18 @@ func Info(opts config.Options) config.ResponseObject {
 				buffer.WriteString(strconv.FormatInt(resp.CfgVersion, 10))
 				buffer.WriteString(" last modified ")
 				buffer.WriteString(modified.Format(time.RFC1123))
+				if resp.CfgStorage.Identity != "" {
+					buffer.WriteString(" (accessed as ")
+					buffer.WriteString(resp.CfgStorage.Identity)
+					buffer.WriteString(")")
+				}
+				if resp.CfgFrozen {
+					buffer.WriteString(", frozen")
+				}
+				if resp.CfgMeta != nil && resp.CfgMeta.String() != "" {
+					buffer.WriteString(", ")
+					buffer.WriteString(resp.CfgMeta.String())
+				}
 				resp.Message = buffer.String()
 				buffer.Reset()
 			}
@@ -9,6 +9,7 @@ import (
 	"log"
 	"os"
 	"testing"
+	"time"
 )
 
 func TestCreateCfg(t *testing.T) {
@@ -67,6 +68,143 @@ func TestSetKey(t *testing.T) {
 		So(r.Action, ShouldEqual, "set")
 		So(r.Error, ShouldEqual, MissingCfgNameMsg)
 	})
+
+	Convey("Should return a ResponseObject with an Error message for the root key", t, func() {
+		storage.RegisterShipper("mock", mockdb.MockShipper{})
+		var opts = config.Options{StorageInterfaceName: "mock", Version: "0.0.0", CfgName: "mockcfg", Value: []byte("test"), Key: "/"}
+		So(SetKey(opts).Error, ShouldEqual, RootKeyMsg)
+		opts.Key = "//"
+		So(SetKey(opts).Error, ShouldEqual, RootKeyMsg)
+		So(DeleteKey(opts).Error, ShouldEqual, RootKeyMsg)
+		So(TouchKey(opts).Error, ShouldEqual, RootKeyMsg)
+		So(IncrementKey(opts, 1).Error, ShouldEqual, RootKeyMsg)
+		So(string(mockdb.MockCfg["mockcfg"]["/"].Value.([]byte)), ShouldEqual, "Mock configuration")
+	})
+
+	Convey("Should return a ResponseObject with an Error message if the value is not of its type", t, func() {
+		storage.RegisterShipper("mock", mockdb.MockShipper{})
+		var opts = config.Options{StorageInterfaceName: "mock", Version: "0.0.0", CfgName: "mockcfg", Value: []byte("test"), Key: "test", ValueType: config.ValueTypeNumber}
+		r := SetKey(opts)
+		So(r.Error, ShouldEqual, ValueNotOfTypeMsg+config.ValueTypeNumber)
+
+		opts.ValueType = "int"
+		r = SetKey(opts)
+		So(r.Error, ShouldEqual, InvalidValueTypeMsg)
+	})
+
+	Convey("Should record who set the key and when, along with its description and tags", t, func() {
+		storage.RegisterShipper("mock", mockdb.MockShipper{})
+		root := mockdb.MockCfg["mockcfg"]["/"]
+		defer func() {
+			mockdb.MockCfg["mockcfg"]["/"] = root
+			delete(mockdb.MockCfg["mockcfg"], "described")
+		}()
+		var opts = config.Options{StorageInterfaceName: "mock", CfgName: "mockcfg", Key: "described", Value: []byte("a"),
+			Author: "ops", Description: "A described key", Tags: map[string]string{"env": "prod"}}
+		before := time.Now().UnixNano()
+		r := SetKey(opts)
+		So(r.Error, ShouldEqual, "")
+		So(r.Item.ModifiedBy, ShouldEqual, "ops")
+		So(r.Item.Modified, ShouldBeGreaterThanOrEqualTo, before)
+
+		// Setting it again without a description or tags keeps them
+		opts.Author, opts.Description, opts.Tags = "", "", nil
+		r = SetKey(opts)
+		So(r.Item.Description, ShouldEqual, "A described key")
+		So(r.Item.ModifiedBy, ShouldEqual, "")
+
+		r = GetKey(config.Options{StorageInterfaceName: "mock", CfgName: "mockcfg", Key: "described"})
+		So(r.Item.Tags, ShouldResemble, map[string]string{"env": "prod"})
+		So(r.Item.Modified, ShouldBeGreaterThanOrEqualTo, before)
+
+		r = ListKeys(config.Options{StorageInterfaceName: "mock", CfgName: "mockcfg", Tags: map[string]string{"env": "prod"}})
+		So(len(r.Items), ShouldEqual, 1)
+		So(r.Items[0].Key, ShouldEqual, "described")
+		r = ListKeys(config.Options{StorageInterfaceName: "mock", CfgName: "mockcfg", Tags: map[string]string{"env": "dev"}})
+		So(r.Items, ShouldBeEmpty)
+	})
+
+	Convey("Should keep a sensitive key sensitive when it's set again without the flag", t, func() {
+		storage.RegisterShipper("mock", mockdb.MockShipper{})
+		root := mockdb.MockCfg["mockcfg"]["/"]
+		defer func() {
+			mockdb.MockCfg["mockcfg"]["/"] = root
+			delete(mockdb.MockCfg["mockcfg"], "apikey")
+		}()
+		sensitive := true
+		var opts = config.Options{StorageInterfaceName: "mock", CfgName: "mockcfg", Key: "apikey", Value: []byte("abc123"), Sensitive: &sensitive}
+		r := SetKey(opts)
+		So(r.Error, ShouldEqual, "")
+		So(r.Item.Sensitive, ShouldBeTrue)
+
+		opts.Sensitive = nil
+		opts.Value = []byte("xyz789")
+		r = SetKey(opts)
+		So(r.Item.Sensitive, ShouldBeTrue)
+		r = GetKey(config.Options{StorageInterfaceName: "mock", CfgName: "mockcfg", Key: "apikey"})
+		So(r.Item.Sensitive, ShouldBeTrue)
+
+		// Only unmarked when asked to
+		sensitive = false
+		opts.Sensitive = &sensitive
+		r = SetKey(opts)
+		So(r.Item.Sensitive, ShouldBeFalse)
+	})
+}
+
+func TestEncryptedKey(t *testing.T) {
+	keyFile, _ := ioutil.TempFile("", "discfg")
+	_, _ = keyFile.Write([]byte("0123456789abcdef0123456789abcdef"))
+	_ = keyFile.Close()
+	defer os.Remove(keyFile.Name())
+	// Setting a key advances the mock config version, which other tests rely on.
+	root := mockdb.MockCfg["mockcfg"]["/"]
+	defer func() {
+		mockdb.MockCfg["mockcfg"]["/"] = root
+		delete(mockdb.MockCfg["mockcfg"], "secret")
+	}()
+
+	var opts = config.Options{StorageInterfaceName: "mock", Version: "0.0.0", CfgName: "mockcfg", Key: "secret", Value: []byte("api key"), Encrypt: true}
+	opts.Encryption.Provider = "local"
+	opts.Encryption.KeyFile = keyFile.Name()
+
+	Convey("Should store an encrypted value and never return the plaintext unless asked", t, func() {
+		storage.RegisterShipper("mock", mockdb.MockShipper{})
+		r := SetKey(opts)
+		So(r.Error, ShouldEqual, "")
+		So(r.Item.Encrypted, ShouldBeTrue)
+		So(string(r.Item.Value.([]byte)), ShouldNotContainSubstring, "api key")
+		So(string(mockdb.MockCfg["mockcfg"]["secret"].Value.([]byte)), ShouldNotContainSubstring, "api key")
+
+		getOpts := opts
+		getOpts.Encrypt = false
+		r = GetKey(getOpts)
+		So(r.Item.Encrypted, ShouldBeTrue)
+		So(string(r.Item.Value.([]byte)), ShouldNotContainSubstring, "api key")
+	})
+
+	Convey("Should return the plaintext value when decrypting", t, func() {
+		storage.RegisterShipper("mock", mockdb.MockShipper{})
+		getOpts := opts
+		getOpts.Encrypt = false
+		getOpts.Decrypt = true
+		r := GetKey(getOpts)
+		So(r.Error, ShouldEqual, "")
+		So(string(r.Item.Value.([]byte)), ShouldEqual, "api key")
+	})
+
+	Convey("Should not decrypt a value copied to another key", t, func() {
+		defer delete(mockdb.MockCfg["mockcfg"], "swapped")
+		swapped := mockdb.MockCfg["mockcfg"]["secret"]
+		swapped.Key = "swapped"
+		mockdb.MockCfg["mockcfg"]["swapped"] = swapped
+		getOpts := opts
+		getOpts.Key = "swapped"
+		getOpts.Encrypt = false
+		getOpts.Decrypt = true
+		r := GetKey(getOpts)
+		So(r.Error, ShouldNotBeEmpty)
+	})
 }
 
 func TestGetKey(t *testing.T) {
@@ -86,7 +224,7 @@ func TestGetKey(t *testing.T) {
 		So(r.Action, ShouldEqual, "get")
 		So(r.Item.Version, ShouldEqual, int64(1))
 		//So(string(r.Item.Value.([]byte)), ShouldEqual, "initial value for test")
-		log.Println(FormatJSONValue(r).Item.Value)
+		log.Println(FormatJSONValue(opts, r).Item.Value)
 	})
 
 	Convey("Should return a ResponseObject with an Error message if no key name was provided", t, func() {
@@ -98,6 +236,140 @@ func TestGetKey(t *testing.T) {
 	})
 }
 
+func TestListKeys(t *testing.T) {
+	Convey("Should return a ResponseObject with the items beginning with the prefix", t, func() {
+		storage.RegisterShipper("mock", mockdb.MockShipper{})
+		var opts = config.Options{StorageInterfaceName: "mock", Version: "0.0.0", CfgName: "mockcfg", Key: "initial"}
+		r := ListKeys(opts)
+		So(r.Action, ShouldEqual, "ls")
+		So(len(r.Items), ShouldEqual, 2)
+		So(r.Items[0].Key, ShouldEqual, "initial")
+		So(r.Items[1].Key, ShouldEqual, "initial_second")
+	})
+
+	Convey("Should not include the root key", t, func() {
+		storage.RegisterShipper("mock", mockdb.MockShipper{})
+		var opts = config.Options{StorageInterfaceName: "mock", Version: "0.0.0", CfgName: "mockcfg"}
+		r := ListKeys(opts)
+		for _, item := range r.Items {
+			So(item.Key, ShouldNotEqual, "/")
+		}
+	})
+
+	Convey("Should return a ResponseObject with an Error message if no config name was provided", t, func() {
+		storage.RegisterShipper("mock", mockdb.MockShipper{})
+		var opts = config.Options{StorageInterfaceName: "mock", Version: "0.0.0"}
+		r := ListKeys(opts)
+		So(r.Error, ShouldEqual, MissingCfgNameMsg)
+	})
+}
+
+func TestGC(t *testing.T) {
+	// Deleting keys advances the mock config version, which other tests rely on.
+	root := mockdb.MockCfg["mockcfg"]["/"]
+	defer func() {
+		mockdb.MockCfg["mockcfg"]["/"] = root
+		delete(mockdb.MockCfg["mockcfg"], "fresh")
+	}()
+
+	Convey("Should delete expired keys and leave the rest", t, func() {
+		storage.RegisterShipper("mock", mockdb.MockShipper{})
+		mockdb.MockCfg["mockcfg"]["expired"] = config.Item{Key: "expired", Value: []byte("old"), Version: 1, TTL: 1, Expiration: time.Now().Add(-time.Minute)}
+		mockdb.MockCfg["mockcfg"]["fresh"] = config.Item{Key: "fresh", Value: []byte("new"), Version: 1, TTL: 60, Expiration: time.Now().Add(time.Minute)}
+
+		var opts = config.Options{StorageInterfaceName: "mock", Version: "0.0.0", CfgName: "mockcfg"}
+		So(ListKeys(opts).Items, ShouldNotContain, mockdb.MockCfg["mockcfg"]["expired"])
+
+		r := GC(opts)
+		So(r.Action, ShouldEqual, "gc")
+		So(r.Error, ShouldEqual, "")
+		So(len(r.Items), ShouldEqual, 1)
+		So(r.Items[0].Key, ShouldEqual, "expired")
+		_, ok := mockdb.MockCfg["mockcfg"]["expired"]
+		So(ok, ShouldBeFalse)
+		_, ok = mockdb.MockCfg["mockcfg"]["fresh"]
+		So(ok, ShouldBeTrue)
+	})
+
+	Convey("Should only delete keys that are still expired", t, func() {
+		storage.RegisterShipper("mock", mockdb.MockShipper{})
+		var opts = config.Options{StorageInterfaceName: "mock", CfgName: "mockcfg", Key: "fresh", ConditionalExpired: true}
+		_, err := storage.Delete(opts)
+		So(err, ShouldNotBeNil)
+		_, ok := mockdb.MockCfg["mockcfg"]["fresh"]
+		So(ok, ShouldBeTrue)
+	})
+}
+
+func TestTouchKey(t *testing.T) {
+	defer delete(mockdb.MockCfg["mockcfg"], "lease")
+
+	Convey("Should reset a key's TTL without changing its value or version", t, func() {
+		storage.RegisterShipper("mock", mockdb.MockShipper{})
+		mockdb.MockCfg["mockcfg"]["lease"] = config.Item{Key: "lease", Value: []byte("holder"), Version: 3, TTL: 1, Expiration: time.Now().Add(time.Second)}
+
+		var opts = config.Options{StorageInterfaceName: "mock", Version: "0.0.0", CfgName: "mockcfg", Key: "lease", TTL: 60}
+		r := TouchKey(opts)
+		So(r.Action, ShouldEqual, "touch")
+		So(r.Error, ShouldEqual, "")
+		So(r.Item.TTL, ShouldEqual, int64(60))
+		So(r.Item.Expiration, ShouldHappenAfter, time.Now().Add(59*time.Second))
+		So(mockdb.MockCfg["mockcfg"]["lease"].Value, ShouldResemble, []byte("holder"))
+		So(mockdb.MockCfg["mockcfg"]["lease"].Version, ShouldEqual, int64(3))
+	})
+
+	Convey("Should only touch a key with a matching version when one is given", t, func() {
+		storage.RegisterShipper("mock", mockdb.MockShipper{})
+		var opts = config.Options{StorageInterfaceName: "mock", Version: "0.0.0", CfgName: "mockcfg", Key: "lease", TTL: 60, ConditionalVersion: 2}
+		r := TouchKey(opts)
+		So(r.Error, ShouldNotEqual, "")
+
+		opts.ConditionalVersion = 3
+		r = TouchKey(opts)
+		So(r.Error, ShouldEqual, "")
+	})
+
+	Convey("Should return an error for keys that don't exist or have expired", t, func() {
+		storage.RegisterShipper("mock", mockdb.MockShipper{})
+		mockdb.MockCfg["mockcfg"]["lease"] = config.Item{Key: "lease", Value: []byte("holder"), Version: 3, TTL: 1, Expiration: time.Now().Add(-time.Second)}
+		var opts = config.Options{StorageInterfaceName: "mock", Version: "0.0.0", CfgName: "mockcfg", Key: "lease", TTL: 60}
+		So(TouchKey(opts).Error, ShouldNotEqual, "")
+		opts.Key = "missing"
+		So(TouchKey(opts).Error, ShouldNotEqual, "")
+	})
+}
+
+func TestIncrementKey(t *testing.T) {
+	// Incrementing advances the mock config version, which other tests rely on.
+	root := mockdb.MockCfg["mockcfg"]["/"]
+	defer func() {
+		mockdb.MockCfg["mockcfg"]["/"] = root
+		delete(mockdb.MockCfg["mockcfg"], "counter")
+	}()
+
+	Convey("Should add to a number value, starting from 0", t, func() {
+		storage.RegisterShipper("mock", mockdb.MockShipper{})
+		var opts = config.Options{StorageInterfaceName: "mock", Version: "0.0.0", CfgName: "mockcfg", Key: "counter"}
+		r := IncrementKey(opts, 1)
+		So(r.Action, ShouldEqual, "incr")
+		So(r.Error, ShouldEqual, "")
+		So(string(r.Item.Value.([]byte)), ShouldEqual, "1")
+		So(r.Item.Type, ShouldEqual, config.ValueTypeNumber)
+
+		r = IncrementKey(opts, 0.5)
+		So(string(r.Item.Value.([]byte)), ShouldEqual, "1.5")
+		So(r.Item.Version, ShouldEqual, int64(2))
+	})
+
+	Convey("Should return an error for values that aren't numbers", t, func() {
+		storage.RegisterShipper("mock", mockdb.MockShipper{})
+		var opts = config.Options{StorageInterfaceName: "mock", Version: "0.0.0", CfgName: "mockcfg", Key: "initial"}
+		r := IncrementKey(opts, 1)
+		So(r.Error, ShouldNotEqual, "")
+		So(string(mockdb.MockCfg["mockcfg"]["initial"].Value.([]byte)), ShouldEqual, "initial value for test")
+	})
+}
+
 func TestDeleteKey(t *testing.T) {
 	Convey("Should return a ResponseObject with an Error message if not enough arguments were provided", t, func() {
 		storage.RegisterShipper("mock", mockdb.MockShipper{})
@@ -132,5 +404,83 @@ func TestInfo(t *testing.T) {
 	})
 }
 
+func TestListCfgs(t *testing.T) {
+	Convey("Should return a ResponseObject with the configs", t, func() {
+		storage.RegisterShipper("mock", mockdb.MockShipper{})
+		r := ListCfgs(config.Options{StorageInterfaceName: "mock", Version: "0.0.0"})
+		So(r.Action, ShouldEqual, "list cfg")
+		So(r.Error, ShouldEqual, "")
+		So(len(r.Cfgs), ShouldEqual, len(mockdb.MockCfg))
+		So(r.Cfgs[0].Name, ShouldEqual, "mockcfg")
+		So(r.Cfgs[0].State, ShouldEqual, "ACTIVE")
+		So(r.Cfgs[0].Version, ShouldEqual, int64(4))
+		So(r.Cfgs[0].Modified, ShouldEqual, int64(1464675792))
+	})
+
+	Convey("Should return a ResponseObject with an Error message for an invalid shipper", t, func() {
+		r := ListCfgs(config.Options{StorageInterfaceName: "", Version: "0.0.0"})
+		So(r.Error, ShouldNotBeEmpty)
+	})
+}
+
+func TestCloneCfg(t *testing.T) {
+	storage.RegisterShipper("mock", mockdb.MockShipper{})
+	opts := config.Options{StorageInterfaceName: "mock", CfgName: "mockcfg", Version: "0.0.0"}
+	defer delete(mockdb.MockCfg, "mockcfg_clone")
+	defer delete(mockdb.MockCfg, "mockcfg_renamed")
+
+	Convey("Should copy every item to a new config", t, func() {
+		r := CloneCfg(opts, "mockcfg_clone", time.Second)
+		So(r.Action, ShouldEqual, "clone cfg")
+		So(r.Error, ShouldEqual, "")
+		So(mockdb.MockCfg["mockcfg_clone"], ShouldResemble, mockdb.MockCfg["mockcfg"])
+	})
+
+	Convey("Should return an Error message when the new config exists", t, func() {
+		r := CloneCfg(opts, "mockcfg_clone", 0)
+		So(r.Error, ShouldNotBeEmpty)
+	})
+
+	Convey("Should rename a config, deleting the original", t, func() {
+		clone := opts
+		clone.CfgName = "mockcfg_clone"
+		r := RenameCfg(clone, "mockcfg_renamed", 0)
+		So(r.Action, ShouldEqual, "rename cfg")
+		So(r.Error, ShouldEqual, "")
+		So(mockdb.MockCfg, ShouldNotContainKey, "mockcfg_clone")
+		So(mockdb.MockCfg["mockcfg_renamed"]["/"].CfgVersion, ShouldEqual, int64(4))
+		So(mockdb.MockCfg["mockcfg_renamed"]["/"].Frozen, ShouldBeFalse)
+	})
+
+	Convey("Should unfreeze the original when renaming fails", t, func() {
+		r := RenameCfg(opts, "mockcfg_renamed", 0)
+		So(r.Error, ShouldNotBeEmpty)
+		So(mockdb.MockCfg["mockcfg"]["/"].Frozen, ShouldBeFalse)
+	})
+
+	Convey("Should return an Error message if not enough arguments were provided", t, func() {
+		r := RenameCfg(opts, "", 0)
+		So(r.Error, ShouldEqual, NotEnoughArgsMsg)
+	})
+}
+
+func TestMigrateCfg(t *testing.T) {
+	storage.RegisterShipper("mock", mockdb.MockShipper{})
+	opts := config.Options{StorageInterfaceName: "mock", CfgName: "mockcfg", Version: "0.0.0"}
+
+	Convey("Should unfreeze the original when migrating it fails", t, func() {
+		r := MigrateCfg(opts, "mock", true)
+		So(r.Action, ShouldEqual, "migrate cfg")
+		So(r.Error, ShouldNotBeEmpty)
+		So(mockdb.MockCfg, ShouldContainKey, "mockcfg")
+		So(mockdb.MockCfg["mockcfg"]["/"].Frozen, ShouldBeFalse)
+	})
+
+	Convey("Should return an Error message if not enough arguments were provided", t, func() {
+		r := MigrateCfg(opts, "", false)
+		So(r.Error, ShouldEqual, NotEnoughArgsMsg)
+	})
+}
+
 func TestExport(t *testing.T) {
 }
@@ -0,0 +1,72 @@
+package commands
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/tmaiaroto/discfg/config"
+	"github.com/tmaiaroto/discfg/storage"
+	"github.com/tmaiaroto/discfg/storage/mockdb"
+	"testing"
+)
+
+func TestFreezeCfg(t *testing.T) {
+	storage.RegisterShipper("mock", mockdb.MockShipper{})
+	opts := config.Options{StorageInterfaceName: "mock", CfgName: "mockcfg", Version: "0.0.0", Author: "tester"}
+	root := mockdb.MockCfg["mockcfg"]["/"]
+	defer func() {
+		mockdb.MockCfg["mockcfg"]["/"] = root
+		delete(mockdb.MockCfg["mockcfg"], "thawed")
+	}()
+
+	Convey("A frozen config's keys shouldn't change", t, func() {
+		r := FreezeCfg(opts, true)
+		So(r.Action, ShouldEqual, "freeze cfg")
+		So(r.Error, ShouldEqual, "")
+		version := mockdb.MockCfg["mockcfg"]["/"].CfgVersion
+
+		opts.Key = "initial"
+		opts.Value = []byte("changed")
+		r = SetKey(opts)
+		So(r.Error, ShouldEqual, config.ErrFrozen.Error())
+		So(r.ErrorCode, ShouldEqual, config.StatusFrozen)
+		So(string(mockdb.MockCfg["mockcfg"]["initial"].Value.([]byte)), ShouldEqual, "initial value for test")
+		So(DeleteKey(opts).ErrorCode, ShouldEqual, config.StatusFrozen)
+		So(IncrementKey(opts, 1).ErrorCode, ShouldEqual, config.StatusFrozen)
+		So(TouchKey(opts).ErrorCode, ShouldEqual, config.StatusFrozen)
+		So(SetCfgMeta(opts, []byte(`{"owner": "ops"}`)).ErrorCode, ShouldEqual, config.StatusFrozen)
+		So(string(mockdb.MockCfg["mockcfg"]["/"].Value.([]byte)), ShouldEqual, "Mock configuration")
+		So(DeleteCfg(opts).ErrorCode, ShouldEqual, config.StatusFrozen)
+		So(RenameCfg(opts, "mockcfg_renamed", 0).ErrorCode, ShouldEqual, config.StatusFrozen)
+		So(mockdb.MockCfg["mockcfg_renamed"], ShouldBeNil)
+		So(mockdb.MockCfg["mockcfg"]["/"].CfgVersion, ShouldEqual, version)
+
+		Convey("A clone of it shouldn't be frozen", func() {
+			defer delete(mockdb.MockCfg, "mockcfg_thawed")
+			So(CloneCfg(opts, "mockcfg_thawed", 0).Error, ShouldEqual, "")
+			So(mockdb.MockCfg["mockcfg_thawed"]["/"].Frozen, ShouldBeFalse)
+		})
+
+		Convey("It should say so in its info", func() {
+			r := Info(opts)
+			So(r.CfgFrozen, ShouldBeTrue)
+			So(r.Message, ShouldContainSubstring, ", frozen")
+		})
+	})
+
+	Convey("An unfrozen config's keys should change again", t, func() {
+		r := FreezeCfg(opts, false)
+		So(r.Action, ShouldEqual, "unfreeze cfg")
+		So(r.Error, ShouldEqual, "")
+
+		opts.Key = "thawed"
+		opts.Value = []byte("value")
+		So(SetKey(opts).Error, ShouldEqual, "")
+		So(Info(opts).CfgFrozen, ShouldBeFalse)
+	})
+
+	Convey("Only configs that exist can be frozen", t, func() {
+		missing := opts
+		missing.CfgName = "missing"
+		So(FreezeCfg(missing, true).Error, ShouldNotBeEmpty)
+		So(FreezeCfg(config.Options{StorageInterfaceName: "mock"}, true).Error, ShouldEqual, MissingCfgNameMsg)
+	})
+}
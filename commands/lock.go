@@ -0,0 +1,70 @@
+// Package commands lock commands, for acquiring, renewing and releasing lease locks (see the lock package).
+package commands
+
+import (
+	"github.com/tmaiaroto/discfg/config"
+	"github.com/tmaiaroto/discfg/lock"
+	"time"
+)
+
+// AcquireLock acquires the lock named by opts.Key for the owner, with a lease of opts.TTL seconds
+func AcquireLock(opts config.Options, owner string) config.ResponseObject {
+	resp := config.ResponseObject{
+		Action: "acquire",
+	}
+	if opts.CfgName == "" {
+		resp.Error = MissingCfgNameMsg
+		return resp
+	}
+	lease, err := lock.Acquire(opts, opts.Key, owner, time.Duration(opts.TTL)*time.Second)
+	return lockResponse(opts, resp, lease, err, "Lock acquired")
+}
+
+// RenewLock renews the owner's lease on the lock named by opts.Key for another opts.TTL seconds
+func RenewLock(opts config.Options, owner string) config.ResponseObject {
+	resp := config.ResponseObject{
+		Action: "renew",
+	}
+	if opts.CfgName == "" {
+		resp.Error = MissingCfgNameMsg
+		return resp
+	}
+	lease, err := lock.Renew(opts, lock.Lease{Name: opts.Key, Owner: owner, TTL: time.Duration(opts.TTL) * time.Second})
+	return lockResponse(opts, resp, lease, err, "Lock renewed")
+}
+
+// ReleaseLock releases the lock named by opts.Key if held by the owner
+func ReleaseLock(opts config.Options, owner string) config.ResponseObject {
+	resp := config.ResponseObject{
+		Action: "release",
+	}
+	if opts.CfgName == "" {
+		resp.Error = MissingCfgNameMsg
+		return resp
+	}
+	lease := lock.Lease{Name: opts.Key, Owner: owner}
+	err := lock.Release(opts, lease)
+	return lockResponse(opts, resp, lease, err, "Lock released")
+}
+
+// lockResponse sets the lease (or error) on a lock command's response. The owner is part of the message rather
+// than the item value, so the CLI outputs the message.
+func lockResponse(opts config.Options, resp config.ResponseObject, lease lock.Lease, err error, msg string) config.ResponseObject {
+	resp.Item = config.Item{
+		Key:        lock.Key(lease.Name),
+		Version:    lease.Version,
+		TTL:        int64(lease.TTL / time.Second),
+		Expiration: lease.Expiration,
+	}
+	if err != nil {
+		resp.Error = err.Error()
+		if err == lock.ErrNotAcquired || err == lock.ErrLost {
+			if holder, holderErr := lock.Holder(opts, lease.Name); holderErr == nil && holder != "" {
+				resp.Message = "Lock is held by " + holder
+			}
+		}
+		return resp
+	}
+	resp.Message = msg + " for " + lease.Owner
+	return resp
+}
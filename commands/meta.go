@@ -0,0 +1,123 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/tmaiaroto/discfg/audit"
+	"github.com/tmaiaroto/discfg/config"
+	"github.com/tmaiaroto/discfg/storage"
+)
+
+// SetCfgMeta updates a configuration's metadata (owner, description, tags and annotation) from a JSON object.
+// Only the fields given change, tags are merged (an empty tag value removes the tag). The metadata is the root
+// key's value, so setting it doesn't change the config version. Should someone else change it at the same time,
+// the update fails rather than losing their change.
+func SetCfgMeta(opts config.Options, changes []byte) config.ResponseObject {
+	resp := config.ResponseObject{
+		Action: "set cfg meta",
+	}
+	if opts.CfgName == "" {
+		resp.Error = MissingCfgNameMsg
+		return resp
+	}
+	meta, root, err := cfgMeta(opts)
+	if err == nil {
+		err = mergeCfgMeta(meta, changes)
+	}
+	if err != nil {
+		resp.Error = err.Error()
+		resp.Message = "Error setting the configuration metadata"
+		return resp
+	}
+	value, _ := json.Marshal(meta)
+
+	// Only the value is set on the root key, nothing else a key could be set with
+	rootOpts := config.Options{
+		CfgName:              opts.CfgName,
+		StorageInterfaceName: opts.StorageInterfaceName,
+		Storage:              opts.Storage,
+		Context:              opts.Context,
+		Key:                  "/",
+		Value:                value,
+		ValueType:            config.ValueTypeJSON,
+	}
+	// Only if the metadata hasn't changed since it was read, including when it's set for the first time
+	rootOpts.ConditionalVersion = root.Version
+	rootOpts.ConditionalUnset = root.Version == 0
+	if _, err := storage.Update(rootOpts); err != nil {
+		resp.Error = err.Error()
+		resp.ErrorCode = errorCode(err)
+		resp.Message = "Error setting the configuration metadata"
+		return resp
+	}
+	resp.CfgMeta = meta
+	resp.Message = "Successfully set the configuration metadata"
+	recordAudit(opts, config.AuditEvent{
+		Action:     resp.Action,
+		Key:        "/",
+		OldVersion: root.Version,
+		NewVersion: root.Version + 1,
+		ValueHash:  audit.Hash(value),
+	}, &resp)
+	return resp
+}
+
+// GetCfgMeta returns a configuration's metadata
+func GetCfgMeta(opts config.Options) config.ResponseObject {
+	resp := config.ResponseObject{
+		Action: "get cfg meta",
+	}
+	if opts.CfgName == "" {
+		resp.Error = MissingCfgNameMsg
+		return resp
+	}
+	meta, _, err := cfgMeta(opts)
+	if err != nil {
+		resp.Error = err.Error()
+		return resp
+	}
+	resp.CfgMeta = meta
+	resp.Message = meta.String()
+	if resp.Message == "" {
+		resp.Message = "No metadata for " + opts.CfgName
+	}
+	return resp
+}
+
+// cfgMeta returns a configuration's metadata (empty when there's none yet) along with its root key
+func cfgMeta(opts config.Options) (*config.CfgMeta, config.Item, error) {
+	// Setting the root key of a config that doesn't exist would create it in some storage engines (a shared table)
+	state, err := storage.ConfigState(opts)
+	if err != nil {
+		return nil, config.Item{}, err
+	}
+	if state == config.CfgStateNotFound {
+		return nil, config.Item{}, errors.New("The configuration " + opts.CfgName + " doesn't exist")
+	}
+	opts.Key = "/"
+	root, err := storage.Get(opts)
+	if err != nil {
+		return nil, root, err
+	}
+	meta := config.ParseCfgMeta(root.Value)
+	if meta == nil {
+		meta = &config.CfgMeta{}
+	}
+	return meta, root, nil
+}
+
+// mergeCfgMeta applies changes, a JSON object of metadata fields, to the metadata
+func mergeCfgMeta(meta *config.CfgMeta, changes []byte) error {
+	d := json.NewDecoder(bytes.NewReader(changes))
+	d.DisallowUnknownFields()
+	if err := d.Decode(meta); err != nil {
+		return errors.New("Invalid metadata, it must be a JSON object of owner, description, tags and annotation: " + err.Error())
+	}
+	for k, v := range meta.Tags {
+		if v == "" {
+			delete(meta.Tags, k)
+		}
+	}
+	return nil
+}
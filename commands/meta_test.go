@@ -0,0 +1,72 @@
+package commands
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/tmaiaroto/discfg/config"
+	"github.com/tmaiaroto/discfg/storage"
+	"github.com/tmaiaroto/discfg/storage/mockdb"
+	"testing"
+)
+
+func TestCfgMeta(t *testing.T) {
+	storage.RegisterShipper("mock", mockdb.MockShipper{})
+	opts := config.Options{StorageInterfaceName: "mock", CfgName: "mockcfg", Version: "0.0.0"}
+	root := mockdb.MockCfg["mockcfg"]["/"]
+	defer func() {
+		mockdb.MockCfg["mockcfg"]["/"] = root
+	}()
+
+	Convey("A config without metadata should have empty metadata", t, func() {
+		r := GetCfgMeta(opts)
+		So(r.Action, ShouldEqual, "get cfg meta")
+		So(r.Error, ShouldEqual, "")
+		So(*r.CfgMeta, ShouldResemble, config.CfgMeta{})
+	})
+
+	Convey("Metadata should be set without changing the config version", t, func() {
+		version := mockdb.MockCfg["mockcfg"]["/"].CfgVersion
+		r := SetCfgMeta(opts, []byte(`{"owner": "platform", "description": "Feature flags", "tags": {"env": "prod", "team": "a"}, "annotation": {"oncall": "#platform"}}`))
+		So(r.Error, ShouldEqual, "")
+		So(mockdb.MockCfg["mockcfg"]["/"].CfgVersion, ShouldEqual, version)
+
+		r = GetCfgMeta(opts)
+		So(r.CfgMeta.Owner, ShouldEqual, "platform")
+		So(string(r.CfgMeta.Annotation), ShouldEqual, `{"oncall":"#platform"}`)
+		So(r.Message, ShouldEqual, "owned by platform: Feature flags [env=prod, team=a]")
+	})
+
+	Convey("Only the fields given should change, an empty tag removing it", t, func() {
+		r := SetCfgMeta(opts, []byte(`{"description": "Flags", "tags": {"team": ""}}`))
+		So(r.Error, ShouldEqual, "")
+		So(r.CfgMeta.Owner, ShouldEqual, "platform")
+		So(r.CfgMeta.Description, ShouldEqual, "Flags")
+		So(r.CfgMeta.Tags, ShouldResemble, map[string]string{"env": "prod"})
+	})
+
+	Convey("Metadata should only be set if it hasn't changed since it was read", t, func() {
+		rootOpts := opts
+		rootOpts.Key = "/"
+		rootOpts.Value = []byte(`{}`)
+		rootOpts.ConditionalUnset = true
+		_, err := storage.Update(rootOpts)
+		So(err, ShouldNotBeNil)
+		rootOpts.ConditionalUnset = false
+		rootOpts.ConditionalVersion = mockdb.MockCfg["mockcfg"]["/"].Version - 1
+		_, err = storage.Update(rootOpts)
+		So(err, ShouldNotBeNil)
+		So(GetCfgMeta(opts).CfgMeta.Owner, ShouldEqual, "platform")
+	})
+
+	Convey("The metadata should be included in info and the config list", t, func() {
+		So(Info(opts).CfgMeta.Owner, ShouldEqual, "platform")
+		So(ListCfgs(opts).Cfgs[0].Meta.Owner, ShouldEqual, "platform")
+	})
+
+	Convey("Unknown fields and configs should be errors", t, func() {
+		So(SetCfgMeta(opts, []byte(`{"colour": "blue"}`)).Error, ShouldNotBeEmpty)
+		missing := opts
+		missing.CfgName = "missing"
+		So(SetCfgMeta(missing, []byte(`{}`)).Error, ShouldNotBeEmpty)
+		So(GetCfgMeta(config.Options{StorageInterfaceName: "mock"}).Error, ShouldEqual, MissingCfgNameMsg)
+	})
+}
@@ -0,0 +1,140 @@
+// Package commands schema validation, JSON Schemas stored in a config are used to validate values for matching keys.
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"github.com/tmaiaroto/discfg/config"
+	"github.com/xeipuuv/gojsonschema"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// SchemaKeyPrefix defines the reserved namespace for JSON Schemas. The rest of a schema's key is a pattern
+// (see path.Match) for the keys it applies to. For example, "/_schema/services/*/port" applies to "services/api/port".
+const SchemaKeyPrefix = "/_schema/"
+
+// InvalidSchemaMsg defines a message for setting a schema that isn't a valid JSON Schema
+const InvalidSchemaMsg = "Invalid JSON Schema: "
+
+// InvalidValueMsg defines a message for setting a value that doesn't validate against a schema
+const InvalidValueMsg = "Value does not match the schema at "
+
+// ValueNotJSONMsg defines a message for a value that has a schema but isn't JSON
+const ValueNotJSONMsg = "value is not JSON"
+
+// Validate checks every key in a configuration against the schema(s) matching it. Items that fail are returned.
+func Validate(opts config.Options) config.ResponseObject {
+	resp := config.ResponseObject{
+		Action: "validate",
+	}
+	if opts.CfgName == "" {
+		resp.Error = MissingCfgNameMsg
+		return resp
+	}
+
+	schemas, err := getSchemas(opts)
+	if err != nil {
+		resp.Error = err.Error()
+		return resp
+	}
+	listOpts := opts
+	listOpts.Key = ""
+	list := ListKeys(listOpts)
+	if list.Error != "" {
+		resp.Error = list.Error
+		return resp
+	}
+
+	checked, skipped := 0, 0
+	var failures []string
+	resp.Items = []config.Item{}
+	for _, item := range list.Items {
+		if isSchemaKey(item.Key) {
+			continue
+		}
+		// Encrypted values can only be validated when they can be decrypted
+		item, err = decryptItem(opts, item)
+		if err != nil || (item.Encrypted && !opts.Decrypt) {
+			skipped++
+			continue
+		}
+		value, _ := item.Value.([]byte)
+		checked++
+		if err := validateValue(schemas, item.Key, value); err != nil {
+			failures = append(failures, item.Key+": "+err.Error())
+			resp.Items = append(resp.Items, item)
+		}
+	}
+
+	resp.Message = strconv.Itoa(checked) + " keys checked, " + strconv.Itoa(len(failures)) + " invalid"
+	if skipped > 0 {
+		resp.Message += ", " + strconv.Itoa(skipped) + " encrypted keys skipped"
+	}
+	if len(failures) > 0 {
+		resp.Error = strings.Join(failures, "\n")
+	}
+	return resp
+}
+
+// validateKeyValue validates a value about to be set for a key. Schemas themselves are checked to be valid JSON Schemas
+// and all other values are checked against any schemas with a pattern matching the key.
+func validateKeyValue(opts config.Options, key string, value []byte) error {
+	if isSchemaKey(key) {
+		if _, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(value)); err != nil {
+			return errors.New(InvalidSchemaMsg + err.Error())
+		}
+		return nil
+	}
+	schemas, err := getSchemas(opts)
+	if err != nil {
+		return err
+	}
+	return validateValue(schemas, key, value)
+}
+
+// validateValue validates a value against each schema matching the key, the error lists every failing path.
+func validateValue(schemas []config.Item, key string, value []byte) error {
+	for _, schema := range schemas {
+		pattern := strings.TrimPrefix(schema.Key, SchemaKeyPrefix)
+		if matched, _ := path.Match(strings.TrimPrefix(pattern, "/"), strings.TrimPrefix(key, "/")); !matched {
+			continue
+		}
+		schemaValue, _ := schema.Value.([]byte)
+		result, err := gojsonschema.Validate(gojsonschema.NewBytesLoader(schemaValue), gojsonschema.NewBytesLoader(value))
+		if err != nil {
+			// The schema was validated when set, so this is (most likely) a value that isn't JSON.
+			return errors.New(InvalidValueMsg + schema.Key + ": " + ValueNotJSONMsg)
+		}
+		if !result.Valid() {
+			var buffer bytes.Buffer
+			buffer.WriteString(InvalidValueMsg)
+			buffer.WriteString(schema.Key)
+			buffer.WriteString(":")
+			for _, e := range result.Errors() {
+				buffer.WriteString(" ")
+				buffer.WriteString(e.Field())
+				buffer.WriteString(": ")
+				buffer.WriteString(e.Description())
+				buffer.WriteString(";")
+			}
+			return errors.New(strings.TrimSuffix(buffer.String(), ";"))
+		}
+	}
+	return nil
+}
+
+// getSchemas returns all of the schemas stored in a configuration.
+func getSchemas(opts config.Options) ([]config.Item, error) {
+	opts.Key = SchemaKeyPrefix
+	resp := ListKeys(opts)
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+	return resp.Items, nil
+}
+
+func isSchemaKey(key string) bool {
+	return strings.HasPrefix(key, SchemaKeyPrefix)
+}
@@ -0,0 +1,64 @@
+package commands
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/tmaiaroto/discfg/config"
+	"github.com/tmaiaroto/discfg/storage"
+	"github.com/tmaiaroto/discfg/storage/mockdb"
+	"testing"
+)
+
+func TestSchemaValidation(t *testing.T) {
+	// Setting keys changes the mock config, which other tests rely on.
+	root := mockdb.MockCfg["mockcfg"]["/"]
+	defer func() {
+		mockdb.MockCfg["mockcfg"]["/"] = root
+		delete(mockdb.MockCfg["mockcfg"], "/_schema/services/*")
+		delete(mockdb.MockCfg["mockcfg"], "services/api")
+	}()
+	schema := []byte(`{"type": "object", "properties": {"port": {"type": "integer"}}, "required": ["port"]}`)
+
+	Convey("Should not allow setting an invalid JSON Schema", t, func() {
+		storage.RegisterShipper("mock", mockdb.MockShipper{})
+		var opts = config.Options{StorageInterfaceName: "mock", Version: "0.0.0", CfgName: "mockcfg", Key: "/_schema/services/*", Value: []byte(`{"type": 4}`)}
+		r := SetKey(opts)
+		So(r.Error, ShouldStartWith, InvalidSchemaMsg)
+	})
+
+	Convey("Should validate values against the schema matching the key", t, func() {
+		storage.RegisterShipper("mock", mockdb.MockShipper{})
+		var opts = config.Options{StorageInterfaceName: "mock", Version: "0.0.0", CfgName: "mockcfg", Key: "/_schema/services/*", Value: schema}
+		r := SetKey(opts)
+		So(r.Error, ShouldEqual, "")
+
+		opts.Key = "services/api"
+		opts.Value = []byte(`{"port": "80"}`)
+		r = SetKey(opts)
+		So(r.Error, ShouldStartWith, InvalidValueMsg+"/_schema/services/*")
+		So(r.Error, ShouldContainSubstring, "port: Invalid type")
+
+		opts.Value = []byte(`not json`)
+		r = SetKey(opts)
+		So(r.Error, ShouldEndWith, ValueNotJSONMsg)
+
+		opts.Value = []byte(`{"port": 80}`)
+		r = SetKey(opts)
+		So(r.Error, ShouldEqual, "")
+
+		// Keys without a matching schema are not validated
+		opts.Key = "other"
+		opts.Value = []byte(`not json`)
+		So(validateKeyValue(opts, opts.Key, opts.Value), ShouldBeNil)
+	})
+
+	Convey("Should report existing keys that don't match their schema", t, func() {
+		storage.RegisterShipper("mock", mockdb.MockShipper{})
+		mockdb.MockCfg["mockcfg"]["services/api"] = config.Item{Key: "services/api", Value: []byte(`{"host": "x"}`), Version: 1}
+		var opts = config.Options{StorageInterfaceName: "mock", Version: "0.0.0", CfgName: "mockcfg"}
+		r := Validate(opts)
+		So(r.Action, ShouldEqual, "validate")
+		So(len(r.Items), ShouldEqual, 1)
+		So(r.Items[0].Key, ShouldEqual, "services/api")
+		So(r.Error, ShouldContainSubstring, "port is required")
+	})
+}
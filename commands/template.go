@@ -0,0 +1,205 @@
+// Package commands template rendering, for generating files from configuration values (not unlike consul-template).
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/tmaiaroto/discfg/config"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"text/template"
+	"time"
+)
+
+// MissingTemplateFilesMsg defines a message for input validation when template input or output paths were not passed
+const MissingTemplateFilesMsg = "Both a template input file and an output file are required"
+
+// TemplateKeyNotFoundMsg defines a message for template rendering when a key used by the template has no value
+const TemplateKeyNotFoundMsg = "Key not found: "
+
+// Template renders a text/template file using values from a configuration and writes the result to the output path.
+// The config version at the time of rendering is set on the response so callers can tell when to render again.
+func Template(opts config.Options, input string, output string) config.ResponseObject {
+	resp := config.ResponseObject{
+		Action: "template",
+	}
+	if opts.CfgName == "" {
+		resp.Error = MissingCfgNameMsg
+		return resp
+	}
+	if input == "" || output == "" {
+		resp.Error = MissingTemplateFilesMsg
+		return resp
+	}
+
+	// Get the version before rendering. Should the config change while rendering, the version will have advanced
+	// past this one and a watcher will simply render again.
+	rootOpts := opts
+	rootOpts.Key = "/"
+	root := GetKey(rootOpts)
+	if root.Error != "" {
+		resp.Error = root.Error
+		return resp
+	}
+	resp.CfgVersion = root.Item.CfgVersion
+
+	b, err := ioutil.ReadFile(input)
+	if err != nil {
+		resp.Error = err.Error()
+		return resp
+	}
+	tmpl, err := template.New(input).Funcs(TemplateFuncs(opts)).Parse(string(b))
+	if err != nil {
+		resp.Error = err.Error()
+		resp.Message = "Error parsing the template"
+		return resp
+	}
+
+	var buffer bytes.Buffer
+	if err = tmpl.Execute(&buffer, nil); err != nil {
+		resp.Error = err.Error()
+		resp.Message = "Error rendering the template"
+		return resp
+	}
+	if err = writeFileAtomic(output, buffer.Bytes(), 0644); err != nil {
+		resp.Error = err.Error()
+		resp.Message = "Error writing the rendered template"
+		return resp
+	}
+
+	resp.Message = "Rendered " + input + " to " + output
+	return resp
+}
+
+// writeFileAtomic writes a file by renaming a temporary file (in the same directory) over it, so anything reading
+// it sees either the old or the new contents, never part of them. An existing file keeps its mode.
+func writeFileAtomic(path string, data []byte, mode os.FileMode) error {
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Chmod(mode)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// WatchTemplate renders a template and then checks the config version on the root key "/" every interval,
+// rendering again whenever it advances. If a reload command is given, it is run (with sh) after each successful render.
+// Each response is passed to the given function. This blocks forever, so it's meant for the CLI or a goroutine.
+func WatchTemplate(opts config.Options, input string, output string, interval time.Duration, reload string, fn func(config.ResponseObject)) {
+	rootOpts := opts
+	rootOpts.Key = "/"
+	var renderedVersion int64 = -1
+
+	for {
+		root := GetKey(rootOpts)
+		if root.Error != "" {
+			fn(root)
+		} else if root.Item.CfgVersion > renderedVersion {
+			resp := Template(opts, input, output)
+			if resp.Error == "" {
+				renderedVersion = resp.CfgVersion
+				if reload != "" {
+					if out, err := exec.Command("sh", "-c", reload).CombinedOutput(); err != nil {
+						resp.Error = err.Error()
+						resp.Message = string(out)
+					}
+				}
+			}
+			fn(resp)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// TemplateFuncs returns the functions available to templates. Each function reads from the given configuration.
+//
+//	key "name"                   the value for a key (rendering fails if the key has no value)
+//	keyOrDefault "name" "value"  the value for a key or a default if the key has no value
+//	ls "/prefix"                 all items with keys beginning with the prefix (each with .Key and .Value)
+//	json "name"                  the value for a key decoded from JSON
+func TemplateFuncs(opts config.Options) template.FuncMap {
+	return template.FuncMap{
+		"key": func(name string) (string, error) {
+			value, err := templateKeyValue(opts, name)
+			if err != nil {
+				return "", err
+			}
+			if value == nil {
+				return "", errors.New(TemplateKeyNotFoundMsg + name)
+			}
+			return string(value), nil
+		},
+		"keyOrDefault": func(name string, defaultValue string) (string, error) {
+			value, err := templateKeyValue(opts, name)
+			if err != nil {
+				return "", err
+			}
+			if value == nil {
+				return defaultValue, nil
+			}
+			return string(value), nil
+		},
+		"ls": func(prefix string) ([]config.Item, error) {
+			lsOpts := opts
+			lsOpts.Key = prefix
+			resp := ListKeys(lsOpts)
+			if resp.Error != "" {
+				return nil, errors.New(resp.Error)
+			}
+			// Values are strings in templates, otherwise they'd print as byte arrays.
+			for i := range resp.Items {
+				if b, ok := resp.Items[i].Value.([]byte); ok {
+					resp.Items[i].Value = string(b)
+				}
+			}
+			return resp.Items, nil
+		},
+		"json": func(name string) (interface{}, error) {
+			value, err := templateKeyValue(opts, name)
+			if err != nil {
+				return nil, err
+			}
+			if value == nil {
+				return nil, errors.New(TemplateKeyNotFoundMsg + name)
+			}
+			var data interface{}
+			err = json.Unmarshal(value, &data)
+			return data, err
+		},
+	}
+}
+
+// templateKeyValue gets a key's value, a nil value means the key was not found.
+func templateKeyValue(opts config.Options, name string) ([]byte, error) {
+	opts.Key = name
+	resp := GetKey(opts)
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+	if resp.Item.Value == nil {
+		return nil, nil
+	}
+	value, _ := resp.Item.Value.([]byte)
+	return value, nil
+}
@@ -0,0 +1,67 @@
+package commands
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/tmaiaroto/discfg/config"
+	"github.com/tmaiaroto/discfg/storage"
+	"github.com/tmaiaroto/discfg/storage/mockdb"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTemplate(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "discfg")
+	defer os.RemoveAll(dir)
+	input := filepath.Join(dir, "test.tmpl")
+	output := filepath.Join(dir, "test.out")
+
+	Convey("Should render key values into the output file", t, func() {
+		storage.RegisterShipper("mock", mockdb.MockShipper{})
+		var opts = config.Options{StorageInterfaceName: "mock", Version: "0.0.0", CfgName: "mockcfg"}
+		tmpl := `{{key "initial"}}|{{keyOrDefault "missing" "fallback"}}|{{(json "json_value").num}}|{{range ls "initial"}}{{.Key}},{{end}}`
+		_ = ioutil.WriteFile(input, []byte(tmpl), 0644)
+
+		r := Template(opts, input, output)
+		So(r.Action, ShouldEqual, "template")
+		So(r.Error, ShouldEqual, "")
+		So(r.CfgVersion, ShouldEqual, mockdb.MockCfg["mockcfg"]["/"].CfgVersion)
+
+		b, _ := ioutil.ReadFile(output)
+		So(string(b), ShouldEqual, "initial value for test|fallback|4|initial,initial_second,")
+	})
+
+	Convey("Should replace the output file whole, keeping its mode", t, func() {
+		storage.RegisterShipper("mock", mockdb.MockShipper{})
+		var opts = config.Options{StorageInterfaceName: "mock", Version: "0.0.0", CfgName: "mockcfg"}
+		_ = ioutil.WriteFile(input, []byte(`{{key "initial"}}`), 0644)
+		_ = os.Chmod(output, 0600)
+
+		r := Template(opts, input, output)
+		So(r.Error, ShouldEqual, "")
+		b, _ := ioutil.ReadFile(output)
+		So(string(b), ShouldEqual, "initial value for test")
+		info, _ := os.Stat(output)
+		So(info.Mode().Perm(), ShouldEqual, os.FileMode(0600))
+		// No temporary files are left behind
+		files, _ := ioutil.ReadDir(dir)
+		So(files, ShouldHaveLength, 2)
+	})
+
+	Convey("Should return a ResponseObject with an Error message if a key used by the template has no value", t, func() {
+		storage.RegisterShipper("mock", mockdb.MockShipper{})
+		var opts = config.Options{StorageInterfaceName: "mock", Version: "0.0.0", CfgName: "mockcfg"}
+		_ = ioutil.WriteFile(input, []byte(`{{key "missing"}}`), 0644)
+
+		r := Template(opts, input, output)
+		So(r.Error, ShouldContainSubstring, TemplateKeyNotFoundMsg+"missing")
+	})
+
+	Convey("Should return a ResponseObject with an Error message if no input or output file was provided", t, func() {
+		storage.RegisterShipper("mock", mockdb.MockShipper{})
+		var opts = config.Options{StorageInterfaceName: "mock", Version: "0.0.0", CfgName: "mockcfg"}
+		r := Template(opts, input, "")
+		So(r.Error, ShouldEqual, MissingTemplateFilesMsg)
+	})
+}
@@ -0,0 +1,93 @@
+package commands
+
+import (
+	"github.com/tmaiaroto/discfg/auth"
+	"github.com/tmaiaroto/discfg/config"
+	"strings"
+	"time"
+)
+
+// CreateToken creates an API token with policies in the form access:cfg[:key] (see auth.ParsePolicy). The token is
+// only ever returned here.
+func CreateToken(opts config.Options, name string, policies []string) config.ResponseObject {
+	resp := config.ResponseObject{
+		Action: "create token",
+	}
+	if len(policies) == 0 {
+		resp.Error = "At least one policy is required"
+		return resp
+	}
+	parsed := []config.Policy{}
+	for _, s := range policies {
+		p, err := auth.ParsePolicy(s)
+		if err != nil {
+			resp.Error = err.Error()
+			return resp
+		}
+		parsed = append(parsed, p)
+	}
+	t, err := auth.Create(opts, name, parsed)
+	if err != nil {
+		resp.Error = err.Error()
+		resp.Message = "Error creating the token"
+		return resp
+	}
+	resp.Tokens = []config.Token{t}
+	resp.Message = "Successfully created the token, it can't be shown again"
+	recordTokenAudit(opts, resp.Action, t, &resp)
+	return resp
+}
+
+// RevokeToken revokes an API token by name
+func RevokeToken(opts config.Options, name string) config.ResponseObject {
+	resp := config.ResponseObject{
+		Action: "revoke token",
+	}
+	t, err := auth.Revoke(opts, name)
+	if err != nil {
+		resp.Error = err.Error()
+		resp.Message = "Error revoking the token"
+		return resp
+	}
+	resp.Message = "Successfully revoked the token"
+	recordTokenAudit(opts, resp.Action, t, &resp)
+	return resp
+}
+
+// recordTokenAudit records a change to a token in the token configuration's audit log, by the token's key (never
+// the token itself)
+func recordTokenAudit(opts config.Options, action string, t config.Token, resp *config.ResponseObject) {
+	opts.CfgName = opts.Auth.Cfg
+	recordAudit(opts, config.AuditEvent{Action: action, Key: auth.KeyPrefix + t.Hash}, resp)
+}
+
+// ListTokens lists the API tokens, without the tokens themselves
+func ListTokens(opts config.Options) config.ResponseObject {
+	resp := config.ResponseObject{
+		Action: "list token",
+	}
+	tokens, err := auth.List(opts)
+	if err != nil {
+		resp.Error = err.Error()
+		resp.Message = "Error listing tokens"
+		return resp
+	}
+	resp.Tokens = tokens
+	if len(tokens) == 0 {
+		resp.Message = "No tokens found"
+	}
+	return resp
+}
+
+// tokenLine describes a token on one line, ie. "ci created 2026-10-19T12:00:00Z: write:prod:services/, read:*"
+func tokenLine(t config.Token) string {
+	policies := []string{}
+	for _, p := range t.Policies {
+		policies = append(policies, p.String())
+	}
+	line := t.Name + " created " + time.Unix(0, t.Created).Format(time.RFC3339) + ": " + strings.Join(policies, ", ")
+	if t.Secret != "" {
+		line += "\n" + t.Secret
+	}
+	return line
+}
@@ -0,0 +1,61 @@
+package commands
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/tmaiaroto/discfg/audit"
+	"github.com/tmaiaroto/discfg/auth"
+	"github.com/tmaiaroto/discfg/config"
+	"github.com/tmaiaroto/discfg/storage"
+	"github.com/tmaiaroto/discfg/storage/mockdb"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTokens(t *testing.T) {
+	storage.RegisterShipper("mock", mockdb.MockShipper{})
+	opts := config.Options{StorageInterfaceName: "mock", Version: "0.0.0"}
+	opts.Auth.Cfg = "mocktokens"
+	storage.CreateConfig(config.Options{StorageInterfaceName: "mock", CfgName: "mocktokens"}, nil)
+	defer storage.DeleteConfig(config.Options{StorageInterfaceName: "mock", CfgName: "mocktokens"})
+
+	Convey("Tokens should be created, listed and revoked", t, func() {
+		r := CreateToken(opts, "ci", []string{"write:prod:services/", "read:*"})
+		So(r.Action, ShouldEqual, "create token")
+		So(r.Error, ShouldEqual, "")
+		So(r.Tokens[0].Secret, ShouldNotBeEmpty)
+		So(tokenLine(r.Tokens[0]), ShouldContainSubstring, ": write:prod:services/, read:*\n"+r.Tokens[0].Secret)
+
+		r = ListTokens(opts)
+		So(len(r.Tokens), ShouldEqual, 1)
+		So(r.Tokens[0].Secret, ShouldEqual, "")
+
+		So(RevokeToken(opts, "ci").Error, ShouldEqual, "")
+		So(ListTokens(opts).Message, ShouldEqual, "No tokens found")
+	})
+
+	Convey("Creating and revoking tokens should be recorded in the audit log, without the token", t, func() {
+		dir, _ := ioutil.TempDir("", "discfg-audit")
+		defer os.RemoveAll(dir)
+		audited := opts
+		audited.Audit.Sink = "file"
+		audited.Audit.File = filepath.Join(dir, "audit.jsonl")
+		r := CreateToken(audited, "audited", []string{"read:*"})
+		So(r.Error, ShouldEqual, "")
+		So(RevokeToken(audited, "audited").Error, ShouldEqual, "")
+
+		audited.CfgName = "mocktokens"
+		events := Audit(audited, audit.Filter{}).AuditEvents
+		So(len(events), ShouldEqual, 2)
+		So(events[0].Action, ShouldEqual, "create token")
+		So(events[1].Action, ShouldEqual, "revoke token")
+		So(events[1].Key, ShouldEqual, auth.KeyPrefix+r.Tokens[0].Hash)
+	})
+
+	Convey("Tokens need a name and valid policies", t, func() {
+		So(CreateToken(opts, "ci", []string{}).Error, ShouldNotBeEmpty)
+		So(CreateToken(opts, "ci", []string{"all:*"}).Error, ShouldNotBeEmpty)
+		So(CreateToken(opts, "", []string{"read:*"}).Error, ShouldNotBeEmpty)
+	})
+}
@@ -2,15 +2,17 @@
 package commands
 
 import (
-	//"encoding/base64"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"regexp"
-	//"strconv"
+	"strconv"
+	"strings"
 	//"github.com/pquerna/ffjson/ffjson"
 	ct "github.com/daviddengcn/go-colortext"
 	"github.com/tmaiaroto/discfg/config"
+	"github.com/tmaiaroto/discfg/storage"
 	"io/ioutil"
 	"time"
 )
@@ -35,11 +37,27 @@ const MissingKeyNameMsg = "Missing key name"
 // InvalidKeyNameMsg defines a message for input validation
 const InvalidKeyNameMsg = "Invalid key name"
 
+// RootKeyMsg defines a message for input validation when a key change would change the root key, which holds the
+// config's metadata
+const RootKeyMsg = "The root key / can't be changed, it holds the configuration's metadata (see 'discfg cfg meta')"
+
 // MissingCfgNameMsg defines a message for input validation
 const MissingCfgNameMsg = "Missing configuration name"
 
+// InvalidValueTypeMsg defines a message for input validation when an unknown value type is given
+const InvalidValueTypeMsg = "Invalid value type, must be one of: string, number, bool, json, binary"
+
+// ValueNotOfTypeMsg defines a message for input validation when a value can't be parsed as its given type
+const ValueNotOfTypeMsg = "Value is not of type "
+
+// RedactedValue replaces the value of sensitive keys in output
+const RedactedValue = "***"
+
 // Out formats a config.ResponseObject for suitable output
 func Out(opts config.Options, resp config.ResponseObject) config.ResponseObject {
+	// Sensitive values are never output unless asked for.
+	resp = Redact(opts, resp)
+
 	// We've stored everything as binary data. But that can be many things.
 	// A string, a number, or even JSON. We can check to see if it's something we can marshal to JSON.
 	// If that fails, then we'll just return it as a string in the JSON response under the "value" key.
@@ -71,15 +89,11 @@ func Out(opts config.Options, resp config.ResponseObject) config.ResponseObject
 	// 	resp.PrevItem.OutputValue = json.RawMessage(resp.PrevItem.Value)
 	// }
 
-	// Format the expiration time (if applicable). This prevents output like "0001-01-01T00:00:00Z" when empty
-	// and allows for the time.RFC3339Nano format to be used whereas time.Time normally marshals to a different format.
-	if resp.Item.TTL > 0 {
-		resp.Item.OutputExpiration = resp.Item.Expiration.Format(time.RFC3339Nano)
-	}
+	resp = formatExpirations(resp)
 
 	switch opts.OutputFormat {
 	case "json":
-		o, _ := json.Marshal(&resp)
+		o, _ := json.Marshal(FormatJSONValue(opts, resp))
 		// TODO: Benchmark this - is it faster?
 		// o, _ := ffjson.Marshal(&resp)
 		//
@@ -97,6 +111,25 @@ func Out(opts config.Options, resp config.ResponseObject) config.ResponseObject
 		if resp.Item.Value != nil {
 			// The value should be a byte array, for th CLI we want a string.
 			fmt.Println(string(resp.Item.Value.([]byte)))
+		} else if len(resp.Items) > 0 {
+			for _, item := range resp.Items {
+				fmt.Println(item.Key)
+			}
+		} else if len(resp.Cfgs) > 0 {
+			for _, cfg := range resp.Cfgs {
+				fmt.Println(cfgSummaryLine(cfg))
+			}
+		} else if len(resp.AuditEvents) > 0 {
+			for _, e := range resp.AuditEvents {
+				fmt.Println(auditEventLine(e))
+			}
+		} else if len(resp.Tokens) > 0 {
+			if resp.Message != "" {
+				fmt.Println(resp.Message)
+			}
+			for _, t := range resp.Tokens {
+				fmt.Println(tokenLine(t))
+			}
 		} else {
 			if resp.Message != "" {
 				fmt.Println(resp.Message)
@@ -106,6 +139,71 @@ func Out(opts config.Options, resp config.ResponseObject) config.ResponseObject
 	return resp
 }
 
+// cfgSummaryLine describes a config on one line, like the info command's message
+func cfgSummaryLine(cfg config.CfgSummary) string {
+	line := cfg.Name
+	if cfg.State != "" {
+		line += " (" + cfg.State + ")"
+	}
+	line += " version " + strconv.FormatInt(cfg.Version, 10)
+	if cfg.ModifiedNanoseconds > 0 {
+		line += " last modified " + time.Unix(0, cfg.ModifiedNanoseconds).Format(time.RFC1123)
+	}
+	if cfg.Frozen {
+		line += ", frozen"
+	}
+	if cfg.Meta != nil && cfg.Meta.String() != "" {
+		line += ", " + cfg.Meta.String()
+	}
+	return line
+}
+
+// author returns who is changing a key, the author in the options or else the identity of the storage credentials.
+// Not knowing who it is shouldn't stop the change, so it's empty when the identity can't be found.
+func author(opts config.Options) string {
+	if opts.Author != "" {
+		return opts.Author
+	}
+	identity, _ := storage.Identity(opts)
+	return identity
+}
+
+// errorCode returns the status code for a storage error, 0 for errors without one
+func errorCode(err error) int {
+	switch err {
+	case config.ErrFrozen:
+		return config.StatusFrozen
+	case config.ErrContention:
+		return config.StatusContention
+	}
+	return 0
+}
+
+// hasTags returns whether or not an item has all of the tags
+func hasTags(item config.Item, tags map[string]string) bool {
+	for k, v := range tags {
+		if t, ok := item.Tags[k]; !ok || t != v {
+			return false
+		}
+	}
+	return true
+}
+
+// auditEventLine describes an audit event on one line, ie. "2026-10-19T12:00:00Z set mykey v1 -> v2 by arn:... (cli)"
+func auditEventLine(e config.AuditEvent) string {
+	line := time.Unix(0, e.Time).Format(time.RFC3339) + " " + e.Action
+	if e.Key != "" {
+		line += " " + e.Key + " v" + strconv.FormatInt(e.OldVersion, 10) + " -> v" + strconv.FormatInt(e.NewVersion, 10)
+	}
+	if e.Identity != "" {
+		line += " by " + e.Identity
+	}
+	if e.Source != "" {
+		line += " (" + e.Source + ")"
+	}
+	return line
+}
+
 // Changes the color for error messages. Good for one line heading. Any lengthy response should probably not be colored with a red background.
 func errorLabel(message string) {
 	ct.ChangeColor(ct.White, true, ct.Red, false)
@@ -152,8 +250,11 @@ func formatKeyName(key string) (string, error) {
 		return "", errors.New(MissingKeyNameMsg)
 	}
 
-	// Ensure valid characters
+	// Ensure valid characters (schema keys are patterns, so they may also use glob characters)
 	r, _ := regexp.Compile(`[\w\/\-]+$`)
+	if isSchemaKey(k) {
+		r, _ = regexp.Compile(`[\w\/\-\*\?\[\]]+$`)
+	}
 	if !r.MatchString(k) {
 		return "", errors.New(InvalidKeyNameMsg)
 	}
@@ -163,7 +264,7 @@ func formatKeyName(key string) (string, error) {
 	// This may come in a future version, for now the structure is flat. However, convention set by other tools (along with REST API endpoints)
 	// makes using slashes a natural fit and discfg will assume they are being used. It could be thought of as a namespace.
 	if len(k) > 1 {
-		for k[len(k)-1:] == "/" {
+		for len(k) > 1 && k[len(k)-1:] == "/" {
 			k = k[:len(k)-1]
 		}
 	}
@@ -171,6 +272,15 @@ func formatKeyName(key string) (string, error) {
 	return k, err
 }
 
+// formatChangedKeyName checks and formats the name of a key being changed, which can't be the root key
+func formatChangedKeyName(key string) (string, error) {
+	k, err := formatKeyName(key)
+	if err == nil && k == "/" {
+		return "", errors.New(RootKeyMsg)
+	}
+	return k, err
+}
+
 func isJSONString(s string) bool {
 	var js string
 	err := json.Unmarshal([]byte(s), &js)
@@ -182,46 +292,166 @@ func isJSON(s string) bool {
 
 }
 
+// Redact replaces the values of sensitive items in a response with RedactedValue, unless the options say to reveal them.
+// Anything that outputs key values (the CLI, the API, etc.) should pass responses through here first.
+func Redact(opts config.Options, resp config.ResponseObject) config.ResponseObject {
+	if opts.Reveal {
+		return resp
+	}
+	resp.Item = redactItem(opts, resp.Item)
+	resp.PrevItem = redactItem(opts, resp.PrevItem)
+	if resp.Items != nil {
+		items := make([]config.Item, len(resp.Items))
+		for i := range resp.Items {
+			items[i] = redactItem(opts, resp.Items[i])
+		}
+		resp.Items = items
+	}
+	return resp
+}
+
+// IsSensitive returns whether or not an item is sensitive, either marked as such or under a sensitive key prefix.
+func IsSensitive(opts config.Options, item config.Item) bool {
+	if item.Sensitive {
+		return true
+	}
+	for _, prefix := range opts.SensitivePrefixes {
+		if prefix != "" && strings.HasPrefix(item.Key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func redactItem(opts config.Options, item config.Item) config.Item {
+	if item.Value != nil && IsSensitive(opts, item) {
+		item.Value = []byte(RedactedValue)
+		item.Sensitive = true
+	}
+	return item
+}
+
 // FormatJSONValue sets the Item Value (an interface{}) as a map[string]interface{} so it can be output as JSON.
 // The stored value could actually be JSON so it tries to Unmarshal. If it can't, it will just be a string value
 // in the response object which will already be JSON (ie. {"value": "the string value"}).
-func FormatJSONValue(resp config.ResponseObject) config.ResponseObject {
+// Sensitive values are redacted (see Redact).
+func FormatJSONValue(opts config.Options, resp config.ResponseObject) config.ResponseObject {
+	resp = Redact(opts, resp)
+	resp = formatExpirations(resp)
+
 	// Don't attempt to Unmarshal or anything if the Value is empty. We wouldn't want to create a panic now.
 	if resp.Item.Value != nil {
-		resp.Item.Value = string(resp.Item.Value.([]byte))
+		resp.Item.Value = formatJSONItemValue(opts, resp.Item)
+	}
 
-		// TODO: Perhaps an option for storing/retrieving data...
-		// The value could be base64 encoded, but it need not be.
-		// val, err := base64.StdEncoding.DecodeString(resp.Item.Value.(string)) //`eyJ1cGRhdGVkIjogImZyaWRheSJ9`)
-		// if err == nil && val != nil {
-		// 	resp.Item.Value = string(val)
-		// }
+	// The previous value as well
+	if resp.PrevItem.Value != nil {
+		resp.PrevItem.Value = formatJSONItemValue(opts, resp.PrevItem)
+	}
 
-		// Try to unmarshal to map if JSON string
-		var jsonData map[string]interface{}
-		err := json.Unmarshal([]byte(resp.Item.Value.(string)), &jsonData)
-		if err == nil {
-			resp.Item.Value = jsonData
+	// And any list of items
+	for i := range resp.Items {
+		if resp.Items[i].Value != nil {
+			resp.Items[i].Value = formatJSONItemValue(opts, resp.Items[i])
 		}
 	}
 
-	// The previous value as well
-	if resp.PrevItem.Value != nil {
-		resp.PrevItem.Value = string(resp.PrevItem.Value.([]byte))
+	return resp
+}
 
-		// TODO: Perhaps an option for storing/retrieving data...
-		// The value could be base64 encoded, but it need not be.
-		// val, err := base64.StdEncoding.DecodeString(resp.PrevItem.Value.(string)) //`eyJ1cGRhdGVkIjogImZyaWRheSJ9`)
-		// if err == nil && val != nil {
-		// 	resp.PrevItem.Value = string(val)
-		// }
+// formatExpirations formats the expiration time (if applicable) for each item in a response. This prevents output like
+// "0001-01-01T00:00:00Z" when empty and allows for the time.RFC3339Nano format to be used whereas time.Time normally
+// marshals to a different format.
+func formatExpirations(resp config.ResponseObject) config.ResponseObject {
+	resp.Item = formatExpiration(resp.Item)
+	resp.PrevItem = formatExpiration(resp.PrevItem)
+	if resp.Items != nil {
+		items := make([]config.Item, len(resp.Items))
+		for i := range resp.Items {
+			items[i] = formatExpiration(resp.Items[i])
+		}
+		resp.Items = items
+	}
+	return resp
+}
+
+func formatExpiration(item config.Item) config.Item {
+	if item.TTL > 0 && !item.Expiration.IsZero() {
+		item.OutputExpiration = item.Expiration.Format(time.RFC3339Nano)
+	}
+	return item
+}
 
-		var jsonData map[string]interface{}
-		err := json.Unmarshal([]byte(resp.PrevItem.Value.(string)), &jsonData)
-		if err == nil {
-			resp.PrevItem.Value = jsonData
+// isExpired returns whether or not an item with a TTL has expired (storage engines may not have removed it yet).
+func isExpired(item config.Item, now time.Time) bool {
+	return item.TTL > 0 && item.Expiration.Before(now)
+}
+
+// formatJSONItemValue converts a stored []byte value to its type for JSON output. Binary values are base64 encoded.
+// Untyped values become a string, or a map if the string is a JSON object.
+func formatJSONItemValue(opts config.Options, item config.Item) interface{} {
+	b, ok := item.Value.([]byte)
+	if !ok {
+		return item.Value
+	}
+	str := string(b)
+
+	valueType := item.Type
+	// Encrypted values that weren't decrypted are an envelope, not a value of the item's type.
+	if item.Encrypted && !opts.Decrypt {
+		valueType = ""
+	}
+
+	// Should a value somehow not be of its type (redacted values for example), it's returned as a string.
+	switch valueType {
+	case config.ValueTypeString:
+		return str
+	case config.ValueTypeNumber:
+		if config.NumberRegexp.MatchString(str) {
+			return json.Number(str)
+		}
+		return str
+	case config.ValueTypeBool:
+		if v, err := strconv.ParseBool(str); err == nil {
+			return v
+		}
+		return str
+	case config.ValueTypeJSON:
+		var data interface{}
+		if err := json.Unmarshal(b, &data); err == nil {
+			return data
 		}
+		return str
+	case config.ValueTypeBinary:
+		return base64.StdEncoding.EncodeToString(b)
 	}
 
-	return resp
+	// Try to unmarshal to map if JSON string
+	var jsonData map[string]interface{}
+	err := json.Unmarshal(b, &jsonData)
+	if err == nil {
+		return jsonData
+	}
+	return str
+}
+
+// validateValueType checks a value can be parsed as its type (an empty type is untyped, anything goes).
+func validateValueType(valueType string, value []byte) error {
+	valid := true
+	switch valueType {
+	case "", config.ValueTypeString, config.ValueTypeBinary:
+	case config.ValueTypeNumber:
+		valid = config.NumberRegexp.Match(value)
+	case config.ValueTypeBool:
+		_, err := strconv.ParseBool(string(value))
+		valid = err == nil
+	case config.ValueTypeJSON:
+		valid = json.Valid(value)
+	default:
+		return errors.New(InvalidValueTypeMsg)
+	}
+	if !valid {
+		return errors.New(ValueNotOfTypeMsg + valueType)
+	}
+	return nil
 }
@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"encoding/json"
 	. "github.com/smartystreets/goconvey/convey"
 	"github.com/tmaiaroto/discfg/config"
 	"github.com/tmaiaroto/discfg/storage"
@@ -32,21 +33,75 @@ func TestGetDiscfgNameFromFile(t *testing.T) {
 	})
 }
 
+func TestRedact(t *testing.T) {
+	resp := config.ResponseObject{
+		Item:     config.Item{Key: "apikey", Value: []byte("abc123"), Sensitive: true},
+		PrevItem: config.Item{Key: "apikey", Value: []byte("xyz789"), Sensitive: true},
+		Items: []config.Item{
+			{Key: "secrets/db", Value: []byte("hunter2")},
+			{Key: "public", Value: []byte("hello")},
+		},
+	}
+
+	Convey("Should redact the values of sensitive items and keys under sensitive prefixes", t, func() {
+		opts := config.Options{SensitivePrefixes: []string{"secrets/"}}
+		r := Redact(opts, resp)
+		So(string(r.Item.Value.([]byte)), ShouldEqual, RedactedValue)
+		So(string(r.PrevItem.Value.([]byte)), ShouldEqual, RedactedValue)
+		So(string(r.Items[0].Value.([]byte)), ShouldEqual, RedactedValue)
+		So(r.Items[0].Sensitive, ShouldBeTrue)
+		So(string(r.Items[1].Value.([]byte)), ShouldEqual, "hello")
+		// The original response should be left alone
+		So(string(resp.Items[0].Value.([]byte)), ShouldEqual, "hunter2")
+	})
+
+	Convey("Should not redact anything when revealing", t, func() {
+		opts := config.Options{SensitivePrefixes: []string{"secrets/"}, Reveal: true}
+		r := Redact(opts, resp)
+		So(string(r.Item.Value.([]byte)), ShouldEqual, "abc123")
+		So(string(r.Items[0].Value.([]byte)), ShouldEqual, "hunter2")
+	})
+
+	Convey("Should redact when formatting JSON values", t, func() {
+		r := FormatJSONValue(config.Options{}, resp)
+		So(r.Item.Value.(string), ShouldEqual, RedactedValue)
+	})
+}
+
 func TestFormatJSONValue(t *testing.T) {
 	Convey("Should handle basic string values", t, func() {
 		storage.RegisterShipper("mock", mockdb.MockShipper{})
 		var opts = config.Options{StorageInterfaceName: "mock", Version: "0.0.0", CfgName: "mockcfg", Key: "initial"}
 		r := GetKey(opts)
-		rFormatted := FormatJSONValue(r)
+		rFormatted := FormatJSONValue(opts, r)
 		So(rFormatted.Item.Value.(string), ShouldEqual, "initial value for test")
 	})
 
+	Convey("Should return values as their type", t, func() {
+		resp := config.ResponseObject{
+			Item:     config.Item{Key: "n", Value: []byte("4.5"), Type: config.ValueTypeNumber},
+			PrevItem: config.Item{Key: "n", Value: []byte("true"), Type: config.ValueTypeBool},
+			Items: []config.Item{
+				{Key: "list", Value: []byte(`[1, "two"]`), Type: config.ValueTypeJSON},
+				{Key: "bin", Value: []byte{0, 1, 2}, Type: config.ValueTypeBinary},
+				{Key: "str", Value: []byte(`{"not": "json"}`), Type: config.ValueTypeString},
+			},
+		}
+		r := FormatJSONValue(config.Options{}, resp)
+		o, _ := json.Marshal(r)
+		So(string(o), ShouldContainSubstring, `"value":4.5`)
+		So(string(o), ShouldContainSubstring, `"value":true`)
+		So(string(o), ShouldContainSubstring, `"value":[1,"two"]`)
+		So(string(o), ShouldContainSubstring, `"value":"AAEC"`)
+		So(string(o), ShouldContainSubstring, `"value":"{\"not\": \"json\"}"`)
+	})
+
 	// Not yet
 	// Convey("Should handle base64 encoded string values", t, func() {
 	// 	storage.RegisterShipper("mock", mockdb.MockShipper{})
 	// 	var opts = config.Options{StorageInterfaceName: "mock", Version: "0.0.0", CfgName: "mockcfg", Key: "encoded"}
 	// 	r := GetKey(opts)
-	// 	rFormatted := FormatJSONValue(r)
+	// 	rFormatted := FormatJSONValue(opts, r)
 	// 	mapValue := map[string]interface{}{"updated": "friday"}
 	// 	So(rFormatted.Item.Value.(map[string]interface{}), ShouldResemble, mapValue)
 	// })
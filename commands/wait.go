@@ -0,0 +1,64 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"github.com/tmaiaroto/discfg/config"
+	"github.com/tmaiaroto/discfg/storage"
+	"time"
+)
+
+// Polling backs off from the first interval, doubling up to the max interval between checks
+const (
+	waitFirstInterval = 500 * time.Millisecond
+	waitMaxInterval   = 5 * time.Second
+)
+
+// DefaultWaitTimeout is how long WaitForCfg waits by default (the CLI's --waitTimeout)
+const DefaultWaitTimeout = 5 * time.Minute
+
+// WaitForCfg polls the config state until the config is ready (ACTIVE) or, when deleted is true, until it no
+// longer exists. Each state seen is passed to progress, which may be nil. The last state is returned, along with
+// an error should the timeout (0 is no timeout) pass or the options' context be done first.
+func WaitForCfg(opts config.Options, deleted bool, timeout time.Duration, progress func(state string)) (string, error) {
+	want := config.CfgStateActive
+	if deleted {
+		want = config.CfgStateNotFound
+	}
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	opts.Context = ctx
+
+	state := ""
+	interval := waitFirstInterval
+	for {
+		current, err := storage.ConfigState(opts)
+		if err != nil {
+			return state, err
+		}
+		if current != state && progress != nil {
+			progress(current)
+		}
+		state = current
+		if state == want {
+			return state, nil
+		}
+		// Waiting on a new config that isn't there (yet) is fine, it may take a moment to show up
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return state, errors.New("Timed out waiting for the configuration to be " + want + ", it's " + state)
+		}
+		interval *= 2
+		if interval > waitMaxInterval {
+			interval = waitMaxInterval
+		}
+	}
+}
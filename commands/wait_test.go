@@ -0,0 +1,41 @@
+package commands
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/tmaiaroto/discfg/config"
+	"github.com/tmaiaroto/discfg/storage"
+	"github.com/tmaiaroto/discfg/storage/mockdb"
+	"testing"
+	"time"
+)
+
+func TestWaitForCfg(t *testing.T) {
+	storage.RegisterShipper("mock", mockdb.MockShipper{})
+
+	Convey("Should return once the config is active", t, func() {
+		states := []string{}
+		state, err := WaitForCfg(config.Options{StorageInterfaceName: "mock", CfgName: "mockcfg"}, false, time.Second, func(s string) {
+			states = append(states, s)
+		})
+		So(err, ShouldBeNil)
+		So(state, ShouldEqual, config.CfgStateActive)
+		So(states, ShouldResemble, []string{config.CfgStateActive})
+	})
+
+	Convey("Should return once a deleted config is gone", t, func() {
+		state, err := WaitForCfg(config.Options{StorageInterfaceName: "mock", CfgName: "deletedcfg"}, true, time.Second, nil)
+		So(err, ShouldBeNil)
+		So(state, ShouldEqual, config.CfgStateNotFound)
+	})
+
+	Convey("Should return an error after the timeout", t, func() {
+		state, err := WaitForCfg(config.Options{StorageInterfaceName: "mock", CfgName: "mockcfg"}, true, 100*time.Millisecond, nil)
+		So(err, ShouldNotBeNil)
+		So(state, ShouldEqual, config.CfgStateActive)
+	})
+
+	Convey("Should return an error for an invalid shipper", t, func() {
+		_, err := WaitForCfg(config.Options{StorageInterfaceName: ""}, false, time.Second, nil)
+		So(err, ShouldNotBeNil)
+	})
+}
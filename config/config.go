@@ -2,7 +2,12 @@
 package config
 
 import (
-	//"encoding/json"
+	"context"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -21,6 +26,73 @@ type Options struct {
 	}
 	Version      string
 	OutputFormat string
+	// Context for storage calls, so deadlines and cancelation can stop them (context.Background() when nil)
+	Context context.Context
+	// Conditional operation on the key's current version (0 is no condition)
+	ConditionalVersion int64
+	// Conditional operation, only set the key if it has never been set (it has no version). Unlike
+	// ConditionalNotExists, this works for keys that exist without being set, such as the root key "/".
+	ConditionalUnset bool
+	// Conditional operation, only set the key if it doesn't exist (or has expired). Combined with a
+	// ConditionalValue, either condition allows the operation.
+	ConditionalNotExists bool
+	// Conditional operation, only delete the key if it has expired (so a key set again since isn't deleted)
+	ConditionalExpired bool
+	// Change a key without updating the config version or checking whether the config is frozen, for keys that
+	// aren't part of the config itself (locks for example). They're also never cached.
+	Unversioned bool
+	// Read the latest value rather than a possibly stale one, for storage engines whose reads are eventually
+	// consistent (DynamoDB), listing included. The cache reads this way so it never caches an item older than the
+	// config version, and copies read this way so they can be checked.
+	ConsistentRead bool
+	// Delete a config even though it's frozen, renaming freezes the original while it's copied
+	DeleteFrozen bool
+	// Encrypt values client side before storing them
+	Encrypt bool
+	// Decrypt encrypted values (otherwise they are returned, and output, still encrypted)
+	Decrypt bool
+	// The type of value being set (see the ValueType constants), empty for untyped values
+	ValueType string
+	// Mark a key as sensitive (or not) when setting it, its value will be redacted in output. When nil, the key's
+	// flag is left as it was.
+	Sensitive *bool
+	// Keys beginning with any of these prefixes are also treated as sensitive
+	SensitivePrefixes []string
+	// Reveal sensitive values in output instead of redacting them
+	Reveal bool
+	// Who is setting a key (the identity the storage credentials resolve to when empty)
+	Author string
+	// A description and tags to set on a key, or tags to filter keys by when listing them
+	Description string
+	Tags        map[string]string
+	// Encryption options, the provider name is used to look up a registered key provider
+	Encryption struct {
+		Provider string
+		KeyFile  string
+		KMSKeyID string
+	}
+	// Audit options, the sink name is used to look up a registered audit sink (empty for no audit log)
+	Audit struct {
+		Sink  string
+		File  string
+		Table string
+		// Where changes come from, ie. "cli", "http" or "lambda"
+		Source string
+	}
+	// API token options, tokens are stored in the Cfg configuration (the HTTP APIs only check tokens when it's set).
+	// Token is sent by clients of the HTTP API.
+	Auth struct {
+		Cfg   string
+		Token string
+	}
+}
+
+// ContextOrBackground returns the context set on the options, context.Background() if there isn't one
+func (o Options) ContextOrBackground() context.Context {
+	if o.Context != nil {
+		return o.Context
+	}
+	return context.Background()
 }
 
 // AWS credentials and options
@@ -30,6 +102,11 @@ type AWS struct {
 	SecretAccessKey string
 	SessionToken    string
 	CredProfile     string
+	// Endpoint overrides the storage endpoint, for DynamoDB Local or a compatible store for example
+	Endpoint string
+	// A role to assume, with the external id it may require
+	RoleARN    string
+	ExternalID string
 }
 
 // ResponseObject for output
@@ -37,6 +114,7 @@ type ResponseObject struct {
 	Action        string `json:"action"`
 	Item          Item   `json:"item,omitempty"`
 	PrevItem      Item   `json:"prevItem,omitempty"`
+	Items         []Item `json:"items,omitempty"`
 	ErrorCode     int    `json:"errorCode,omitempty"`
 	CurrentDiscfg string `json:"currentDiscfg,omitempty"`
 	// Error message
@@ -57,15 +135,156 @@ type ResponseObject struct {
 	CfgModifiedParsed string `json:"cfgModifiedParsed,omitempty"`
 	// Configuration state (some storage engines, such as DynamoDB, have "active" and "updating" states)
 	CfgState string `json:"cfgState,omitempty"`
+	// Whether or not the configuration is frozen (its keys can't be changed)
+	CfgFrozen bool `json:"cfgFrozen,omitempty"`
 	// Information about the configuration storage
 	CfgStorage StorageInfo `json:"cfgStorage,omitempty"`
+	// Configurations (when listing them)
+	Cfgs []CfgSummary `json:"cfgs,omitempty"`
+	// Configuration metadata
+	CfgMeta *CfgMeta `json:"cfgMeta,omitempty"`
+	// Audit log events
+	AuditEvents []AuditEvent `json:"auditEvents,omitempty"`
+	// API tokens
+	Tokens []Token `json:"tokens,omitempty"`
+}
+
+// AuditEvent records a change to a configuration. Events are numbered per config, each one including the hash of
+// the one before it, so a changed or missing event is evident.
+type AuditEvent struct {
+	Cfg string `json:"cfg"`
+	Seq int64  `json:"seq"`
+	// When the change was made, in nanoseconds
+	Time   int64  `json:"time"`
+	Action string `json:"action"`
+	Key    string `json:"key,omitempty"`
+	// The key's version before and after the change
+	OldVersion int64 `json:"oldVersion,omitempty"`
+	NewVersion int64 `json:"newVersion,omitempty"`
+	// SHA-256 of the value set or deleted (as stored, so encrypted values stay that way), or the settings
+	ValueHash string `json:"valueHash,omitempty"`
+	Identity  string `json:"identity,omitempty"`
+	Source    string `json:"source,omitempty"`
+	PrevHash  string `json:"prevHash,omitempty"`
+	Hash      string `json:"hash"`
+}
+
+// Token is an API token, granted access by its policies. Only its hash is stored.
+type Token struct {
+	Name     string   `json:"name"`
+	Policies []Policy `json:"policies"`
+	// When the token was created, in nanoseconds
+	Created int64 `json:"created"`
+	// SHA-256 of the token, it's stored under this
+	Hash string `json:"hash,omitempty"`
+	// The token itself, only ever returned when it's created
+	Secret string `json:"secret,omitempty"`
+}
+
+// Policy grants access ("read", "write" or "admin") to configs matching a pattern (see path.Match) and keys
+// beginning with a prefix (all keys, and the configs themselves, when empty)
+type Policy struct {
+	Cfg    string `json:"cfg"`
+	Key    string `json:"key,omitempty"`
+	Access string `json:"access"`
 }
 
+// String returns a policy in the form access:cfg[:key] (see auth.ParsePolicy)
+func (p Policy) String() string {
+	s := p.Access + ":" + p.Cfg
+	if p.Key != "" {
+		s += ":" + p.Key
+	}
+	return s
+}
+
+// CfgSummary describes a configuration when listing them
+type CfgSummary struct {
+	Name  string `json:"name"`
+	State string `json:"state,omitempty"`
+	// The config version and modified time are 0 for configs that haven't had a key set yet
+	Version             int64  `json:"version"`
+	ModifiedNanoseconds int64  `json:"-"`
+	Modified            int64  `json:"modified,omitempty"`
+	ModifiedParsed      string `json:"modifiedParsed,omitempty"`
+	// Metadata, nil for configs without any
+	Meta   *CfgMeta `json:"meta,omitempty"`
+	Frozen bool     `json:"frozen,omitempty"`
+}
+
+// CfgMeta describes a configuration. It's stored as JSON in the value of the root key "/".
+type CfgMeta struct {
+	Owner       string            `json:"owner,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	// Free-form JSON, for anything else worth knowing about the config
+	Annotation json.RawMessage `json:"annotation,omitempty"`
+}
+
+// ParseCfgMeta returns the metadata in a root key's value. It's nil when there's none, or the value isn't
+// metadata (configs could have had something else set on the root key before).
+func ParseCfgMeta(value interface{}) *CfgMeta {
+	var b []byte
+	switch v := value.(type) {
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return nil
+	}
+	meta := &CfgMeta{}
+	if len(b) == 0 || json.Unmarshal(b, meta) != nil {
+		return nil
+	}
+	return meta
+}
+
+// String describes the metadata on one line, ie. "owned by platform: Feature flags [env=prod]"
+func (m CfgMeta) String() string {
+	s := ""
+	if m.Owner != "" {
+		s = "owned by " + m.Owner
+	}
+	if m.Description != "" {
+		if s != "" {
+			s += ": "
+		}
+		s += m.Description
+	}
+	if len(m.Tags) > 0 {
+		tags := make([]string, 0, len(m.Tags))
+		for k, v := range m.Tags {
+			tags = append(tags, k+"="+v)
+		}
+		sort.Strings(tags)
+		if s != "" {
+			s += " "
+		}
+		s += "[" + strings.Join(tags, ", ") + "]"
+	}
+	return s
+}
+
+// Config states. Storage engines may have others along the way (DynamoDB tables are CREATING, UPDATING or DELETING).
+const (
+	CfgStateActive   = "ACTIVE"
+	CfgStateNotFound = "NOT_FOUND"
+)
+
+// ErrFrozen is returned by storage engines for changes to a frozen configuration
+var ErrFrozen = errors.New("The configuration is frozen, unfreeze it to make changes")
+
+// ErrContention is returned by storage engines when a change kept conflicting with other changes to the configuration
+var ErrContention = errors.New("Too much contention, the configuration kept changing, try again")
+
 // StorageInfo holds information about the storage engine used for the configuration
 type StorageInfo struct {
 	Name          string                 `json:"name"`
 	InterfaceName string                 `json:"interfaceName"`
 	Options       map[string]interface{} `json:"options"`
+	// Who storage is worked with as (the AWS identity for DynamoDB)
+	Identity string `json:"identity,omitempty"`
 }
 
 // NOTES ON ITEMS (somewhat similar to etcd's nodes):
@@ -108,6 +327,19 @@ type StorageInfo struct {
 // Other storage engines may convert to something else.
 // For now, all data is coming in as string. Either from the terminal or a RESTful API.
 
+// Value types. Values are always stored as bytes, but the type is stored alongside so values can be returned as
+// they were set. Untyped values (an empty type) are returned as JSON if they can be parsed as an object, otherwise a string.
+const (
+	ValueTypeString = "string"
+	ValueTypeNumber = "number"
+	ValueTypeBool   = "bool"
+	ValueTypeJSON   = "json"
+	ValueTypeBinary = "binary"
+)
+
+// NumberRegexp matches number values (JSON numbers, which are also what DynamoDB can store as numbers)
+var NumberRegexp = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
 // Item defines the data structure around a key and its state
 type Item struct {
 	Version int64  `json:"version,omitempty"`
@@ -115,6 +347,8 @@ type Item struct {
 	//Value   []byte `json:"value,omitempty"`
 	Value       interface{}            `json:"value,omitempty"`
 	OutputValue map[string]interface{} `json:"ovalue,omitempty"`
+	// The type of value (see the ValueType constants)
+	Type string `json:"type,omitempty"`
 
 	// perfect for json, not good if some other value was stored
 	//OutputValue            map[string]interface{} `json:"value,omitempty"`
@@ -124,9 +358,21 @@ type Item struct {
 	TTL              int64     `json:"ttl,omitempty"`
 	Expiration       time.Time `json:"-"`
 	OutputExpiration string    `json:"expiration,omitempty"`
+	// Whether or not the value is encrypted (client side, see the encryption package)
+	Encrypted bool `json:"encrypted,omitempty"`
+	// Whether or not the value is sensitive (redacted in output unless revealed)
+	Sensitive bool `json:"sensitive,omitempty"`
+	// When the key was last set (in nanoseconds) and by whom, if known
+	Modified   int64  `json:"modified,omitempty"`
+	ModifiedBy string `json:"modifiedBy,omitempty"`
+	// Optional description and tags, describing the key
+	Description string            `json:"description,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
 	// For now, skip this. The original thinking was to have a tree like directory structure like etcd.
 	// Though discfg has now deviated away from that to a flat key/value structure.
 	// Items                  []Item    `json:"items,omitepty"`
 	CfgVersion             int64 `json:"-"`
 	CfgModifiedNanoseconds int64 `json:"-"`
+	// Only set on the root key, a frozen config's keys can't be changed
+	Frozen bool `json:"-"`
 }
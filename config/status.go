@@ -9,6 +9,11 @@ const (
 	StatusOK       = 200
 	StatusCreated  = 201
 	StatusAccepted = 202
+
+	StatusUnauthorized = 401
+	StatusForbidden    = 403
+	StatusContention   = 409
+	StatusFrozen       = 423
 )
 
 var statusText = map[int]string{
@@ -19,6 +24,11 @@ var statusText = map[int]string{
 	StatusOK:       "OK",
 	StatusCreated:  "Created",
 	StatusAccepted: "Accepted",
+
+	StatusUnauthorized: "Unauthorized",
+	StatusForbidden:    "Forbidden",
+	StatusContention:   "Too much contention",
+	StatusFrozen:       "Configuration frozen",
 }
 
 // StatusText returns a text for the discfg status code.
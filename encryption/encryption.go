@@ -0,0 +1,135 @@
+// Package encryption provides client-side envelope encryption for key values. Each value is encrypted with its own
+// data key which is in turn encrypted by a KeyProvider (a local key file, AWS KMS, etc.) and stored alongside it.
+//
+// Values are bound to the config name and key they're encrypted for (as additional authenticated data), so an
+// envelope copied to another key or config won't decrypt there.
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"github.com/tmaiaroto/discfg/config"
+	"io"
+)
+
+// KeyProvider generates and decrypts the data keys used to encrypt values. Much like the storage Shipper interface,
+// anyone importing discfg can register their own.
+type KeyProvider interface {
+	// GenerateDataKey returns a new plaintext data key along with the same key encrypted by the provider.
+	GenerateDataKey(config.Options) ([]byte, []byte, error)
+	// DecryptDataKey returns the plaintext data key for an encrypted data key.
+	DecryptDataKey(config.Options, []byte) ([]byte, error)
+}
+
+// Envelope is what actually gets stored as the value for an encrypted key. It's JSON, so an encrypted value can be
+// displayed (and copied around) safely without ever revealing the plaintext.
+type Envelope struct {
+	Provider string `json:"provider"`
+	Key      []byte `json:"key"`
+	Nonce    []byte `json:"nonce"`
+	Data     []byte `json:"data"`
+}
+
+// Error message constants, reduce repetition.
+const (
+	errMsgInvalidKeyProvider = "Invalid encryption key provider."
+	errMsgInvalidEnvelope    = "Value is not an encrypted envelope."
+)
+
+// A map of all KeyProviders available for use (with some defaults).
+var keyProviders = map[string]KeyProvider{
+	"local": LocalKeyProvider{},
+	"kms":   KMSKeyProvider{},
+}
+
+// RegisterKeyProvider allows anyone importing discfg into their own project to register new key providers or overwrite the defaults.
+func RegisterKeyProvider(name string, provider KeyProvider) {
+	keyProviders[name] = provider
+}
+
+// ListKeyProviders returns the list of available key providers.
+func ListKeyProviders() map[string]KeyProvider {
+	return keyProviders
+}
+
+// Encrypt encrypts a value for the config name and key in the options using a new data key from the key provider set
+// in the options, returning the envelope (JSON).
+func Encrypt(opts config.Options, plaintext []byte) ([]byte, error) {
+	p, ok := keyProviders[opts.Encryption.Provider]
+	if !ok {
+		return nil, errors.New(errMsgInvalidKeyProvider)
+	}
+	dataKey, encryptedKey, err := p.GenerateDataKey(opts)
+	if err != nil {
+		return nil, err
+	}
+	nonce, data, err := seal(dataKey, plaintext, additionalData(opts))
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(Envelope{
+		Provider: opts.Encryption.Provider,
+		Key:      encryptedKey,
+		Nonce:    nonce,
+		Data:     data,
+	})
+}
+
+// Decrypt decrypts an envelope (JSON) using the key provider that encrypted it. The config name and key in the
+// options must be the ones it was encrypted for.
+func Decrypt(opts config.Options, envelope []byte) ([]byte, error) {
+	var e Envelope
+	if err := json.Unmarshal(envelope, &e); err != nil || e.Data == nil {
+		return nil, errors.New(errMsgInvalidEnvelope)
+	}
+	p, ok := keyProviders[e.Provider]
+	if !ok {
+		return nil, errors.New(errMsgInvalidKeyProvider)
+	}
+	dataKey, err := p.DecryptDataKey(opts, e.Key)
+	if err != nil {
+		return nil, err
+	}
+	return open(dataKey, e.Nonce, e.Data, additionalData(opts))
+}
+
+// additionalData returns what a value is bound to, the config name and key
+func additionalData(opts config.Options) []byte {
+	return []byte(opts.CfgName + "\x00" + opts.Key)
+}
+
+// seal encrypts with AES-GCM, authenticating the additional data too, returning a random nonce and the ciphertext.
+func seal(key []byte, plaintext []byte, additional []byte) ([]byte, []byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, nil, err
+	}
+	return nonce, gcm.Seal(nil, nonce, plaintext, additional), nil
+}
+
+// open decrypts AES-GCM ciphertext, failing unless the additional data is what it was sealed with.
+func open(key []byte, nonce []byte, ciphertext []byte, additional []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, errors.New(errMsgInvalidEnvelope)
+	}
+	return gcm.Open(nil, nonce, ciphertext, additional)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,75 @@
+package encryption
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/tmaiaroto/discfg/config"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncrypt(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "discfg")
+	defer os.RemoveAll(dir)
+	keyFile := filepath.Join(dir, "key")
+	_ = ioutil.WriteFile(keyFile, []byte("MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=\n"), 0600)
+	otherKeyFile := filepath.Join(dir, "other")
+	_ = ioutil.WriteFile(otherKeyFile, []byte("0123456789abcdef0123456789abcdeX"), 0600)
+
+	opts := config.Options{CfgName: "mycfg", Key: "db/password"}
+	opts.Encryption.Provider = "local"
+	opts.Encryption.KeyFile = keyFile
+
+	Convey("A value should be encrypted into an envelope and decrypted back", t, func() {
+		envelope, err := Encrypt(opts, []byte("secret value"))
+		So(err, ShouldBeNil)
+		So(string(envelope), ShouldNotContainSubstring, "secret value")
+		So(string(envelope), ShouldContainSubstring, `"provider":"local"`)
+
+		plaintext, err := Decrypt(opts, envelope)
+		So(err, ShouldBeNil)
+		So(string(plaintext), ShouldEqual, "secret value")
+	})
+
+	Convey("A value should not decrypt with a different key", t, func() {
+		envelope, _ := Encrypt(opts, []byte("secret value"))
+		otherOpts := opts
+		otherOpts.Encryption.KeyFile = otherKeyFile
+		_, err := Decrypt(otherOpts, envelope)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("A value should not decrypt for another key or config", t, func() {
+		envelope, _ := Encrypt(opts, []byte("secret value"))
+		otherKey := opts
+		otherKey.Key = "feature/flag"
+		_, err := Decrypt(otherKey, envelope)
+		So(err, ShouldNotBeNil)
+		otherCfg := opts
+		otherCfg.CfgName = "othercfg"
+		_, err = Decrypt(otherCfg, envelope)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("A valid key provider must be used", t, func() {
+		invalidOpts := opts
+		invalidOpts.Encryption.Provider = "invalid"
+		_, err := Encrypt(invalidOpts, []byte("secret value"))
+		So(err.Error(), ShouldEqual, errMsgInvalidKeyProvider)
+	})
+
+	Convey("A value that isn't an envelope can't be decrypted", t, func() {
+		_, err := Decrypt(opts, []byte("plain value"))
+		So(err.Error(), ShouldEqual, errMsgInvalidEnvelope)
+	})
+
+	Convey("A key file must hold a 32 byte key", t, func() {
+		badKeyFile := filepath.Join(dir, "bad")
+		_ = ioutil.WriteFile(badKeyFile, []byte("too short"), 0600)
+		badOpts := opts
+		badOpts.Encryption.KeyFile = badKeyFile
+		_, err := Encrypt(badOpts, []byte("secret value"))
+		So(err.Error(), ShouldEqual, errMsgInvalidKeyFile)
+	})
+}
@@ -0,0 +1,59 @@
+package encryption
+
+import (
+	"errors"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/tmaiaroto/discfg/awssession"
+	"github.com/tmaiaroto/discfg/config"
+)
+
+const errMsgMissingKMSKeyID = "A KMS key id (or alias) is required to encrypt with KMS."
+
+// KMSKeyProvider uses AWS Key Management Service to generate and decrypt data keys. The master key never leaves KMS
+// so access to values can be controlled (and audited) with IAM.
+type KMSKeyProvider struct {
+}
+
+// GenerateDataKey asks KMS for a new data key under the configured KMS key id.
+func (p KMSKeyProvider) GenerateDataKey(opts config.Options) ([]byte, []byte, error) {
+	if opts.Encryption.KMSKeyID == "" {
+		return nil, nil, errors.New(errMsgMissingKMSKeyID)
+	}
+	svc, err := kmsSvc(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := svc.GenerateDataKeyWithContext(opts.ContextOrBackground(), &kms.GenerateDataKeyInput{
+		KeyId:   aws.String(opts.Encryption.KMSKeyID),
+		KeySpec: aws.String("AES_256"),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp.Plaintext, resp.CiphertextBlob, nil
+}
+
+// DecryptDataKey asks KMS to decrypt a data key. The encrypted key identifies the KMS key, so no key id is needed.
+func (p KMSKeyProvider) DecryptDataKey(opts config.Options, encryptedKey []byte) ([]byte, error) {
+	svc, err := kmsSvc(opts)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := svc.DecryptWithContext(opts.ContextOrBackground(), &kms.DecryptInput{
+		CiphertextBlob: encryptedKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Plaintext, nil
+}
+
+// kmsSvc returns the KMS service client to use, with the same session (and credentials) as storage.
+func kmsSvc(opts config.Options) (*kms.KMS, error) {
+	sess, err := awssession.Get(opts)
+	if err != nil {
+		return nil, err
+	}
+	return kms.New(sess), nil
+}
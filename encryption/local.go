@@ -0,0 +1,80 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"github.com/tmaiaroto/discfg/config"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// DefaultKeyFile is used by the local key provider when no key file was given. It's relative to the user's home directory.
+const DefaultKeyFile = ".discfg.key"
+
+const errMsgInvalidKeyFile = "Key file must contain a 32 byte key (raw or base64 encoded)."
+
+// LocalKeyProvider encrypts data keys with a master key read from a local file, so it works offline.
+// The file should hold 32 random bytes, either raw or base64 encoded, for example:
+//
+//	head -c 32 /dev/urandom | base64 > ~/.discfg.key
+//
+// Anyone with the file can decrypt values, so keep it safe (and backed up, without it values can't be decrypted).
+type LocalKeyProvider struct {
+}
+
+// GenerateDataKey creates a random data key and encrypts it with the master key from the key file.
+func (p LocalKeyProvider) GenerateDataKey(opts config.Options) ([]byte, []byte, error) {
+	masterKey, err := readKeyFile(opts.Encryption.KeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	dataKey := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, nil, err
+	}
+	nonce, encryptedKey, err := seal(masterKey, dataKey, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	// The nonce is kept with the encrypted key.
+	return dataKey, append(nonce, encryptedKey...), nil
+}
+
+// DecryptDataKey decrypts a data key with the master key from the key file.
+func (p LocalKeyProvider) DecryptDataKey(opts config.Options, encryptedKey []byte) ([]byte, error) {
+	masterKey, err := readKeyFile(opts.Encryption.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(encryptedKey) < gcm.NonceSize() {
+		return nil, errors.New(errMsgInvalidEnvelope)
+	}
+	return open(masterKey, encryptedKey[:gcm.NonceSize()], encryptedKey[gcm.NonceSize():], nil)
+}
+
+// readKeyFile reads a 32 byte master key from a file (the default file under the home directory if no path is given).
+func readKeyFile(path string) ([]byte, error) {
+	if path == "" {
+		path = filepath.Join(os.Getenv("HOME"), DefaultKeyFile)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 32 {
+		return b, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(b)))
+	if err != nil || len(key) != 32 {
+		return nil, errors.New(errMsgInvalidKeyFile)
+	}
+	return key, nil
+}
@@ -0,0 +1,160 @@
+package lock
+
+import (
+	"errors"
+	"github.com/tmaiaroto/discfg/config"
+	"sync"
+	"time"
+)
+
+// ErrCampaignStopped is returned when a campaign is stopped before being elected
+var ErrCampaignStopped = errors.New("Campaign stopped")
+
+// Election elects a single leader among many owners campaigning for the same lock. The leader's lease is renewed
+// in the background (every third of its TTL) until it resigns or leadership is lost.
+type Election struct {
+	Name  string
+	Owner string
+	TTL   time.Duration
+	opts  config.Options
+
+	mu    sync.Mutex
+	lease *Lease
+	// Closed to stop renewing the lease
+	done chan struct{}
+	// Closed once leadership is lost (or resigned)
+	lost chan struct{}
+}
+
+// NewElection returns an Election for the named lock. Each campaigning process needs a unique owner ID.
+func NewElection(opts config.Options, name string, owner string, ttl time.Duration) *Election {
+	return &Election{Name: name, Owner: owner, TTL: leaseTTL(ttl), opts: opts}
+}
+
+// Campaign blocks until elected leader or the stop channel is closed. Once elected, the returned channel is closed
+// should leadership be lost. Errors other than the lock being held by another owner end the campaign.
+func (e *Election) Campaign(stop <-chan struct{}) (<-chan struct{}, error) {
+	for {
+		if lost, ok := e.leading(); ok {
+			return lost, nil
+		}
+		lease, err := Acquire(e.opts, e.Name, e.Owner, e.TTL)
+		if err == nil {
+			return e.elected(lease), nil
+		}
+		if err != ErrNotAcquired {
+			return nil, err
+		}
+		select {
+		case <-stop:
+			return nil, ErrCampaignStopped
+		case <-time.After(e.TTL / 3):
+		}
+	}
+}
+
+// Do runs the function only if elected right away, which suits scheduled jobs (such as Lambda functions run on
+// a schedule) where only one of many invocations should do the work. The lease is renewed while the function runs
+// and released afterwards. Whether or not the function ran is returned along with its error.
+func (e *Election) Do(fn func() error) (bool, error) {
+	lease, err := Acquire(e.opts, e.Name, e.Owner, e.TTL)
+	if err == ErrNotAcquired {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	e.elected(lease)
+	err = fn()
+	if resignErr := e.Resign(); err == nil {
+		err = resignErr
+	}
+	return true, err
+}
+
+// IsLeader returns whether or not this owner is currently the leader
+func (e *Election) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.lease != nil && time.Now().Before(e.lease.Expiration)
+}
+
+// Resign stops renewing the lease and releases the lock so another owner can be elected
+func (e *Election) Resign() error {
+	e.mu.Lock()
+	lease, done, lost := e.lease, e.done, e.lost
+	e.lease, e.done, e.lost = nil, nil, nil
+	e.mu.Unlock()
+
+	if done == nil {
+		return nil
+	}
+	close(done)
+	<-lost
+	if lease == nil {
+		return nil
+	}
+	err := Release(e.opts, *lease)
+	if err == ErrNotAcquired {
+		// Leadership was lost already, there's nothing to release
+		return nil
+	}
+	return err
+}
+
+// leading returns the lost channel when already the leader
+func (e *Election) leading() (<-chan struct{}, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.lease != nil && time.Now().Before(e.lease.Expiration) {
+		return e.lost, true
+	}
+	return nil, false
+}
+
+// elected keeps the lease and starts renewing it
+func (e *Election) elected(lease Lease) <-chan struct{} {
+	done := make(chan struct{})
+	lost := make(chan struct{})
+	e.mu.Lock()
+	e.lease, e.done, e.lost = &lease, done, lost
+	e.mu.Unlock()
+
+	go e.renew(lease, done, lost)
+	return lost
+}
+
+// renew renews the lease until done is closed or the lease can't be renewed. Failures other than losing the lock
+// are retried until the lease expires.
+func (e *Election) renew(lease Lease, done chan struct{}, lost chan struct{}) {
+	defer close(lost)
+	ticker := time.NewTicker(lease.TTL / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			renewed, err := Renew(e.opts, lease)
+			if err == nil {
+				lease = renewed
+				e.setLease(done, &lease)
+				continue
+			}
+			if err == ErrLost || time.Now().After(lease.Expiration) {
+				e.setLease(done, nil)
+				return
+			}
+		}
+	}
+}
+
+// setLease sets the lease unless the renewal it came from has since been stopped
+func (e *Election) setLease(done chan struct{}, lease *Lease) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.done == done {
+		e.lease = lease
+	}
+}
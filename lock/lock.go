@@ -0,0 +1,180 @@
+// Package lock provides lease based locks (and leader election) on top of a discfg configuration.
+// A lock is a key holding the owner's ID with a TTL. It is acquired with a conditional write that only succeeds when
+// the key doesn't exist or has expired, so only one owner can hold it at a time. Owners must renew the lease
+// before it expires or they lose the lock.
+package lock
+
+import (
+	"errors"
+	"github.com/tmaiaroto/discfg/config"
+	"github.com/tmaiaroto/discfg/storage"
+	"os"
+	"strings"
+	"time"
+)
+
+// KeyPrefix defines the reserved namespace for lock keys
+const KeyPrefix = "/_lock/"
+
+// DefaultTTL is used for leases when no TTL is given
+const DefaultTTL = 60 * time.Second
+
+// ErrNotAcquired is returned when a lock is held by another owner
+var ErrNotAcquired = errors.New("Lock is held by another owner")
+
+// ErrLost is returned when renewing a lease for a lock that is no longer held (it expired or was taken by another owner)
+var ErrLost = errors.New("Lock is no longer held")
+
+// ErrMissingName is returned when no lock name was given
+var ErrMissingName = errors.New("Lock name is required")
+
+// ErrMissingOwner is returned when no owner was given
+var ErrMissingOwner = errors.New("Lock owner is required")
+
+// Lease is a held lock
+type Lease struct {
+	Name       string
+	Owner      string
+	TTL        time.Duration
+	Expiration time.Time
+	// The lock key's version, it advances each time the lock is acquired
+	Version int64
+}
+
+// Key returns the key a lock is stored under
+func Key(name string) string {
+	return KeyPrefix + strings.TrimPrefix(name, "/")
+}
+
+// DefaultOwner returns an owner ID for the current host. Processes on the same host sharing a lock should use
+// their own, more unique, owner IDs.
+func DefaultOwner() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		return "unknown"
+	}
+	return hostname
+}
+
+// Acquire acquires a lock for the owner if it's free (or expired). An owner acquiring a lock it already holds
+// simply gets a new lease. ErrNotAcquired is returned if another owner holds the lock.
+func Acquire(opts config.Options, name string, owner string, ttl time.Duration) (Lease, error) {
+	lease := Lease{Name: name, Owner: owner, TTL: leaseTTL(ttl)}
+	opts, err := lockOptions(opts, lease)
+	if err != nil {
+		return lease, err
+	}
+	opts.Value = []byte(owner)
+	opts.ValueType = config.ValueTypeString
+	opts.ConditionalNotExists = true
+	opts.ConditionalValue = owner
+
+	// The stored expiration is no earlier than this, however long the write takes (retries included)
+	start := time.Now()
+	item, err := storage.Update(opts)
+	if err != nil {
+		return lease, checkHolder(opts, lease, ErrNotAcquired, err)
+	}
+	lease.Version = item.Version
+	lease.Expiration = start.Add(lease.TTL)
+	return lease, nil
+}
+
+// Renew extends a lease by its TTL. ErrLost is returned if the lock expired or is held by another owner.
+func Renew(opts config.Options, lease Lease) (Lease, error) {
+	lease.TTL = leaseTTL(lease.TTL)
+	opts, err := lockOptions(opts, lease)
+	if err != nil {
+		return lease, err
+	}
+	opts.ConditionalValue = lease.Owner
+
+	// As with Acquire, the lease never outlives the stored expiration
+	start := time.Now()
+	if _, err = storage.Touch(opts); err != nil {
+		return lease, checkHolder(opts, lease, ErrLost, err)
+	}
+	lease.Expiration = start.Add(lease.TTL)
+	return lease, nil
+}
+
+// Release releases a lock held by the lease's owner. Releasing a lock held by another owner returns ErrNotAcquired
+// and leaves the lock alone, releasing a free lock is not an error.
+func Release(opts config.Options, lease Lease) error {
+	opts, err := lockOptions(opts, lease)
+	if err != nil {
+		return err
+	}
+	holder, err := Holder(opts, lease.Name)
+	if err != nil || holder == "" {
+		return err
+	}
+	if holder != lease.Owner {
+		return ErrNotAcquired
+	}
+	// Still conditional, should the lease expire and the lock be acquired by another owner in the meantime
+	opts.ConditionalValue = lease.Owner
+
+	if _, err = storage.Delete(opts); err != nil {
+		return checkHolder(opts, lease, ErrNotAcquired, err)
+	}
+	return nil
+}
+
+// Holder returns the owner currently holding a lock, an empty string means the lock is free.
+func Holder(opts config.Options, name string) (string, error) {
+	opts.Key = Key(name)
+	opts.Unversioned = true
+	item, err := storage.Get(opts)
+	if err != nil {
+		return "", err
+	}
+	value, _ := item.Value.([]byte)
+	return string(value), nil
+}
+
+// lockOptions validates a lease and sets the options needed to work with its lock key. Options that don't apply
+// to locks (set by the CLI for example) are cleared.
+func lockOptions(opts config.Options, lease Lease) (config.Options, error) {
+	if lease.Name == "" {
+		return opts, ErrMissingName
+	}
+	if lease.Owner == "" {
+		return opts, ErrMissingOwner
+	}
+	// Lock keys aren't part of the config, so they don't change its version and work while it's frozen
+	opts.Key = Key(lease.Name)
+	opts.Unversioned = true
+	opts.TTL = int64(lease.TTL / time.Second)
+	opts.Value = nil
+	opts.ValueType = ""
+	opts.Encrypt = false
+	opts.Sensitive = nil
+	opts.ConditionalValue = ""
+	opts.ConditionalVersion = 0
+	opts.ConditionalUnset = false
+	opts.ConditionalNotExists = false
+	opts.ConditionalExpired = false
+	return opts, nil
+}
+
+// checkHolder returns the given lock error if the lease's owner no longer holds the lock, otherwise the failure
+// was something else (a network error for example) and the original error is returned.
+func checkHolder(opts config.Options, lease Lease, lockErr error, err error) error {
+	holder, holderErr := Holder(opts, lease.Name)
+	if holderErr == nil && holder != lease.Owner {
+		return lockErr
+	}
+	return err
+}
+
+// leaseTTL returns the TTL for a lease, storage TTLs are in whole seconds.
+func leaseTTL(ttl time.Duration) time.Duration {
+	if ttl <= 0 {
+		return DefaultTTL
+	}
+	if ttl < time.Second {
+		return time.Second
+	}
+	return ttl - ttl%time.Second
+}
@@ -0,0 +1,161 @@
+package lock
+
+import (
+	"errors"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/tmaiaroto/discfg/config"
+	"github.com/tmaiaroto/discfg/storage"
+	"github.com/tmaiaroto/discfg/storage/mockdb"
+	"testing"
+	"time"
+)
+
+var opts = config.Options{StorageInterfaceName: "mock", Version: "0.0.0", CfgName: "mockcfg"}
+
+// Locks are keys in the mock config, which other tests rely on being left as it was.
+func restoreMockCfg() func() {
+	root := mockdb.MockCfg["mockcfg"]["/"]
+	return func() {
+		mockdb.MockCfg["mockcfg"]["/"] = root
+		delete(mockdb.MockCfg["mockcfg"], Key("job"))
+	}
+}
+
+func TestAcquire(t *testing.T) {
+	defer restoreMockCfg()()
+
+	Convey("Should acquire a free lock and not let another owner acquire it", t, func() {
+		storage.RegisterShipper("mock", mockdb.MockShipper{})
+		lease, err := Acquire(opts, "job", "a", time.Minute)
+		So(err, ShouldBeNil)
+		So(lease.Owner, ShouldEqual, "a")
+		So(lease.Expiration, ShouldHappenAfter, time.Now())
+		// The lease never outlives the lock as stored
+		So(lease.Expiration, ShouldHappenOnOrBefore, mockdb.MockCfg["mockcfg"][Key("job")].Expiration)
+		So(string(mockdb.MockCfg["mockcfg"][Key("job")].Value.([]byte)), ShouldEqual, "a")
+
+		_, err = Acquire(opts, "job", "b", time.Minute)
+		So(err, ShouldEqual, ErrNotAcquired)
+
+		// Acquiring again by the same owner is fine
+		_, err = Acquire(opts, "job", "a", time.Minute)
+		So(err, ShouldBeNil)
+
+		holder, _ := Holder(opts, "job")
+		So(holder, ShouldEqual, "a")
+	})
+
+	Convey("Should acquire an expired lock", t, func() {
+		storage.RegisterShipper("mock", mockdb.MockShipper{})
+		item := mockdb.MockCfg["mockcfg"][Key("job")]
+		item.Expiration = time.Now().Add(-time.Second)
+		mockdb.MockCfg["mockcfg"][Key("job")] = item
+
+		lease, err := Acquire(opts, "job", "b", time.Minute)
+		So(err, ShouldBeNil)
+		So(lease.Owner, ShouldEqual, "b")
+	})
+
+	Convey("Should require a name and an owner", t, func() {
+		_, err := Acquire(opts, "", "a", time.Minute)
+		So(err, ShouldEqual, ErrMissingName)
+		_, err = Acquire(opts, "job", "", time.Minute)
+		So(err, ShouldEqual, ErrMissingOwner)
+	})
+}
+
+func TestRenewAndRelease(t *testing.T) {
+	defer restoreMockCfg()()
+
+	Convey("Should only renew and release a lock held by the owner", t, func() {
+		storage.RegisterShipper("mock", mockdb.MockShipper{})
+		lease, err := Acquire(opts, "job", "a", 2*time.Second)
+		So(err, ShouldBeNil)
+
+		_, err = Renew(opts, Lease{Name: "job", Owner: "b", TTL: time.Minute})
+		So(err, ShouldEqual, ErrLost)
+		So(Release(opts, Lease{Name: "job", Owner: "b"}), ShouldEqual, ErrNotAcquired)
+
+		lease.TTL = time.Minute
+		lease, err = Renew(opts, lease)
+		So(err, ShouldBeNil)
+		So(mockdb.MockCfg["mockcfg"][Key("job")].TTL, ShouldEqual, int64(60))
+		So(lease.Expiration, ShouldHappenOnOrBefore, mockdb.MockCfg["mockcfg"][Key("job")].Expiration)
+
+		So(Release(opts, lease), ShouldBeNil)
+		holder, _ := Holder(opts, "job")
+		So(holder, ShouldEqual, "")
+		// Releasing a free lock is fine
+		So(Release(opts, lease), ShouldBeNil)
+	})
+}
+
+func TestUnversioned(t *testing.T) {
+	defer restoreMockCfg()()
+
+	Convey("Should leave the config version alone and work while the config is frozen", t, func() {
+		storage.RegisterShipper("mock", mockdb.MockShipper{})
+		version := mockdb.MockCfg["mockcfg"]["/"].CfgVersion
+		lease, err := Acquire(opts, "job", "a", time.Minute)
+		So(err, ShouldBeNil)
+		So(mockdb.MockCfg["mockcfg"]["/"].CfgVersion, ShouldEqual, version)
+
+		So(storage.Freeze(opts, true), ShouldBeNil)
+		_, err = Acquire(opts, "job", "b", time.Minute)
+		So(err, ShouldEqual, ErrNotAcquired)
+		lease, err = Renew(opts, lease)
+		So(err, ShouldBeNil)
+		So(Release(opts, lease), ShouldBeNil)
+		So(mockdb.MockCfg["mockcfg"]["/"].CfgVersion, ShouldEqual, version)
+	})
+}
+
+func TestElection(t *testing.T) {
+	defer restoreMockCfg()()
+
+	Convey("Should only run for the elected leader", t, func() {
+		storage.RegisterShipper("mock", mockdb.MockShipper{})
+		leader := NewElection(opts, "job", "a", time.Minute)
+		follower := NewElection(opts, "job", "b", time.Minute)
+
+		ran, err := leader.Do(func() error {
+			So(leader.IsLeader(), ShouldBeTrue)
+			followerRan, followerErr := follower.Do(func() error { return nil })
+			So(followerRan, ShouldBeFalse)
+			So(followerErr, ShouldBeNil)
+			return errors.New("job failed")
+		})
+		So(ran, ShouldBeTrue)
+		So(err.Error(), ShouldEqual, "job failed")
+		So(leader.IsLeader(), ShouldBeFalse)
+
+		// The lock was released, so the follower can now run
+		ran, err = follower.Do(func() error { return nil })
+		So(ran, ShouldBeTrue)
+		So(err, ShouldBeNil)
+	})
+
+	Convey("Should campaign until elected or stopped", t, func() {
+		storage.RegisterShipper("mock", mockdb.MockShipper{})
+		leader := NewElection(opts, "job", "a", 3*time.Second)
+		follower := NewElection(opts, "job", "b", 3*time.Second)
+
+		lost, err := leader.Campaign(nil)
+		So(err, ShouldBeNil)
+		So(leader.IsLeader(), ShouldBeTrue)
+
+		stop := make(chan struct{})
+		close(stop)
+		_, err = follower.Campaign(stop)
+		So(err, ShouldEqual, ErrCampaignStopped)
+
+		So(leader.Resign(), ShouldBeNil)
+		<-lost
+		So(leader.IsLeader(), ShouldBeFalse)
+
+		_, err = follower.Campaign(nil)
+		So(err, ShouldBeNil)
+		So(follower.IsLeader(), ShouldBeTrue)
+		So(follower.Resign(), ShouldBeNil)
+	})
+}
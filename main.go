@@ -3,14 +3,19 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/spf13/cobra"
+	"github.com/tmaiaroto/discfg/audit"
+	"github.com/tmaiaroto/discfg/auth"
 	"github.com/tmaiaroto/discfg/commands"
 	"github.com/tmaiaroto/discfg/config"
+	"github.com/tmaiaroto/discfg/lock"
 	"github.com/tmaiaroto/discfg/version"
 	"io/ioutil"
 	"os"
+	"strconv"
 	"time"
 )
 
@@ -20,15 +25,66 @@ var _ bytes.Buffer
 // Options for the configuration
 var Options = config.Options{StorageInterfaceName: "dynamodb", Version: version.Semantic}
 
+// timeout for storage calls (0 is no timeout)
+var timeout time.Duration
+var cancelTimeout context.CancelFunc
+
 // dataFile for loading data for a key from file using the CLI
 var dataFile = ""
 
+// sensitive marks (or with --sensitive=false, unmarks) a key as sensitive, only when given
+var sensitive = false
+
+// Lock command options
+var lockOwner = ""
+
+// Config create/update/delete options, to wait for the change to finish
+var wait = false
+var waitTimeout time.Duration
+
+// invalidSettingsMsg is output when the settings given to create or update a config aren't a JSON object
+const invalidSettingsMsg = "Invalid settings, they must be a JSON object"
+
+// Increment options, a flag so negative deltas aren't taken for flags themselves
+var incrBy = ""
+
+// Config migrate options
+var migrateTo = ""
+var migrateDelete = false
+
+// Audit command options
+var auditFrom = ""
+var auditTo = ""
+
+// Token command options
+var tokenPolicies = []string{}
+
+// Template command options
+var templateInput = ""
+var templateOutput = ""
+var templateWatch = false
+var templateInterval = 5 * time.Second
+var templateReload = ""
+
 // DiscfgCmd defines the parent discfg command
 var DiscfgCmd = &cobra.Command{
 	Use:   "discfg",
 	Short: "discfg is a distributed configuration service",
 	Long:  `A distributed configuration service using Amazon Web Services.`,
 	Run:   func(cmd *cobra.Command, args []string) {},
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if timeout > 0 {
+			Options.Context, cancelTimeout = context.WithTimeout(context.Background(), timeout)
+		}
+		if cmd.Flags().Changed("sensitive") {
+			Options.Sensitive = &sensitive
+		}
+	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		if cancelTimeout != nil {
+			cancelTimeout()
+		}
+	},
 }
 
 // versionCmd displays the discfg version
@@ -82,13 +138,14 @@ var createCfgCmd = &cobra.Command{
 			break
 		case 2:
 			Options.CfgName = args[0]
-			if err := json.Unmarshal([]byte(args[0]), &settings); err != nil {
-				commands.Out(Options, config.ResponseObject{Action: "create", Error: err.Error()})
+			if err := json.Unmarshal([]byte(args[1]), &settings); err != nil {
+				commands.Out(Options, config.ResponseObject{Action: "create", Error: err.Error(), Message: invalidSettingsMsg})
+				return
 			}
 		}
 
 		resp := commands.CreateCfg(Options, settings)
-		commands.Out(Options, resp)
+		commands.Out(Options, waitForCfg(resp, false))
 	},
 }
 var deleteCfgCmd = &cobra.Command{
@@ -98,17 +155,12 @@ var deleteCfgCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		if len(args) > 0 {
 			Options.CfgName = args[0]
-			// Confirmation
-			inputReader := bufio.NewReader(os.Stdin)
-			cfgCmd.Print("Are you sure? [Y/n] ")
-			input, _ := inputReader.ReadString('\n')
-			if input != "Y\n" {
-				DiscfgCmd.Println("Aborted")
+			if !confirm() {
 				return
 			}
 		}
 		resp := commands.DeleteCfg(Options)
-		commands.Out(Options, resp)
+		commands.Out(Options, waitForCfg(resp, true))
 	},
 }
 var updateCfgCmd = &cobra.Command{
@@ -123,16 +175,147 @@ var updateCfgCmd = &cobra.Command{
 		switch len(args) {
 		case 1:
 			if err := json.Unmarshal([]byte(args[0]), &settings); err != nil {
-				commands.Out(Options, config.ResponseObject{Action: "update", Error: err.Error()})
+				commands.Out(Options, config.ResponseObject{Action: "update", Error: err.Error(), Message: invalidSettingsMsg})
+				return
 			}
 			break
 		case 2:
 			Options.CfgName = args[0]
-			if err := json.Unmarshal([]byte(args[0]), &settings); err != nil {
-				commands.Out(Options, config.ResponseObject{Action: "update", Error: err.Error()})
+			if err := json.Unmarshal([]byte(args[1]), &settings); err != nil {
+				commands.Out(Options, config.ResponseObject{Action: "update", Error: err.Error(), Message: invalidSettingsMsg})
+				return
 			}
 		}
 		resp := commands.UpdateCfg(Options, settings)
+		commands.Out(Options, waitForCfg(resp, false))
+	},
+}
+var listCfgCmd = &cobra.Command{
+	Use:   "list",
+	Short: "list configs",
+	Long:  `Lists the discfg configurations in storage (for DynamoDB, in the region) with their state and version`,
+	Run: func(cmd *cobra.Command, args []string) {
+		resp := commands.ListCfgs(Options)
+		commands.Out(Options, resp)
+	},
+}
+var cloneCfgCmd = &cobra.Command{
+	Use:   "clone",
+	Short: "clone config",
+	Long:  `Copies a discfg configuration, with its settings and every key, to a new configuration`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 2 {
+			commands.Out(Options, config.ResponseObject{Action: "clone cfg", Error: commands.NotEnoughArgsMsg})
+			return
+		}
+		Options.CfgName = args[0]
+		resp := commands.CloneCfg(Options, args[1], copyWaitTimeout())
+		commands.Out(Options, resp)
+	},
+}
+var renameCfgCmd = &cobra.Command{
+	Use:   "rename",
+	Short: "rename config",
+	Long:  `Renames a discfg configuration by copying it to a new configuration, then deleting the original once the copy is verified`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 2 {
+			commands.Out(Options, config.ResponseObject{Action: "rename cfg", Error: commands.NotEnoughArgsMsg})
+			return
+		}
+		Options.CfgName = args[0]
+		resp := commands.RenameCfg(Options, args[1], copyWaitTimeout())
+		// Keep using the config by its new name
+		if resp.Error == "" && commands.GetDiscfgNameFromFile() == args[0] {
+			useOpts := Options
+			useOpts.CfgName = args[1]
+			commands.Use(useOpts)
+		}
+		commands.Out(Options, resp)
+	},
+}
+var metaCfgCmd = &cobra.Command{
+	Use:   "meta",
+	Short: "config metadata",
+	Long:  `Gets or sets a config's metadata: owner, description, tags and a free-form JSON annotation`,
+	Run: func(cmd *cobra.Command, args []string) {
+	},
+}
+var setMetaCfgCmd = &cobra.Command{
+	Use:   "set",
+	Short: "set config metadata",
+	Long:  `Sets a config's metadata from a JSON object, ie. '{"owner": "platform", "tags": {"env": "prod"}}' (only the fields given change)`,
+	Run: func(cmd *cobra.Command, args []string) {
+		Options.CfgName = commands.GetDiscfgNameFromFile()
+		var changes []byte
+		switch len(args) {
+		case 1:
+			changes = []byte(args[0])
+		case 2:
+			Options.CfgName = args[0]
+			changes = []byte(args[1])
+		default:
+			commands.Out(Options, config.ResponseObject{Action: "set cfg meta", Error: commands.NotEnoughArgsMsg})
+			return
+		}
+		resp := commands.SetCfgMeta(Options, changes)
+		commands.Out(Options, resp)
+	},
+}
+var getMetaCfgCmd = &cobra.Command{
+	Use:   "get",
+	Short: "get config metadata",
+	Long:  `Gets a config's metadata`,
+	Run: func(cmd *cobra.Command, args []string) {
+		Options.CfgName = commands.GetDiscfgNameFromFile()
+		if len(args) > 0 {
+			Options.CfgName = args[0]
+		}
+		resp := commands.GetCfgMeta(Options)
+		commands.Out(Options, resp)
+	},
+}
+var freezeCfgCmd = &cobra.Command{
+	Use:   "freeze",
+	Short: "freeze config",
+	Long:  `Freezes a discfg distributed configuration so its keys can't be changed, ie. during a release freeze`,
+	Run: func(cmd *cobra.Command, args []string) {
+		Options.CfgName = commands.GetDiscfgNameFromFile()
+		if len(args) > 0 {
+			Options.CfgName = args[0]
+		}
+		if Options.CfgName != "" && !confirm() {
+			return
+		}
+		resp := commands.FreezeCfg(Options, true)
+		commands.Out(Options, resp)
+	},
+}
+var unfreezeCfgCmd = &cobra.Command{
+	Use:   "unfreeze",
+	Short: "unfreeze config",
+	Long:  `Unfreezes a frozen discfg distributed configuration so its keys can be changed again`,
+	Run: func(cmd *cobra.Command, args []string) {
+		Options.CfgName = commands.GetDiscfgNameFromFile()
+		if len(args) > 0 {
+			Options.CfgName = args[0]
+		}
+		if Options.CfgName != "" && !confirm() {
+			return
+		}
+		resp := commands.FreezeCfg(Options, false)
+		commands.Out(Options, resp)
+	},
+}
+var migrateCfgCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "migrate config storage",
+	Long:  `Copies a discfg configuration to another storage engine, ie. from its own DynamoDB table into a shared table`,
+	Run: func(cmd *cobra.Command, args []string) {
+		Options.CfgName = commands.GetDiscfgNameFromFile()
+		if len(args) > 0 {
+			Options.CfgName = args[0]
+		}
+		resp := commands.MigrateCfg(Options, migrateTo, migrateDelete)
 		commands.Out(Options, resp)
 	},
 }
@@ -177,6 +360,175 @@ var deleteCmd = &cobra.Command{
 		commands.Out(Options, resp)
 	},
 }
+var touchCmd = &cobra.Command{
+	Use:   "touch",
+	Short: "reset key TTL",
+	Long:  `Resets the TTL for a key without changing its value for a given discfg`,
+	Run: func(cmd *cobra.Command, args []string) {
+		setOptsFromArgs(args)
+		resp := commands.TouchKey(Options)
+		commands.Out(Options, resp)
+	},
+}
+var incrCmd = &cobra.Command{
+	Use:   "incr",
+	Short: "increment key value",
+	Long:  `Atomically adds to a key's number value (by 1 unless a delta is given, --by for negative ones) for a given discfg`,
+	Run: func(cmd *cobra.Command, args []string) {
+		setOptsFromArgs(args)
+		delta, err := incrDelta(incrBy, Options.Value)
+		if err != nil {
+			commands.Out(Options, config.ResponseObject{Action: "incr", Error: "Invalid delta, must be a number"})
+			return
+		}
+		resp := commands.IncrementKey(Options, delta)
+		commands.Out(Options, resp)
+	},
+}
+var lsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "list keys",
+	Long:  `Lists keys beginning with a given prefix (or all keys) for a given discfg`,
+	Run: func(cmd *cobra.Command, args []string) {
+		setOptsFromArgs(args)
+		resp := commands.ListKeys(Options)
+		commands.Out(Options, resp)
+	},
+}
+var gcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "delete expired keys",
+	Long:  `Deletes expired keys that storage has not yet removed for a given discfg`,
+	Run: func(cmd *cobra.Command, args []string) {
+		setOptsFromArgs(args)
+		resp := commands.GC(Options)
+		commands.Out(Options, resp)
+	},
+}
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "validate key values",
+	Long:  `Validates every key value against the JSON Schemas (stored under /_schema/) for a given discfg`,
+	Run: func(cmd *cobra.Command, args []string) {
+		setOptsFromArgs(args)
+		resp := commands.Validate(Options)
+		commands.Out(Options, resp)
+	},
+}
+var templateCmd = &cobra.Command{
+	Use:   "template",
+	Short: "render a template",
+	Long:  `Renders a Go text/template file using key values from a given discfg`,
+	Run: func(cmd *cobra.Command, args []string) {
+		setOptsFromArgs(args)
+		if templateWatch {
+			commands.WatchTemplate(Options, templateInput, templateOutput, templateInterval, templateReload, func(resp config.ResponseObject) {
+				commands.Out(Options, resp)
+			})
+			return
+		}
+		resp := commands.Template(Options, templateInput, templateOutput)
+		commands.Out(Options, resp)
+	},
+}
+var lockCmd = &cobra.Command{
+	Use:   "lock",
+	Short: "manage locks",
+	Long:  `Acquires, renews and releases lease locks (held for the TTL) stored in a discfg`,
+	Run: func(cmd *cobra.Command, args []string) {
+	},
+}
+var acquireLockCmd = &cobra.Command{
+	Use:   "acquire",
+	Short: "acquire a lock",
+	Long:  `Acquires a lock if it is free or has expired`,
+	Run: func(cmd *cobra.Command, args []string) {
+		setOptsFromArgs(args)
+		resp := commands.AcquireLock(Options, lockOwner)
+		commands.Out(Options, resp)
+	},
+}
+var renewLockCmd = &cobra.Command{
+	Use:   "renew",
+	Short: "renew a lock",
+	Long:  `Renews the lease on a held lock for another TTL`,
+	Run: func(cmd *cobra.Command, args []string) {
+		setOptsFromArgs(args)
+		resp := commands.RenewLock(Options, lockOwner)
+		commands.Out(Options, resp)
+	},
+}
+var releaseLockCmd = &cobra.Command{
+	Use:   "release",
+	Short: "release a lock",
+	Long:  `Releases a held lock`,
+	Run: func(cmd *cobra.Command, args []string) {
+		setOptsFromArgs(args)
+		resp := commands.ReleaseLock(Options, lockOwner)
+		commands.Out(Options, resp)
+	},
+}
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "show the audit log",
+	Long:  `Shows the audit log of changes made to a discfg (or to one of its keys), verifying it hasn't been changed`,
+	Run: func(cmd *cobra.Command, args []string) {
+		setOptsFromArgs(args)
+		f := audit.Filter{Key: Options.Key}
+		var err error
+		if f.From, err = parseAuditTime(auditFrom); err == nil {
+			f.To, err = parseAuditTime(auditTo)
+		}
+		if err != nil {
+			commands.Out(Options, config.ResponseObject{Action: "audit", Error: err.Error()})
+			return
+		}
+		resp := commands.Audit(Options, f)
+		commands.Out(Options, resp)
+	},
+}
+var tokenCmd = &cobra.Command{
+	Use:   "token",
+	Short: "manage API tokens",
+	Long:  `Creates, revokes and lists API tokens for the HTTP APIs, granted access to configs and keys by policies`,
+	Run: func(cmd *cobra.Command, args []string) {
+	},
+}
+var createTokenCmd = &cobra.Command{
+	Use:   "create",
+	Short: "create an API token",
+	Long:  `Creates a named API token with policies, ie. --policy write:prod-*:services/ --policy read:*`,
+	Run: func(cmd *cobra.Command, args []string) {
+		name := ""
+		if len(args) > 0 {
+			name = args[0]
+		}
+		resp := commands.CreateToken(Options, name, tokenPolicies)
+		commands.Out(Options, resp)
+	},
+}
+var revokeTokenCmd = &cobra.Command{
+	Use:   "revoke",
+	Short: "revoke an API token",
+	Long:  `Revokes a named API token`,
+	Run: func(cmd *cobra.Command, args []string) {
+		name := ""
+		if len(args) > 0 {
+			name = args[0]
+		}
+		resp := commands.RevokeToken(Options, name)
+		commands.Out(Options, resp)
+	},
+}
+var listTokenCmd = &cobra.Command{
+	Use:   "list",
+	Short: "list API tokens",
+	Long:  `Lists API tokens and their policies`,
+	Run: func(cmd *cobra.Command, args []string) {
+		resp := commands.ListTokens(Options)
+		commands.Out(Options, resp)
+	},
+}
 var exportCmd = &cobra.Command{
 	Use:   "export",
 	Short: "export entire config",
@@ -196,23 +548,156 @@ func main() {
 	DiscfgCmd.PersistentFlags().StringVarP(&Options.Storage.AWS.AccessKeyID, "keyId", "k", "", "AWS Access Key ID")
 	DiscfgCmd.PersistentFlags().StringVarP(&Options.Storage.AWS.SecretAccessKey, "secretKey", "s", "", "AWS Secret Access Key")
 	DiscfgCmd.PersistentFlags().StringVarP(&Options.Storage.AWS.CredProfile, "credProfile", "p", "", "AWS Credentials Profile to use")
+	DiscfgCmd.PersistentFlags().StringVar(&Options.Storage.AWS.RoleARN, "role-arn", "", "AWS IAM role to assume")
+	DiscfgCmd.PersistentFlags().StringVar(&Options.Storage.AWS.ExternalID, "external-id", "", "External ID for assuming the AWS IAM role")
+	DiscfgCmd.PersistentFlags().StringVar(&Options.StorageInterfaceName, "storage", "dynamodb", "Storage engine to use (dynamodb|dynamodb-shared)")
+	DiscfgCmd.PersistentFlags().StringVar(&Options.Storage.AWS.Endpoint, "endpoint", "", "Storage endpoint to use, ie. DynamoDB Local (DISCFG_DYNAMODB_ENDPOINT by default)")
+	DiscfgCmd.PersistentFlags().DurationVar(&timeout, "timeout", 0, "Timeout for storage calls, ie. 10s (0 is no timeout)")
 
 	// Additional options by some operations
 	DiscfgCmd.PersistentFlags().StringVarP(&dataFile, "data", "d", "", "Data file to read for value")
 	DiscfgCmd.PersistentFlags().StringVarP(&Options.ConditionalValue, "condition", "c", "", "Conditional operation value")
+	DiscfgCmd.PersistentFlags().Int64Var(&Options.ConditionalVersion, "ifVersion", 0, "Conditional operation on the key's current version")
 	DiscfgCmd.PersistentFlags().Int64VarP(&Options.TTL, "ttl", "t", 0, "Set a time to live for a key (0 is no TTL)")
+	DiscfgCmd.PersistentFlags().StringVar(&Options.ValueType, "type", "", "Type of value being set (string|number|bool|json|binary)")
+
+	// Sensitive values
+	DiscfgCmd.PersistentFlags().BoolVar(&sensitive, "sensitive", false, "Mark the key as sensitive, its value is redacted in output (--sensitive=false unmarks it)")
+	DiscfgCmd.PersistentFlags().StringSliceVar(&Options.SensitivePrefixes, "sensitivePrefix", []string{}, "Treat keys beginning with this prefix as sensitive")
+	DiscfgCmd.PersistentFlags().BoolVar(&Options.Reveal, "reveal", false, "Reveal sensitive values in output")
+
+	// Key metadata options
+	DiscfgCmd.PersistentFlags().StringVar(&Options.Author, "author", "", "Who is setting the key (the AWS identity by default)")
+	DiscfgCmd.PersistentFlags().StringVar(&Options.Description, "description", "", "Description to set on the key")
+	DiscfgCmd.PersistentFlags().StringToStringVar(&Options.Tags, "tag", map[string]string{}, "Tag to set on the key, or to filter keys by when listing them, ie. env=prod")
+
+	// Client side encryption
+	DiscfgCmd.PersistentFlags().BoolVar(&Options.Encrypt, "encrypt", false, "Encrypt the value before storing it")
+	DiscfgCmd.PersistentFlags().BoolVar(&Options.Decrypt, "decrypt", false, "Decrypt encrypted values (they are output encrypted otherwise)")
+	DiscfgCmd.PersistentFlags().StringVar(&Options.Encryption.Provider, "keyProvider", "local", "Encryption key provider to use (local|kms)")
+	DiscfgCmd.PersistentFlags().StringVar(&Options.Encryption.KeyFile, "keyFile", "", "Key file for the local key provider (~/.discfg.key by default)")
+	DiscfgCmd.PersistentFlags().StringVar(&Options.Encryption.KMSKeyID, "kmsKeyId", "", "KMS key id or alias for the kms key provider")
+
+	// Audit options
+	Options.Audit.Source = audit.SourceCLI
+	DiscfgCmd.PersistentFlags().StringVar(&Options.Audit.Sink, "auditSink", "", "Audit log to record changes to (file|dynamodb), none by default")
+	DiscfgCmd.PersistentFlags().StringVar(&Options.Audit.File, "auditFile", "", "Audit log file for the file sink (~/"+audit.DefaultFile+" by default)")
+	DiscfgCmd.PersistentFlags().StringVar(&Options.Audit.Table, "auditTable", audit.DefaultTable, "Audit log table for the dynamodb sink")
+	auditCmd.Flags().StringVar(&auditFrom, "from", "", "Only show changes since this time, ie. 2016-01-02T15:04:05Z or 24h (ago)")
+	auditCmd.Flags().StringVar(&auditTo, "to", "", "Only show changes until this time, ie. 2016-01-02T15:04:05Z or 1h (ago)")
+
+	// Template options
+	templateCmd.Flags().StringVarP(&templateInput, "input", "i", "", "Template file to render")
+	templateCmd.Flags().StringVarP(&templateOutput, "output", "o", "", "File to write the rendered template to")
+	templateCmd.Flags().BoolVarP(&templateWatch, "watch", "w", false, "Render again whenever the config version changes")
+	templateCmd.Flags().DurationVar(&templateInterval, "interval", 5*time.Second, "How often to check the config version when watching")
+	templateCmd.Flags().StringVarP(&templateReload, "reload", "r", "", "Command to run after rendering when watching")
+
+	// Config wait options
+	for _, cmd := range []*cobra.Command{createCfgCmd, updateCfgCmd, deleteCfgCmd, cloneCfgCmd, renameCfgCmd} {
+		cmd.Flags().BoolVar(&wait, "wait", false, "Wait for the configuration to be ready (or gone when deleting)")
+		cmd.Flags().DurationVar(&waitTimeout, "waitTimeout", commands.DefaultWaitTimeout, "How long to wait")
+	}
+
+	// Increment options
+	incrCmd.Flags().StringVar(&incrBy, "by", "", "Delta to add, which may be negative (--by -5), instead of the positional one")
+
+	// Config migrate options
+	migrateCfgCmd.Flags().StringVar(&migrateTo, "to", "dynamodb-shared", "Storage engine to migrate the configuration to")
+	migrateCfgCmd.Flags().BoolVar(&migrateDelete, "delete", false, "Delete the configuration from the original storage engine once copied")
 
-	DiscfgCmd.AddCommand(cfgCmd, setCmd, getCmd, deleteCmd, infoCmd)
+	// Token options
+	tokenCmd.PersistentFlags().StringVar(&Options.Auth.Cfg, "tokenCfg", auth.DefaultCfg, "Configuration the tokens are stored in")
+	createTokenCmd.Flags().StringSliceVar(&tokenPolicies, "policy", []string{}, "Policy to grant the token, access:cfg[:key] (access is read|write|admin)")
+
+	// Lock options
+	lockCmd.PersistentFlags().StringVar(&lockOwner, "owner", lock.DefaultOwner(), "Lock owner ID (the hostname by default)")
+
+	DiscfgCmd.AddCommand(cfgCmd, setCmd, getCmd, deleteCmd, touchCmd, incrCmd, lsCmd, infoCmd, gcCmd, validateCmd, templateCmd, lockCmd, auditCmd, tokenCmd)
 	cfgCmd.AddCommand(useCmd)
 	cfgCmd.AddCommand(whichCmd)
 	cfgCmd.AddCommand(createCfgCmd)
 	cfgCmd.AddCommand(deleteCfgCmd)
 	cfgCmd.AddCommand(updateCfgCmd)
+	cfgCmd.AddCommand(listCfgCmd)
+	cfgCmd.AddCommand(migrateCfgCmd)
+	cfgCmd.AddCommand(cloneCfgCmd)
+	cfgCmd.AddCommand(renameCfgCmd)
+	cfgCmd.AddCommand(metaCfgCmd)
+	cfgCmd.AddCommand(freezeCfgCmd, unfreezeCfgCmd)
+	metaCfgCmd.AddCommand(setMetaCfgCmd, getMetaCfgCmd)
 	cfgCmd.AddCommand(infoCmd)
+	lockCmd.AddCommand(acquireLockCmd, renewLockCmd, releaseLockCmd)
+	tokenCmd.AddCommand(createTokenCmd, revokeTokenCmd, listTokenCmd)
 	DiscfgCmd.Execute()
 }
 
+// waitForCfg waits for a config change to finish when --wait was given, with progress for humans
+func waitForCfg(resp config.ResponseObject, deleted bool) config.ResponseObject {
+	if !wait || resp.Error != "" {
+		return resp
+	}
+	var progress func(string)
+	if Options.OutputFormat == "human" {
+		progress = func(state string) {
+			DiscfgCmd.Println("Configuration " + Options.CfgName + " is " + state)
+		}
+	}
+	state, err := commands.WaitForCfg(Options, deleted, waitTimeout, progress)
+	resp.CfgState = state
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	return resp
+}
+
+// confirm asks for confirmation before a command makes a change that's hard to take back
+func confirm() bool {
+	inputReader := bufio.NewReader(os.Stdin)
+	cfgCmd.Print("Are you sure? [Y/n] ")
+	input, _ := inputReader.ReadString('\n')
+	if input != "Y\n" {
+		DiscfgCmd.Println("Aborted")
+		return false
+	}
+	return true
+}
+
+// copyWaitTimeout returns how long clone and rename wait for the new config to be ready, 0 unless --wait is set
+func copyWaitTimeout() time.Duration {
+	if !wait {
+		return 0
+	}
+	return waitTimeout
+}
+
+// parseAuditTime parses an audit time filter, either RFC3339 or a duration ago (empty is no filter)
+func parseAuditTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return t, fmt.Errorf("Invalid time %q, must be RFC3339 or a duration", s)
+	}
+	return t, nil
+}
+
 // Takes positional command arguments and sets options from them (because some may be optional)
+// incrDelta returns the delta for incr; the --by flag, else the positional value, else 1
+func incrDelta(by string, value []byte) (float64, error) {
+	if by == "" {
+		by = string(value)
+	}
+	if by == "" {
+		return 1, nil
+	}
+	return strconv.ParseFloat(by, 64)
+}
+
 func setOptsFromArgs(args []string) {
 	// The user may have set a config name in a `.discfg` file, for convenience, to shorten the commands.
 	// This will affect the positional arguments. The confusing part will be if a config name has been
@@ -2,6 +2,7 @@ package main
 
 import (
 	. "github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/cobra"
 	"testing"
 )
 
@@ -33,3 +34,30 @@ func TestSetOptsFromArgs(t *testing.T) {
 		})
 	})
 }
+
+func TestIncrDelta(t *testing.T) {
+	Convey("The delta should come from --by, then the positional value, then default to 1", t, func() {
+		delta, err := incrDelta("", nil)
+		So(err, ShouldBeNil)
+		So(delta, ShouldEqual, 1)
+		delta, _ = incrDelta("", []byte("0.05"))
+		So(delta, ShouldEqual, 0.05)
+		delta, _ = incrDelta("-5", []byte("2"))
+		So(delta, ShouldEqual, -5)
+		_, err = incrDelta("five", nil)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("A negative delta should be accepted by a string flag such as --by", t, func() {
+		// Flags are added in main, so this is one like it
+		cmd := &cobra.Command{}
+		by := ""
+		cmd.Flags().StringVar(&by, "by", "", "")
+		So(cmd.ParseFlags([]string{"--by", "-5"}), ShouldBeNil)
+		So(by, ShouldEqual, "-5")
+		So(cmd.ParseFlags([]string{"--by=-0.5"}), ShouldBeNil)
+		So(by, ShouldEqual, "-0.5")
+		// Whereas a positional one is taken for a flag
+		So(cmd.ParseFlags([]string{"-5"}), ShouldNotBeNil)
+	})
+}
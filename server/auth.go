@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/tmaiaroto/discfg/auth"
+	"github.com/tmaiaroto/discfg/config"
+	"net/http"
+	"strings"
+)
+
+// tokenContextKey holds the authorized token in a request's context
+type tokenContextKey struct{}
+
+// v1Authorize is middleware allowing requests only with an API token (the Authorization header) that's allowed
+// access to the config and key in the route. Without a token configuration (DISCFG_TOKEN_CFG) everything is allowed,
+// which the server only starts that way with --insecure.
+func v1Authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, ok := v1Request(r)
+		token, err := auth.Authorize(options, r.Header.Get("Authorization"), req)
+		if err == nil && !ok && options.Auth.Cfg != "" {
+			err = auth.ErrForbidden
+		}
+		if err != nil {
+			status := auth.ErrorCode(err)
+			if status == 0 {
+				status = http.StatusInternalServerError
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(config.ResponseObject{Error: err.Error(), ErrorCode: auth.ErrorCode(err)})
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), tokenContextKey{}, token)))
+	})
+}
+
+// v1Request returns what a v1 route needs access to, false for requests that aren't a v1 route
+func v1Request(r *http.Request) (auth.Request, bool) {
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/v1/"), "/", 3)
+	if !strings.HasPrefix(r.URL.Path, "/v1/") || len(parts) < 2 || parts[0] == "" {
+		return auth.Request{}, false
+	}
+	req := auth.Request{Cfg: parts[0]}
+	switch {
+	case parts[1] == "keys" && len(parts) == 3:
+		req.Key = parts[2]
+		req.Access = auth.Write
+		if r.Method == http.MethodGet {
+			req.Access = auth.Read
+		}
+	case parts[1] == "cfg" && len(parts) == 2:
+		req.Access = auth.Admin
+		if r.Method == http.MethodOptions {
+			req.Access = auth.Read
+		}
+	default:
+		return req, false
+	}
+	return req, true
+}
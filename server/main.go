@@ -1,40 +1,81 @@
 package main
 
 import (
-	// "flag"
-	// "github.com/labstack/echo"
-	// mw "github.com/labstack/echo/middleware"
+	"encoding/json"
+	"flag"
+	"github.com/tmaiaroto/discfg/audit"
+	"github.com/tmaiaroto/discfg/cache"
 	"github.com/tmaiaroto/discfg/config"
 	"github.com/tmaiaroto/discfg/version"
 	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 var options = config.Options{StorageInterfaceName: "dynamodb", Version: version.Semantic}
 
+// cached is the cache reads go through, nil when not caching
+var cached *cache.Shipper
+
 func main() {
 	// TODO: remove
 	log.SetFlags(log.Lshortfile | log.Ldate | log.Ltime)
 
-	// port := *flag.String("port", "8899", "API port")
-	// apiVersion := *flag.String("version", "v1", "API version")
-	// region := *flag.String("region", "us-east-1", "AWS region")
+	port := flag.String("port", "8899", "API port")
+	apiVersion := flag.String("version", "v1", "API version")
+	region := flag.String("region", "us-east-1", "AWS region")
+	insecure := flag.Bool("insecure", false, "Serve without API tokens, letting anyone who can reach the server do anything")
+	flag.Parse()
+
+	options.Storage.AWS.Region = *region
+	options.Audit.Source = audit.SourceHTTP
+	// Requests need an API token when set, the configuration the tokens are stored in
+	options.Auth.Cfg = os.Getenv("DISCFG_TOKEN_CFG")
+	if options.Auth.Cfg == "" {
+		if !*insecure {
+			log.Fatal("DISCFG_TOKEN_CFG is not set, set it to require API tokens or pass --insecure to serve without them")
+		}
+		log.Println("WARNING: serving without API tokens (--insecure), anyone who can reach the server can read, change and delete every configuration")
+	}
+	// Keys beginning with any of these (comma separated) prefixes are redacted unless revealed
+	if prefixes := os.Getenv("DISCFG_SENSITIVE_PREFIXES"); prefixes != "" {
+		options.SensitivePrefixes = strings.Split(prefixes, ",")
+	}
 
-	// options.Storage.AWS.Region = region
+	// Routes
+	mux := http.NewServeMux()
 
-	// e := echo.New()
+	// Items are cached in memory for up to this many seconds, checked for changes in the background that often
+	if maxAge, err := strconv.ParseInt(os.Getenv("DISCFG_CACHE_MAX_AGE"), 10, 64); err == nil && maxAge > 0 {
+		s, err := cache.Register(options.StorageInterfaceName, time.Duration(maxAge)*time.Second)
+		if err != nil {
+			log.Fatal(err)
+		}
+		s.Refresh(time.Duration(maxAge) * time.Second)
+		cached = s
+		mux.HandleFunc("/stats", statsHandler)
+	}
 
-	// // Middleware
-	// //e.Use(mw.Logger())
-	// e.Use(mw.Gzip())
-	// e.Use(mw.Recover())
+	switch *apiVersion {
+	default:
+		log.Fatal("Unknown API version " + *apiVersion)
+	case "v1":
+		v1Routes(mux)
+	}
 
-	// // Routes
-	// switch apiVersion {
-	// default:
-	// case "v1":
-	// 	v1Routes(e)
-	// }
+	// Start server
+	log.Fatal(http.ListenAndServe(":"+*port, mux))
+}
 
-	// // Start server
-	// e.Run(":" + port)
+// statsHandler responds with the cache stats (hits, misses and so on), which hold no config data
+func statsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cached.Stats())
 }
@@ -1,174 +1,120 @@
 // API Version 1
 package main
 
-// import (
-// 	"encoding/json"
-// 	//"encoding/base64"
-// 	"github.com/labstack/echo"
-// 	"github.com/tmaiaroto/discfg/commands"
-// 	"github.com/tmaiaroto/discfg/config"
-// 	//"github.com/ugorji/go/codec" // <-- may eventually be used as a different output format. have to see what echo supports.
-// 	"io/ioutil"
-// 	//"log"
-// 	"net/http"
-// )
-
-// // Set the routes for V1 API
-// func v1Routes(e *echo.Echo) {
-// 	e.Put("/v1/:name/keys/:key", v1SetKey)
-// 	e.Get("/v1/:name/keys/:key", v1GetKey)
-// 	e.Delete("/v1/:name/keys/:key", v1DeleteKey)
-
-// 	e.Put("/v1/:name/cfg", v1CreateCfg)
-// 	e.Delete("/v1/:name/cfg", v1DeleteCfg)
-// 	e.Patch("/v1/:name/cfg", v1PatchCfg)
-// 	e.Options("/v1/:name/cfg", v1OptionsCfg)
-// }
-
-// // Gets a key from discfg
-// func v1GetKey(c *echo.Context) error {
-// 	options.CfgName = c.Param("name")
-// 	options.Key = c.Param("key")
-// 	resp := commands.GetKey(options)
-// 	// Since this option is not needed for anything else, it's not held on the Options struct.
-// 	contentType := c.Query("type")
-
-// 	// This is very awesome. Very interesting possibilties now.
-// 	switch contentType {
-// 	case "text", "text/plain", "string":
-// 		return c.String(http.StatusOK, string(resp.Item.Value.([]byte)))
-// 		break
-// 	// This one is going to be interesting. Weird? Bad practice? I don't know, but I dig it and it starts giving me wild ideas.
-// 	case "html", "text/html":
-// 		return c.HTML(http.StatusOK, string(resp.Item.Value.([]byte)))
-// 		break
-// 	// TODO:
-// 	//case "jsonp":
-// 	//break
-// 	case "json", "application/json":
-// 		resp = commands.FormatJSONValue(resp)
-// 		break
-// 	default:
-// 		resp = commands.FormatJSONValue(resp)
-// 		break
-// 	}
-// 	// default response
-// 	return c.JSON(http.StatusOK, resp)
-// }
-
-// // Sets a key in discfg
-// func v1SetKey(c *echo.Context) error {
-// 	options.CfgName = c.Param("name")
-// 	options.Key = c.Param("key")
-// 	resp := config.ResponseObject{
-// 		Action: "set",
-// 	}
-
-// 	// Allow the value to be passed via querystring param.
-// 	options.Value = []byte(c.Query("value"))
-
-// 	// Overwrite that if the request body passes a value that can be read, preferring that.
-// 	b, err := ioutil.ReadAll(c.Request().Body)
-// 	if err != nil {
-// 		// If reading the body failed and we don't have a value from the querystring parameter
-// 		// then we have a (potential) problem.
-// 		//
-// 		// Some data stores may be ok with an empty key value. DynamoDB is not. It will only
-// 		// return a ValidationException error. Plus, even if it was allowed, it would really
-// 		// confuse the user. Some random error reading the body of a request and poof, the data
-// 		// vanishes? That'd be terrible UX.
-// 		// log.Println(err)
-// 		resp.Error = err.Error()
-// 		resp.Message = "Something went wrong reading the body of the request."
-// 		// resp.ErrorCode = 500 <-- TODO: I need to come up with discfg specific error codes. Keep as const somewhere.
-// 		//	return c.JSON(http.StatusOK, resp)
-// 		//	Or maybe return an HTTP status message... This is outside discfg's concern. It's not an error message/code
-// 		//	that would ever be seen from the CLI, right? Or maybe it would. Maybe a more generic, "error parsing key value" ...
-// 	} else if len(b) > 0 {
-// 		options.Value = b
-// 	}
-
-// 	resp = commands.SetKey(options)
-
-// 	return c.JSON(http.StatusOK, resp)
-// }
-
-// // Deletes a key in discfg
-// func v1DeleteKey(c *echo.Context) error {
-// 	options.CfgName = c.Param("name")
-// 	options.Key = c.Param("key")
-// 	return c.JSON(http.StatusOK, commands.DeleteKey(options))
-// }
-
-// // Creates a new configuration
-// func v1CreateCfg(c *echo.Context) error {
-// 	options.CfgName = c.Param("name")
-// 	resp := config.ResponseObject{
-// 		Action: "create cfg",
-// 	}
-
-// 	// Any settings to pass along to the storage interface (for example, ReadCapacityUnits and WriteCapacityUnits for DynamoDB).
-// 	var settings map[string]interface{}
-// 	b, err := ioutil.ReadAll(c.Request().Body)
-// 	if err != nil {
-// 		resp.Error = err.Error()
-// 		resp.Message = "Something went wrong reading the body of the request."
-// 		// resp.ErrorCode = 500 <-- TODO
-// 	} else if len(b) > 0 {
-// 		resp := config.ResponseObject{
-// 			Action: "create cfg",
-// 		}
-// 		//options.Value = b
-// 		if err := json.Unmarshal(b, &settings); err != nil {
-// 			resp.Error = err.Error()
-// 			resp.Message = "Something went wrong reading the body of the request."
-// 			return c.JSON(http.StatusOK, resp)
-// 		}
-// 	}
-
-// 	return c.JSON(http.StatusOK, commands.CreateCfg(options, settings))
-// }
-
-// // Deletes a configuration
-// func v1DeleteCfg(c *echo.Context) error {
-// 	options.CfgName = c.Param("name")
-// 	return c.JSON(http.StatusOK, commands.DeleteCfg(options))
-// }
-
-// // Sets options for a configuration
-// func v1PatchCfg(c *echo.Context) error {
-// 	options.CfgName = c.Param("name")
-// 	resp := config.ResponseObject{
-// 		Action: "info",
-// 	}
-
-// 	var settings map[string]interface{}
-// 	b, err := ioutil.ReadAll(c.Request().Body)
-// 	if err != nil {
-// 		resp.Error = err.Error()
-// 		resp.Message = "Something went wrong reading the body of the request."
-// 		// resp.ErrorCode = 500 <-- TODO
-// 		return c.JSON(http.StatusOK, resp)
-// 	} else if len(b) > 0 {
-// 		resp := config.ResponseObject{
-// 			Action: "update cfg",
-// 		}
-// 		//options.Value = b
-// 		if err := json.Unmarshal(b, &settings); err != nil {
-// 			resp.Error = err.Error()
-// 			resp.Message = "Something went wrong reading the body of the request."
-// 			return c.JSON(http.StatusOK, resp)
-// 		}
-// 	}
-
-// 	return c.JSON(http.StatusOK, commands.UpdateCfg(options, settings))
-// }
-
-// func v1OptionsCfg(c *echo.Context) error {
-// 	options.CfgName = c.Param("name")
-// 	// resp := config.ResponseObject{
-// 	// 	Action: "info",
-// 	// }
-
-// 	return c.JSON(http.StatusOK, commands.Info(options))
-// }
+import (
+	"encoding/json"
+	"github.com/tmaiaroto/discfg/auth"
+	"github.com/tmaiaroto/discfg/cache"
+	"github.com/tmaiaroto/discfg/commands"
+	"github.com/tmaiaroto/discfg/config"
+	"io/ioutil"
+	"net/http"
+)
+
+// Set the routes for V1 API, every one of them authorized (see v1Authorize)
+//
+//	PUT, GET and DELETE /v1/:name/keys/:key
+//	PUT, PATCH, DELETE and OPTIONS /v1/:name/cfg
+func v1Routes(mux *http.ServeMux) {
+	mux.Handle("/v1/", v1Authorize(http.HandlerFunc(v1Handler)))
+}
+
+// v1Handler serves a v1 route, with its own copy of the options so concurrent requests don't share them
+func v1Handler(w http.ResponseWriter, r *http.Request) {
+	req, ok := v1Request(r)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	opts := options
+	// Only here and not in v1Authorize, so tokens are always checked against storage and a revoked one isn't cached
+	if cached != nil {
+		opts.StorageInterfaceName = cache.RegisteredName(opts.StorageInterfaceName)
+	}
+	opts.CfgName = req.Cfg
+	opts.Key = req.Key
+	opts.Context = r.Context()
+	opts.Reveal = r.URL.Query().Get("reveal") == "true"
+	// Changes made with a token are recorded as made by it
+	if token, ok := r.Context().Value(tokenContextKey{}).(config.Token); ok {
+		opts.Author = auth.Identity(token)
+	}
+
+	var resp config.ResponseObject
+	switch {
+	case req.Key != "" && r.Method == http.MethodGet:
+		v1GetKey(w, r, opts)
+		return
+	case req.Key != "" && r.Method == http.MethodPut:
+		resp = v1SetKey(r, opts)
+	case req.Key != "" && r.Method == http.MethodDelete:
+		resp = commands.DeleteKey(opts)
+	case req.Key == "" && r.Method == http.MethodPut:
+		resp = v1CfgSettings(r, opts, "create cfg", commands.CreateCfg)
+	case req.Key == "" && r.Method == http.MethodPatch:
+		resp = v1CfgSettings(r, opts, "update cfg", commands.UpdateCfg)
+	case req.Key == "" && r.Method == http.MethodDelete:
+		resp = commands.DeleteCfg(opts)
+	case req.Key == "" && r.Method == http.MethodOptions:
+		resp = commands.Info(opts)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	v1JSON(w, http.StatusOK, commands.FormatJSONValue(opts, resp))
+}
+
+// Gets a key from discfg, as JSON unless another type is asked for
+func v1GetKey(w http.ResponseWriter, r *http.Request, opts config.Options) {
+	resp := commands.GetKey(opts)
+	value, _ := commands.Redact(opts, resp).Item.Value.([]byte)
+
+	// Since this option is not needed for anything else, it's not held on the Options struct.
+	switch r.URL.Query().Get("type") {
+	case "text", "text/plain", "string":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write(value)
+	case "html", "text/html":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(value)
+	default:
+		v1JSON(w, http.StatusOK, commands.FormatJSONValue(opts, resp))
+	}
+}
+
+// Sets a key in discfg, to the request body or the value querystring param
+func v1SetKey(r *http.Request, opts config.Options) config.ResponseObject {
+	// Allow the value to be passed via querystring param.
+	opts.Value = []byte(r.URL.Query().Get("value"))
+
+	// Overwrite that if the request body passes a value that can be read, preferring that. Should reading the
+	// body fail, the key isn't set at all rather than set to a value that wasn't meant.
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return config.ResponseObject{Action: "set", Error: err.Error(), Message: "Something went wrong reading the body of the request."}
+	} else if len(b) > 0 {
+		opts.Value = b
+	}
+	return commands.SetKey(opts)
+}
+
+// v1CfgSettings creates or updates a configuration with the settings in the request body (a JSON object), any
+// settings to pass along to the storage interface (for example, ReadCapacityUnits and WriteCapacityUnits for DynamoDB)
+func v1CfgSettings(r *http.Request, opts config.Options, action string, f func(config.Options, map[string]interface{}) config.ResponseObject) config.ResponseObject {
+	var settings map[string]interface{}
+	b, err := ioutil.ReadAll(r.Body)
+	if err == nil && len(b) > 0 {
+		err = json.Unmarshal(b, &settings)
+	}
+	if err != nil {
+		return config.ResponseObject{Action: action, Error: err.Error(), Message: "Something went wrong reading the body of the request."}
+	}
+	return f(opts, settings)
+}
+
+// v1JSON writes a JSON response
+func v1JSON(w http.ResponseWriter, status int, resp config.ResponseObject) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
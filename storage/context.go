@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"context"
+	"github.com/tmaiaroto/discfg/config"
+)
+
+// ContextShipper is a Shipper with context aware variants of its methods, so deadlines (from a Lambda function or
+// the CLI's --timeout for example) and cancelation can stop slow storage calls.
+type ContextShipper interface {
+	Shipper
+	CreateConfigWithContext(context.Context, config.Options, map[string]interface{}) (interface{}, error)
+	DeleteConfigWithContext(context.Context, config.Options) (interface{}, error)
+	UpdateConfigWithContext(context.Context, config.Options, map[string]interface{}) (interface{}, error)
+	ConfigStateWithContext(context.Context, config.Options) (string, error)
+	ListConfigsWithContext(context.Context, config.Options) ([]config.CfgSummary, error)
+	UpdateWithContext(context.Context, config.Options) (config.Item, error)
+	GetWithContext(context.Context, config.Options) (config.Item, error)
+	ListWithContext(context.Context, config.Options) ([]config.Item, error)
+	DeleteWithContext(context.Context, config.Options) (config.Item, error)
+	TouchWithContext(context.Context, config.Options) (config.Item, error)
+	IncrementWithContext(context.Context, config.Options, float64) (config.Item, error)
+	UpdateConfigVersionWithContext(context.Context, config.Options) error
+}
+
+// WithContext returns the ContextShipper for a Shipper. Shippers that don't implement ContextShipper (ones written
+// before it existed) are adapted, with the context only checked before each call since the call itself can't be stopped.
+func WithContext(s Shipper) ContextShipper {
+	if cs, ok := s.(ContextShipper); ok {
+		return cs
+	}
+	return contextAdapter{s}
+}
+
+// contextAdapter adapts a Shipper to a ContextShipper
+type contextAdapter struct {
+	Shipper
+}
+
+func (a contextAdapter) CreateConfigWithContext(ctx context.Context, opts config.Options, settings map[string]interface{}) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return a.CreateConfig(opts, settings)
+}
+
+func (a contextAdapter) DeleteConfigWithContext(ctx context.Context, opts config.Options) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return a.DeleteConfig(opts)
+}
+
+func (a contextAdapter) UpdateConfigWithContext(ctx context.Context, opts config.Options, settings map[string]interface{}) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return a.UpdateConfig(opts, settings)
+}
+
+func (a contextAdapter) ConfigStateWithContext(ctx context.Context, opts config.Options) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return a.ConfigState(opts)
+}
+
+func (a contextAdapter) ListConfigsWithContext(ctx context.Context, opts config.Options) ([]config.CfgSummary, error) {
+	if err := ctx.Err(); err != nil {
+		return []config.CfgSummary{}, err
+	}
+	return a.ListConfigs(opts)
+}
+
+func (a contextAdapter) UpdateWithContext(ctx context.Context, opts config.Options) (config.Item, error) {
+	if err := ctx.Err(); err != nil {
+		return config.Item{}, err
+	}
+	return a.Update(opts)
+}
+
+func (a contextAdapter) GetWithContext(ctx context.Context, opts config.Options) (config.Item, error) {
+	if err := ctx.Err(); err != nil {
+		return config.Item{}, err
+	}
+	return a.Get(opts)
+}
+
+func (a contextAdapter) ListWithContext(ctx context.Context, opts config.Options) ([]config.Item, error) {
+	if err := ctx.Err(); err != nil {
+		return []config.Item{}, err
+	}
+	return a.List(opts)
+}
+
+func (a contextAdapter) DeleteWithContext(ctx context.Context, opts config.Options) (config.Item, error) {
+	if err := ctx.Err(); err != nil {
+		return config.Item{}, err
+	}
+	return a.Delete(opts)
+}
+
+func (a contextAdapter) TouchWithContext(ctx context.Context, opts config.Options) (config.Item, error) {
+	if err := ctx.Err(); err != nil {
+		return config.Item{}, err
+	}
+	return a.Touch(opts)
+}
+
+func (a contextAdapter) IncrementWithContext(ctx context.Context, opts config.Options, delta float64) (config.Item, error) {
+	if err := ctx.Err(); err != nil {
+		return config.Item{}, err
+	}
+	return a.Increment(opts, delta)
+}
+
+func (a contextAdapter) UpdateConfigVersionWithContext(ctx context.Context, opts config.Options) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.UpdateConfigVersion(opts)
+}
@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"context"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/tmaiaroto/discfg/config"
+	"github.com/tmaiaroto/discfg/storage/mockdb"
+	"testing"
+)
+
+func TestWithContext(t *testing.T) {
+	Convey("A Shipper without context aware methods should be adapted", t, func() {
+		shipper := WithContext(mockdb.MockShipper{})
+		So(shipper, ShouldHaveSameTypeAs, contextAdapter{})
+
+		opts := config.Options{CfgName: "mockcfg", Key: "initial"}
+		item, err := shipper.GetWithContext(context.Background(), opts)
+		So(err, ShouldBeNil)
+		So(string(item.Value.([]byte)), ShouldEqual, "initial value for test")
+	})
+
+	Convey("A canceled context should stop the call", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := WithContext(mockdb.MockShipper{}).GetWithContext(ctx, config.Options{CfgName: "mockcfg", Key: "initial"})
+		So(err, ShouldEqual, context.Canceled)
+	})
+
+	Convey("Options with a canceled context should stop the call", t, func() {
+		RegisterShipper("mock", mockdb.MockShipper{})
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := Get(config.Options{StorageInterfaceName: "mock", CfgName: "mockcfg", Key: "initial", Context: ctx})
+		So(err, ShouldEqual, context.Canceled)
+	})
+}
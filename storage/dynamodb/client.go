@@ -0,0 +1,79 @@
+package database
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/tmaiaroto/discfg/awssession"
+	"github.com/tmaiaroto/discfg/config"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// EndpointEnvVar is the environment variable that sets the DynamoDB endpoint when it isn't otherwise set
+const EndpointEnvVar = "DISCFG_DYNAMODB_ENDPOINT"
+
+// clients are the service clients built so far. A client (and its session and credentials) is safe to share
+// between goroutines, so building one per call only adds overhead.
+var clients = struct {
+	sync.Mutex
+	m map[clientKey]*dynamodb.DynamoDB
+}{m: map[clientKey]*dynamodb.DynamoDB{}}
+
+// clientKey is everything that makes one service client differ from another
+type clientKey struct {
+	aws        config.AWS
+	endpoint   string
+	maxRetries int
+	timeout    time.Duration
+}
+
+// svc returns the service client for the options, building it the first time
+func (db DynamoDB) svc(opts config.Options) (*dynamodb.DynamoDB, error) {
+	// The endpoint may come from the environment, which can change
+	key := clientKey{aws: opts.Storage.AWS, endpoint: db.endpoint(opts), maxRetries: db.MaxRetries, timeout: db.Timeout}
+	clients.Lock()
+	defer clients.Unlock()
+	if svc, ok := clients.m[key]; ok {
+		return svc, nil
+	}
+	svc, err := db.newSvc(opts)
+	if err != nil {
+		return nil, err
+	}
+	clients.m[key] = svc
+	return svc, nil
+}
+
+// endpoint returns the endpoint to use; the one in the options, else the one set on the DynamoDB struct,
+// else the DISCFG_DYNAMODB_ENDPOINT environment variable. An empty string uses the AWS endpoint for the region.
+func (db DynamoDB) endpoint(opts config.Options) string {
+	if opts.Storage.AWS.Endpoint != "" {
+		return opts.Storage.AWS.Endpoint
+	}
+	if db.Endpoint != "" {
+		return db.Endpoint
+	}
+	return os.Getenv(EndpointEnvVar)
+}
+
+// newSvc builds a service client, see the awssession package for where credentials come from
+func (db DynamoDB) newSvc(opts config.Options) (*dynamodb.DynamoDB, error) {
+	sess, err := awssession.Get(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	awsConfig := &aws.Config{}
+	if endpoint := db.endpoint(opts); endpoint != "" {
+		awsConfig.Endpoint = aws.String(endpoint)
+	}
+	if db.MaxRetries > 0 {
+		awsConfig.MaxRetries = aws.Int(db.MaxRetries)
+	}
+	if db.Timeout > 0 {
+		awsConfig.HTTPClient = &http.Client{Timeout: db.Timeout}
+	}
+	return dynamodb.New(sess, awsConfig), nil
+}
@@ -0,0 +1,85 @@
+package database
+
+import (
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/tmaiaroto/discfg/awssession"
+	"github.com/tmaiaroto/discfg/config"
+	"os"
+	"testing"
+	"time"
+)
+
+// svc returns the service client, ignoring the error (checked separately)
+func svc(db DynamoDB, opts config.Options) *dynamodb.DynamoDB {
+	s, _ := db.svc(opts)
+	return s
+}
+
+func TestSvc(t *testing.T) {
+	opts := config.Options{}
+	opts.Storage.AWS.Region = "us-east-1"
+
+	Convey("A service client should be built", t, func() {
+		s, err := DynamoDB{}.svc(opts)
+		So(err, ShouldBeNil)
+		So(s, ShouldNotBeNil)
+	})
+
+	Convey("A service client should be reused for the same region and credentials", t, func() {
+		So(svc(DynamoDB{}, opts), ShouldEqual, svc(DynamoDB{}, opts))
+		s, _ := Svc(opts)
+		So(s, ShouldEqual, svc(DynamoDB{}, opts))
+	})
+
+	Convey("A service client should not be shared between regions, credentials or settings", t, func() {
+		west := opts
+		west.Storage.AWS.Region = "us-west-2"
+		So(svc(DynamoDB{}, west), ShouldNotEqual, svc(DynamoDB{}, opts))
+
+		role := opts
+		role.Storage.AWS.RoleARN = "arn:aws:iam::123456789012:role/discfg"
+		So(svc(DynamoDB{}, role), ShouldNotEqual, svc(DynamoDB{}, opts))
+
+		local := DynamoDB{Endpoint: "http://localhost:8000"}
+		So(svc(local, opts), ShouldNotEqual, svc(DynamoDB{}, opts))
+		So(svc(local, opts).Endpoint, ShouldEqual, "http://localhost:8000")
+
+		endpoint := opts
+		endpoint.Storage.AWS.Endpoint = "http://localhost:8001"
+		So(svc(local, endpoint).Endpoint, ShouldEqual, "http://localhost:8001")
+	})
+
+	Convey("A service client should not be reused once the endpoint environment variable changes", t, func() {
+		defer os.Setenv(EndpointEnvVar, os.Getenv(EndpointEnvVar))
+		os.Setenv(EndpointEnvVar, "http://localhost:8002")
+		So(svc(DynamoDB{}, opts).Endpoint, ShouldEqual, "http://localhost:8002")
+		os.Setenv(EndpointEnvVar, "http://localhost:8003")
+		So(svc(DynamoDB{}, opts).Endpoint, ShouldEqual, "http://localhost:8003")
+	})
+
+	Convey("Retries and timeouts should be configurable", t, func() {
+		s := svc(DynamoDB{MaxRetries: 7, Timeout: 2 * time.Second}, opts)
+		So(s.MaxRetries(), ShouldEqual, 7)
+		So(s.Config.HTTPClient.Timeout, ShouldEqual, 2*time.Second)
+	})
+}
+
+// BenchmarkSvc gets the service client as each Shipper method does
+func BenchmarkSvc(b *testing.B) {
+	opts := config.Options{}
+	opts.Storage.AWS.Region = "us-east-1"
+	for i := 0; i < b.N; i++ {
+		DynamoDB{}.svc(opts)
+	}
+}
+
+// BenchmarkNewSvc builds a new session and service client each time, as every Shipper method used to
+func BenchmarkNewSvc(b *testing.B) {
+	opts := config.Options{}
+	opts.Storage.AWS.Region = "us-east-1"
+	for i := 0; i < b.N; i++ {
+		sess, _ := awssession.New(opts)
+		dynamodb.New(sess)
+	}
+}
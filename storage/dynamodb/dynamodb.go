@@ -2,20 +2,34 @@ package database
 
 import (
 	"bytes"
+	"context"
 	"encoding/gob"
+	"errors"
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
-	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/fatih/structs"
+	"github.com/tmaiaroto/discfg/awssession"
 	"github.com/tmaiaroto/discfg/config"
-	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
-// DynamoDB implements the Shipper interface.
+// DynamoDB implements the Shipper interface. The zero value uses the AWS SDK defaults. Service clients are built
+// once per region and set of credentials (and these settings) and then reused.
 type DynamoDB struct {
+	// Endpoint overrides the DynamoDB endpoint, for DynamoDB Local for example
+	Endpoint string
+	// MaxRetries for throttled or failed requests, 0 uses the SDK default
+	MaxRetries int
+	// Timeout for each HTTP request (including retries separately), 0 for no timeout
+	Timeout time.Duration
+	// Table is the table configs share, keyed by config name and item key. Empty for a table per config.
+	Table string
+	// NoTransactions writes keys without transactions, for stores that don't support them (ScyllaDB Alternator for
+	// example). Also set by the DISCFG_DYNAMODB_NO_TRANSACTIONS environment variable.
+	NoTransactions bool
 }
 
 // Name simply returns the display name for this shipper. It might return version info too from a database,
@@ -28,184 +42,426 @@ func (db DynamoDB) Name(opts config.Options) string {
 // read and write capacity units, but anything like that would be found here. Up to the discretion of
 // the interface.
 func (db DynamoDB) Options(opts config.Options) map[string]interface{} {
-	svc := Svc(opts)
+	svc, err := db.svc(opts)
+	if err != nil {
+		return map[string]interface{}{}
+	}
 
+	ctx := opts.ContextOrBackground()
 	params := &dynamodb.DescribeTableInput{
-		TableName: aws.String(opts.CfgName), // Required
+		TableName: db.table(opts), // Required
 	}
-	resp, err := svc.DescribeTable(params)
+	resp, err := svc.DescribeTableWithContext(ctx, params)
 	if err == nil {
 		m := structs.Map(resp)
+		// The settings as they'd be given to create or update the config
+		m["Settings"] = describeSettings(ctx, svc, resp.Table)
 		return m
 	}
 	return map[string]interface{}{}
 }
 
-// Svc configures the DynamoDB service to use
-func Svc(opts config.Options) *dynamodb.DynamoDB {
-	awsConfig := &aws.Config{Region: aws.String(opts.Storage.AWS.Region)}
-
-	// If a session was passed... (AWS Lambda does this)
-	if opts.Storage.AWS.SessionToken != "" {
-		os.Setenv("AWS_SESSION_TOKEN", opts.Storage.AWS.SessionToken)
+// Identity returns the ARN of the AWS identity the credentials resolve to. There's no identity to look up
+// when using a custom endpoint (DynamoDB Local for example), so it's empty.
+func (db DynamoDB) Identity(opts config.Options) (string, error) {
+	if db.endpoint(opts) != "" {
+		return "", nil
 	}
+	return awssession.Identity(opts.ContextOrBackground(), opts)
+}
 
-	// Look in a variety of places for AWS credentials. First, try the credentials file set by AWS CLI tool.
-	// Note the empty string instructs to look under default file path (different based on OS).
-	// This file can have multiple profiles and a default profile will be used unless otherwise configured.
-	// See: https://godoc.org/github.com/aws/aws-sdk-go/aws/credentials#SharedCredentialsProvider
-	creds := credentials.NewSharedCredentials("", opts.Storage.AWS.CredProfile)
-	_, err := creds.Get()
-	// If that failed, try environment variables.
+// Svc returns the DynamoDB service client for the options, using the default DynamoDB settings
+func Svc(opts config.Options) (*dynamodb.DynamoDB, error) {
+	return DynamoDB{}.svc(opts)
+}
+
+// CreateConfig creates a new table for a configuration. With a shared table, the table is created along with
+// the first config in it (settings only apply then, they're changed for every config in it with UpdateConfig).
+func (db DynamoDB) CreateConfig(opts config.Options, settings map[string]interface{}) (interface{}, error) {
+	return db.CreateConfigWithContext(context.Background(), opts, settings)
+}
+
+// CreateConfigWithContext is CreateConfig with a context, which can cancel the request
+func (db DynamoDB) CreateConfigWithContext(ctx context.Context, opts config.Options, settings map[string]interface{}) (interface{}, error) {
+	s, err := parseSettings(settings)
 	if err != nil {
-		// The following are checked:
-		// Access Key ID: AWS_ACCESS_KEY_ID or AWS_ACCESS_KEY
-		// Secret Access Key: AWS_SECRET_ACCESS_KEY or AWS_SECRET_KEY
-		creds = credentials.NewEnvCredentials()
+		return nil, err
 	}
-
-	// If credentials were passed via config, then use those. They will take priority over other methods.
-	if opts.Storage.AWS.AccessKeyID != "" && opts.Storage.AWS.SecretAccessKey != "" {
-		creds = credentials.NewStaticCredentials(opts.Storage.AWS.AccessKeyID, opts.Storage.AWS.SecretAccessKey, "")
+	svc, err := db.svc(opts)
+	if err != nil {
+		return nil, err
 	}
-	awsConfig.Credentials = creds
 
-	return dynamodb.New(session.New(awsConfig))
+	var response interface{}
+	create := true
+	if db.shared() {
+		state, err := tableState(ctx, svc, db.Table)
+		if err != nil {
+			return nil, err
+		}
+		create = state == config.CfgStateNotFound
+	}
+	if create {
+		if response, err = db.createTable(ctx, svc, opts, s); err != nil {
+			return response, err
+		}
+	}
+	return response, db.createRoot(ctx, svc, opts)
 }
 
-// CreateConfig creates a new table for a configuration
-func (db DynamoDB) CreateConfig(opts config.Options, settings map[string]interface{}) (interface{}, error) {
-	svc := Svc(opts)
-	wu := int64(1)
-	ru := int64(2)
-	if val, ok := settings["WriteCapacityUnits"]; ok {
-		wu = int64(val.(float64))
+// createTable creates a table with the settings, waiting for it to be active
+func (db DynamoDB) createTable(ctx context.Context, svc *dynamodb.DynamoDB, opts config.Options, s tableSettings) (interface{}, error) {
+	attributes, keySchema := db.keySchema()
+	params := &dynamodb.CreateTableInput{
+		AttributeDefinitions: attributes,
+		KeySchema:            keySchema,
+		TableName:            db.table(opts), // Required
+		BillingMode:          s.BillingMode,
+		SSESpecification:     s.sse(),
+		TableClass:           s.TableClass,
 	}
-	if val, ok := settings["ReadCapacityUnits"]; ok {
-		ru = int64(val.(float64))
+	// Hard to estimate really, so on-demand billing may be the better choice for many configs.
+	// http://docs.aws.amazon.com/amazondynamodb/latest/developerguide/ProvisionedThroughputIntro.html
+	if !s.payPerRequest() {
+		params.ProvisionedThroughput = s.throughput()
+		if params.ProvisionedThroughput == nil {
+			params.ProvisionedThroughput = &dynamodb.ProvisionedThroughput{
+				ReadCapacityUnits:  aws.Int64(defaultReadCapacityUnits),
+				WriteCapacityUnits: aws.Int64(defaultWriteCapacityUnits),
+			}
+		}
 	}
-
-	// Must be at least 1
-	if wu < 1 {
-		wu = int64(1)
+	if len(s.Tags) > 0 {
+		params.Tags = s.tags()
 	}
-	// Also must be at least 1, default to 2
-	if ru < 1 {
-		ru = int64(2)
+	response, err := svc.CreateTableWithContext(ctx, params)
+	if err == nil {
+		// TTL can only be enabled once the table exists, so this does mean waiting on the table to be created.
+		err = enableTTL(ctx, svc, db.tableName(opts))
 	}
+	if err == nil && s.PointInTimeRecovery != nil && *s.PointInTimeRecovery {
+		err = enablePointInTimeRecovery(ctx, svc, db.tableName(opts), true)
+	}
+	return response, err
+}
 
-	params := &dynamodb.CreateTableInput{
-		AttributeDefinitions: []*dynamodb.AttributeDefinition{
-			{
-				AttributeName: aws.String("key"),
-				AttributeType: aws.String("S"),
-			},
-			// {
-			// 	AttributeName: aws.String("id"),
-			// 	AttributeType: aws.String("N"),
-			// },
-			// // More values...
-		},
-		KeySchema: []*dynamodb.KeySchemaElement{
-			// One is required, but we use both a HASH (key name) and a RANGE (Snowflake).
-			{
-				AttributeName: aws.String("key"),
-				KeyType:       aws.String("HASH"),
-			},
-			// {
-			// 	AttributeName: aws.String("id"),
-			// 	KeyType:       aws.String("RANGE"),
-			// },
+// createRoot creates the root key "/" holding the config version (at 0 until a key is set). It's how configs are
+// told apart from other tables when listing them. In a shared table, it's an error should the config exist.
+func (db DynamoDB) createRoot(ctx context.Context, svc *dynamodb.DynamoDB, opts config.Options) error {
+	params := &dynamodb.UpdateItemInput{
+		Key:       db.itemKey(opts, "/"),
+		TableName: db.table(opts),
+		ExpressionAttributeNames: map[string]*string{
+			"#m": aws.String("cfgModified"),
 		},
-		// Hard to estimate really. Should be passed along via command line when creating a new config.
-		// Along with the table name. This will let people choose. Though it's kinda annoying someone must
-		// think about this...
-		// http://docs.aws.amazon.com/amazondynamodb/latest/developerguide/ProvisionedThroughputIntro.html
-		ProvisionedThroughput: &dynamodb.ProvisionedThroughput{ // Required
-			ReadCapacityUnits:  aws.Int64(ru), // Required
-			WriteCapacityUnits: aws.Int64(wu), // Required
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":modified": {N: aws.String(strconv.FormatInt(time.Now().UnixNano(), 10))},
+			":zero":     {N: aws.String("0")},
 		},
-		TableName: aws.String(opts.CfgName), // Required
+		UpdateExpression: aws.String("SET #m = if_not_exists(#m, :modified), cfgVersion = if_not_exists(cfgVersion, :zero)"),
+	}
+	if db.shared() {
+		params.ExpressionAttributeNames["#k"] = aws.String("key")
+		params.ConditionExpression = aws.String("attribute_not_exists(#k)")
+	}
+	_, err := svc.UpdateItemWithContext(ctx, params)
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return errors.New("The configuration " + opts.CfgName + " already exists")
+	}
+	return err
+}
 
+// TTLAttributeName is the attribute DynamoDB uses to expire items (epoch seconds). The "expires" attribute, in nanoseconds,
+// is still used to filter expired items on read since DynamoDB doesn't delete them right away.
+const TTLAttributeName = "expiresAt"
+
+// enableTTL waits for a table to exist and then enables DynamoDB's native Time To Live on it.
+func enableTTL(ctx context.Context, svc *dynamodb.DynamoDB, table string) error {
+	err := waitUntilActive(ctx, svc, table)
+	if err != nil {
+		return err
 	}
-	response, err := svc.CreateTable(params)
-	// TODO: Convey this somehow?
-	// if err == nil {
-	// tableStatus := *response.TableDescription.TableStatus
-	// if tableStatus != "CREATING" && tableStatus != "ACTIVE" {
-	// 	err = errors.New("Something went wrong creating tables")
-	// }
-	// }
-	return response, err
+	_, err = svc.UpdateTimeToLiveWithContext(ctx, &dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(table),
+		TimeToLiveSpecification: &dynamodb.TimeToLiveSpecification{
+			AttributeName: aws.String(TTLAttributeName),
+			Enabled:       aws.Bool(true),
+		},
+	})
+	return err
 }
 
-// DeleteConfig deletes a configuration (removing the DynamoDB table and all data within it)
+// DeleteConfig deletes a configuration (removing the DynamoDB table and all data within it). In a shared table,
+// the config's items are deleted instead.
 func (db DynamoDB) DeleteConfig(opts config.Options) (interface{}, error) {
-	svc := Svc(opts)
-	params := &dynamodb.DeleteTableInput{
-		TableName: aws.String(opts.CfgName), // Required
+	return db.DeleteConfigWithContext(context.Background(), opts)
+}
+
+// DeleteConfigWithContext is DeleteConfig with a context, which can cancel the request
+func (db DynamoDB) DeleteConfigWithContext(ctx context.Context, opts config.Options) (interface{}, error) {
+	svc, err := db.svc(opts)
+	if err != nil {
+		return nil, err
+	}
+	// Deleting the root key first means a frozen config is left alone, and it can't be frozen while it's deleted
+	params := &dynamodb.DeleteItemInput{
+		Key:       db.itemKey(opts, "/"),
+		TableName: db.table(opts),
+	}
+	if !opts.DeleteFrozen {
+		names, values := map[string]*string{}, map[string]*dynamodb.AttributeValue{}
+		params.ConditionExpression = aws.String(notFrozen(names, values))
+		params.ExpressionAttributeNames = names
+		params.ExpressionAttributeValues = values
+	}
+	_, err = svc.DeleteItemWithContext(ctx, params)
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return nil, config.ErrFrozen
+	}
+	if err != nil {
+		return nil, err
+	}
+	if db.shared() {
+		return nil, db.deleteItems(ctx, svc, opts)
 	}
-	return svc.DeleteTable(params)
+	return svc.DeleteTableWithContext(ctx, &dynamodb.DeleteTableInput{
+		TableName: aws.String(opts.CfgName), // Required
+	})
 }
 
-// UpdateConfig updates a configuration (DyanmoDB can have its read and write capacity units adjusted as needed)
+// UpdateConfig updates a configuration. Only the settings given are changed (see tableSettings), each waiting on
+// the table to be active again since DynamoDB only allows one kind of table update at a time. With a shared table,
+// the settings apply to every config in it.
 // Note: Adjusting the read capacity is fast, adjusting write capacity takes longer.
 func (db DynamoDB) UpdateConfig(opts config.Options, settings map[string]interface{}) (interface{}, error) {
-	svc := Svc(opts)
-	wu := int64(1)
-	ru := int64(2)
-	if val, ok := settings["WriteCapacityUnits"]; ok {
-		wu = int64(val.(float64))
+	return db.UpdateConfigWithContext(context.Background(), opts, settings)
+}
+
+// UpdateConfigWithContext is UpdateConfig with a context, which can cancel the request
+func (db DynamoDB) UpdateConfigWithContext(ctx context.Context, opts config.Options, settings map[string]interface{}) (interface{}, error) {
+	s, err := parseSettings(settings)
+	if err != nil {
+		return nil, err
+	}
+	svc, err := db.svc(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Configs created before TTL was enabled on create can have it enabled with {"TimeToLive": true}
+	if s.TimeToLive != nil {
+		if err := enableTTL(ctx, svc, db.tableName(opts)); err != nil {
+			return nil, err
+		}
+	}
+
+	var response interface{}
+	updates := []*dynamodb.UpdateTableInput{}
+	if s.BillingMode != nil || s.throughput() != nil {
+		updates = append(updates, &dynamodb.UpdateTableInput{BillingMode: s.BillingMode, ProvisionedThroughput: s.throughput()})
 	}
-	if val, ok := settings["ReadCapacityUnits"]; ok {
-		ru = int64(val.(float64))
+	if spec := s.sse(); spec != nil {
+		updates = append(updates, &dynamodb.UpdateTableInput{SSESpecification: spec})
 	}
-	// Must be at least 1
-	if wu < 1 {
-		wu = int64(1)
+	if s.TableClass != nil {
+		updates = append(updates, &dynamodb.UpdateTableInput{TableClass: s.TableClass})
 	}
-	// Also must be at least 1, default to 2
-	if ru < 1 {
-		ru = int64(2)
+	for _, params := range updates {
+		if err := waitUntilActive(ctx, svc, db.tableName(opts)); err != nil {
+			return response, err
+		}
+		params.TableName = db.table(opts)
+		if response, err = svc.UpdateTableWithContext(ctx, params); err != nil {
+			return response, err
+		}
 	}
 
-	params := &dynamodb.UpdateTableInput{
-		TableName: aws.String(opts.CfgName), // Required
-		ProvisionedThroughput: &dynamodb.ProvisionedThroughput{
-			ReadCapacityUnits:  aws.Int64(ru), // Required
-			WriteCapacityUnits: aws.Int64(wu), // Required
-		},
-		// Not for now. Mainly because only one operation per UpdateTable() call. Makes it annoying.
-		// StreamSpecification: &dynamodb.StreamSpecification{
-		// 	StreamEnabled:  aws.Bool(true),
-		// 	StreamViewType: aws.String("StreamViewType"),
-		// },
+	if len(s.Tags) > 0 {
+		table, err := svc.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{TableName: db.table(opts)})
+		if err != nil {
+			return response, err
+		}
+		_, err = svc.TagResourceWithContext(ctx, &dynamodb.TagResourceInput{ResourceArn: table.Table.TableArn, Tags: s.tags()})
+		if err != nil {
+			return response, err
+		}
+	}
+	if s.PointInTimeRecovery != nil {
+		if err := enablePointInTimeRecovery(ctx, svc, db.tableName(opts), *s.PointInTimeRecovery); err != nil {
+			return response, err
+		}
 	}
-	return svc.UpdateTable(params)
+	return response, nil
 }
 
-// ConfigState returns the DynamoDB table state
+// ConfigState returns the DynamoDB table state. A config in a shared table that doesn't have a root key
+// doesn't exist.
 func (db DynamoDB) ConfigState(opts config.Options) (string, error) {
-	svc := Svc(opts)
-	status := ""
+	return db.ConfigStateWithContext(context.Background(), opts)
+}
 
+// ConfigStateWithContext is ConfigState with a context, which can cancel the request
+func (db DynamoDB) ConfigStateWithContext(ctx context.Context, opts config.Options) (string, error) {
+	svc, err := db.svc(opts)
+	if err != nil {
+		return "", err
+	}
+	state, err := tableState(ctx, svc, db.tableName(opts))
+	if err != nil || !db.shared() || state == config.CfgStateNotFound {
+		return state, err
+	}
+	root, err := svc.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: db.table(opts),
+		Key:       db.itemKey(opts, "/"),
+	})
+	if err == nil && len(root.Item) == 0 {
+		return config.CfgStateNotFound, nil
+	}
+	return state, err
+}
+
+// tableState returns a table's status
+func tableState(ctx context.Context, svc *dynamodb.DynamoDB, table string) (string, error) {
+	status := ""
 	params := &dynamodb.DescribeTableInput{
-		TableName: aws.String(opts.CfgName), // Required
+		TableName: aws.String(table), // Required
 	}
-	resp, err := svc.DescribeTable(params)
-	if err != nil {
-		// Print the error, cast err to awserr.Error to get the Code and
-		// Message from an error.
-		//fmt.Println(err.Error())
+	resp, err := svc.DescribeTableWithContext(ctx, params)
+	if err == nil && resp.Table != nil && resp.Table.TableStatus != nil {
 		status = *resp.Table.TableStatus
 	}
+	// A table that doesn't exist (or no longer does once deleted) is a state rather than an error
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeResourceNotFoundException {
+		return config.CfgStateNotFound, nil
+	}
 	return status, err
 }
 
+// ListConfigs returns the configs in the region. Tables are configs when they have the root key "/", which is
+// created along with the config (or when the first key is set for configs created before that). Tables still being
+// created (or being deleted) are listed by their state. With a shared table, it's the configs in that table.
+func (db DynamoDB) ListConfigs(opts config.Options) ([]config.CfgSummary, error) {
+	return db.ListConfigsWithContext(context.Background(), opts)
+}
+
+// ListConfigsWithContext is ListConfigs with a context, which can cancel the request
+func (db DynamoDB) ListConfigsWithContext(ctx context.Context, opts config.Options) ([]config.CfgSummary, error) {
+	cfgs := []config.CfgSummary{}
+	svc, err := db.svc(opts)
+	if err != nil {
+		return cfgs, err
+	}
+	if db.shared() {
+		return db.listSharedConfigs(ctx, svc)
+	}
+
+	names := []string{}
+	err = svc.ListTablesPagesWithContext(ctx, &dynamodb.ListTablesInput{}, func(page *dynamodb.ListTablesOutput, lastPage bool) bool {
+		for _, name := range page.TableNames {
+			names = append(names, aws.StringValue(name))
+		}
+		return true
+	})
+	if err != nil {
+		return cfgs, err
+	}
+
+	for _, name := range names {
+		table, err := svc.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(name)})
+		if err != nil {
+			// Other tables may have been deleted since they were listed or not be readable by these credentials
+			if skipTableError(err) {
+				continue
+			}
+			return cfgs, err
+		}
+		state := aws.StringValue(table.Table.TableStatus)
+		// Tables being created or deleted can't be read, they're listed (without a version) when they have the key
+		// schema of a config's table. A config's root key is created once its table is active.
+		if state == dynamodb.TableStatusCreating || state == dynamodb.TableStatusDeleting {
+			if hasCfgKeySchema(table.Table) {
+				cfgs = append(cfgs, config.CfgSummary{Name: name, State: state})
+			}
+			continue
+		}
+
+		resp, err := svc.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+			TableName: aws.String(name),
+			Key: map[string]*dynamodb.AttributeValue{
+				"key": {S: aws.String("/")},
+			},
+		})
+		if err != nil {
+			// Other tables may have a different key (a validation error)
+			if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "ValidationException" || skipTableError(err) {
+				continue
+			}
+			return cfgs, err
+		}
+		if len(resp.Item) == 0 {
+			continue
+		}
+		root := itemFromAttributes("/", resp.Item)
+		cfgs = append(cfgs, config.CfgSummary{Name: name, State: state, Version: root.CfgVersion, ModifiedNanoseconds: root.CfgModifiedNanoseconds, Meta: config.ParseCfgMeta(root.Value), Frozen: root.Frozen})
+	}
+	return cfgs, nil
+}
+
+// skipTableError returns whether or not an error reading a table means it should be left out when listing configs,
+// should it no longer exist or not be readable by the credentials
+func skipTableError(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && (aerr.Code() == dynamodb.ErrCodeResourceNotFoundException || aerr.Code() == "AccessDeniedException")
+}
+
+// hasCfgKeySchema returns whether or not a table has the key schema of a config's own table
+func hasCfgKeySchema(table *dynamodb.TableDescription) bool {
+	return len(table.KeySchema) == 1 && aws.StringValue(table.KeySchema[0].AttributeName) == "key" &&
+		aws.StringValue(table.KeySchema[0].KeyType) == dynamodb.KeyTypeHash
+}
+
+// listSharedConfigs returns the configs in the shared table, from their root keys
+func (db DynamoDB) listSharedConfigs(ctx context.Context, svc *dynamodb.DynamoDB) ([]config.CfgSummary, error) {
+	cfgs := []config.CfgSummary{}
+	state, err := tableState(ctx, svc, db.Table)
+	if err != nil || state == config.CfgStateNotFound {
+		return cfgs, err
+	}
+	params := &dynamodb.ScanInput{
+		TableName:                 aws.String(db.Table),
+		ExpressionAttributeNames:  map[string]*string{"#k": aws.String("key")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":root": {S: aws.String("/")}},
+		FilterExpression:          aws.String("#k = :root"),
+	}
+	err = svc.ScanPagesWithContext(ctx, params, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		for _, attributes := range page.Items {
+			root := itemFromAttributes("/", attributes)
+			cfgs = append(cfgs, config.CfgSummary{
+				Name:                aws.StringValue(attributes[cfgAttributeName].S),
+				State:               state,
+				Version:             root.CfgVersion,
+				ModifiedNanoseconds: root.CfgModifiedNanoseconds,
+				Meta:                config.ParseCfgMeta(root.Value),
+				Frozen:              root.Frozen,
+			})
+		}
+		return true
+	})
+	return cfgs, err
+}
+
 // Update a key in DynamoDB
 func (db DynamoDB) Update(opts config.Options) (config.Item, error) {
+	return db.UpdateWithContext(context.Background(), opts)
+}
+
+// UpdateWithContext is Update with a context, which can cancel the request
+func (db DynamoDB) UpdateWithContext(ctx context.Context, opts config.Options) (config.Item, error) {
 	var err error
-	svc := Svc(opts)
+	svc, err := db.svc(opts)
+	if err != nil {
+		return config.Item{}, err
+	}
 	item := config.Item{Key: opts.Key}
 
 	ttlString := strconv.FormatInt(opts.TTL, 10)
@@ -216,6 +472,10 @@ func (db DynamoDB) Update(opts config.Options) (config.Item, error) {
 	if opts.TTL == 0 {
 		expiresString = "0"
 	}
+	// Everything that gets SET and REMOVEd (any attribute not being set should not linger from a previous update)
+	// The sensitive flag is left alone unless given, so setting a sensitive key again doesn't reveal it.
+	sets := []string{"#v = :value", "#t = :ttl", "expires = :expires", "encrypted = :encrypted"}
+	removes := []string{}
 
 	// DynamoDB type cheat sheet:
 	// B: []byte("some bytes")
@@ -233,12 +493,8 @@ func (db DynamoDB) Update(opts config.Options) (config.Item, error) {
 	// But the only way to update is to make the items have a HASH only index instead of HASH + RANGE.
 
 	params := &dynamodb.UpdateItemInput{
-		Key: map[string]*dynamodb.AttributeValue{
-			"key": {
-				S: aws.String(opts.Key),
-			},
-		},
-		TableName: aws.String(opts.CfgName),
+		Key:       db.itemKey(opts, opts.Key),
+		TableName: db.table(opts),
 		// KEY and VALUE are reserved words so the query needs to dereference them
 		ExpressionAttributeNames: map[string]*string{
 			//"#k": aws.String("key"),
@@ -260,6 +516,10 @@ func (db DynamoDB) Update(opts config.Options) (config.Item, error) {
 			":expires": {
 				N: aws.String(expiresString),
 			},
+			// Client side encryption flag
+			":encrypted": {
+				BOOL: aws.Bool(opts.Encrypt),
+			},
 			// version increment
 			":i": {
 				N: aws.String("1"),
@@ -267,22 +527,104 @@ func (db DynamoDB) Update(opts config.Options) (config.Item, error) {
 		},
 		//ReturnConsumedCapacity:      aws.String("TOTAL"),
 		//ReturnItemCollectionMetrics: aws.String("ReturnItemCollectionMetrics"),
-		ReturnValues:     aws.String("ALL_OLD"),
-		UpdateExpression: aws.String("SET #v = :value, #t = :ttl, expires = :expires ADD version :i"),
+		ReturnValues: aws.String("ALL_OLD"),
+	}
+
+	// Numbers are stored as DynamoDB numbers so they can be incremented (encrypted values are always binary)
+	if opts.ValueType == config.ValueTypeNumber && !opts.Encrypt && config.NumberRegexp.Match(opts.Value) {
+		params.ExpressionAttributeValues[":value"] = &dynamodb.AttributeValue{N: aws.String(string(opts.Value))}
+	}
+
+	// The value type is only stored when there is one (DynamoDB doesn't care for empty strings), otherwise any
+	// previous type is removed since the value is now untyped. TYPE is also a reserved word.
+	params.ExpressionAttributeNames["#ty"] = aws.String("type")
+	if opts.ValueType != "" {
+		params.ExpressionAttributeValues[":type"] = &dynamodb.AttributeValue{S: aws.String(opts.ValueType)}
+		sets = append(sets, "#ty = :type")
+	} else {
+		removes = append(removes, "#ty")
+	}
+
+	// Sensitive value flag (for output redaction)
+	if opts.Sensitive != nil {
+		params.ExpressionAttributeValues[":sensitive"] = &dynamodb.AttributeValue{BOOL: opts.Sensitive}
+		sets = append(sets, "sensitive = :sensitive")
+	}
+
+	// Who changed the key and when. Without an author it's unknown, rather than whoever changed it before.
+	// The description and tags are left alone unless given.
+	params.ExpressionAttributeValues[":modified"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(time.Now().UnixNano(), 10))}
+	sets = append(sets, "modified = :modified")
+	if opts.Author != "" {
+		params.ExpressionAttributeValues[":modifiedBy"] = &dynamodb.AttributeValue{S: aws.String(opts.Author)}
+		sets = append(sets, "modifiedBy = :modifiedBy")
+	} else {
+		removes = append(removes, "modifiedBy")
+	}
+	if opts.Description != "" {
+		params.ExpressionAttributeValues[":description"] = &dynamodb.AttributeValue{S: aws.String(opts.Description)}
+		sets = append(sets, "description = :description")
+	}
+	if len(opts.Tags) > 0 {
+		params.ExpressionAttributeValues[":tags"] = tagsAttribute(opts.Tags)
+		sets = append(sets, "tags = :tags")
 	}
 
+	// DynamoDB's native TTL needs an expiration in epoch seconds. Items without it never expire.
+	if opts.TTL > 0 {
+		params.ExpressionAttributeValues[":expiresAt"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(expires.Unix(), 10))}
+		sets = append(sets, TTLAttributeName+" = :expiresAt")
+	} else {
+		removes = append(removes, TTLAttributeName)
+	}
+
+	updateExpression := "SET " + strings.Join(sets, ", ") + " ADD version :i"
+	if len(removes) > 0 {
+		updateExpression += " REMOVE " + strings.Join(removes, ", ")
+	}
+	params.UpdateExpression = aws.String(updateExpression)
+
 	// Conditional write operation (CAS)
+	conditions := []string{}
 	if opts.ConditionalValue != "" {
-		params.ExpressionAttributeValues[":condition"] = &dynamodb.AttributeValue{B: []byte(opts.ConditionalValue)}
-		params.ConditionExpression = aws.String("#v = :condition")
+		conditions = append(conditions, valueCondition(params.ExpressionAttributeValues, opts.ConditionalValue))
+	}
+	// Only when the key doesn't exist or has expired (and is just waiting to be deleted)
+	if opts.ConditionalNotExists {
+		params.ExpressionAttributeNames["#k"] = aws.String("key")
+		params.ExpressionAttributeValues[":now"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(time.Now().UnixNano(), 10))}
+		params.ExpressionAttributeValues[":zero"] = &dynamodb.AttributeValue{N: aws.String("0")}
+		conditions = append(conditions, "attribute_not_exists(#k)", "(expires > :zero AND expires < :now)")
+	}
+	change := itemChange{
+		update: updateExpression,
+		names:  params.ExpressionAttributeNames,
+		values: params.ExpressionAttributeValues,
+	}
+	if len(conditions) > 0 {
+		change.condition = "(" + strings.Join(conditions, " OR ") + ")"
+	}
+	// Conditional on the current version, or there not being one
+	versionCondition := ""
+	if opts.ConditionalVersion > 0 {
+		params.ExpressionAttributeValues[":version"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(opts.ConditionalVersion, 10))}
+		versionCondition = "version = :version"
+	}
+	if opts.ConditionalUnset {
+		versionCondition = "attribute_not_exists(version)"
+	}
+	if versionCondition != "" && change.condition != "" {
+		change.condition += " AND " + versionCondition
+	} else if versionCondition != "" {
+		change.condition = versionCondition
 	}
 
-	response, err := svc.UpdateItem(params)
+	// Setting the root key "/" (the config's metadata) doesn't change the config version
+	old, err := db.write(ctx, svc, opts, db.versionUpdate(opts), change)
 	if err == nil {
 		// The old values
-		if val, ok := response.Attributes["value"]; ok {
-			item.Value = val.B
-			item.Version, _ = strconv.ParseInt(*response.Attributes["version"].N, 10, 64)
+		if _, ok := old["value"]; ok {
+			item = itemFromAttributes(opts.Key, old)
 		}
 	}
 
@@ -291,23 +633,32 @@ func (db DynamoDB) Update(opts config.Options) (config.Item, error) {
 
 // Get a key in DynamoDB
 func (db DynamoDB) Get(opts config.Options) (config.Item, error) {
+	return db.GetWithContext(context.Background(), opts)
+}
+
+// GetWithContext is Get with a context, which can cancel the request
+func (db DynamoDB) GetWithContext(ctx context.Context, opts config.Options) (config.Item, error) {
 	var err error
-	svc := Svc(opts)
+	svc, err := db.svc(opts)
+	if err != nil {
+		return config.Item{}, err
+	}
 	item := config.Item{Key: opts.Key}
 
-	params := &dynamodb.QueryInput{
-		TableName: aws.String(opts.CfgName),
-
-		// KEY and VALUE are reserved words so the query needs to dereference them
-		ExpressionAttributeNames: map[string]*string{
-			"#k": aws.String("key"),
-		},
-		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
-			":key": {
-				S: aws.String(opts.Key),
-			},
+	// KEY and VALUE are reserved words so the query needs to dereference them
+	names := map[string]*string{
+		"#k": aws.String("key"),
+	}
+	values := map[string]*dynamodb.AttributeValue{
+		":key": {
+			S: aws.String(opts.Key),
 		},
-		KeyConditionExpression: aws.String("#k = :key"),
+	}
+	params := &dynamodb.QueryInput{
+		TableName:                 db.table(opts),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+		KeyConditionExpression:    db.keyCondition(opts, names, values, "#k = :key"),
 		// TODO: Return more? It's nice to have a history now whereas previously I thought I might now have one...But what's the use?
 		Limit: aws.Int64(1),
 
@@ -317,9 +668,10 @@ func (db DynamoDB) Get(opts config.Options) (config.Item, error) {
 		// order values were stored. So the first item stored for the key ever would be returned...But the latest item is needed.
 		ScanIndexForward: aws.Bool(false),
 		// http://docs.aws.amazon.com/amazondynamodb/latest/APIReference/API_Query.html#DDB-Query-request-Select
-		Select: aws.String("ALL_ATTRIBUTES"),
+		Select:         aws.String("ALL_ATTRIBUTES"),
+		ConsistentRead: aws.Bool(opts.ConsistentRead),
 	}
-	response, err := svc.Query(params)
+	response, err := svc.QueryWithContext(ctx, params)
 
 	if err == nil {
 		// Print the error, cast err to awserr.Error to get the Code and
@@ -327,58 +679,185 @@ func (db DynamoDB) Get(opts config.Options) (config.Item, error) {
 		//fmt.Println(err.Error())
 
 		if len(response.Items) > 0 {
-			// Every field should now be checked because it's possible to have a response without a value or version.
-			// For example, the root key "/" may only hold information about the config version and modified time.
-			// It may not have a set value and therefore it also won't have a relative version either.
-			// TODO: Maybe it should? We can always version it as 1 even if empty value. Perhaps also an empty string value...
-			// But the update config version would need to have a compare for an empty value. See if DynamoDB can do that.
-			// For now, just check the existence of keys in the map.
-			if val, ok := response.Items[0]["value"]; ok {
-				item.Value = val.B
-			}
-			if val, ok := response.Items[0]["version"]; ok {
-				item.Version, _ = strconv.ParseInt(*val.N, 10, 64)
-			}
+			item = itemFromAttributes(opts.Key, response.Items[0])
+		}
 
-			// Expiration/TTL (only set if > 0)
-			if val, ok := response.Items[0]["ttl"]; ok {
-				ttl, _ := strconv.ParseInt(*val.N, 10, 64)
-				if ttl > 0 {
-					item.TTL = ttl
-				}
-			}
-			if val, ok := response.Items[0]["expires"]; ok {
-				expiresNano, _ := strconv.ParseInt(*val.N, 10, 64)
-				if expiresNano > 0 {
-					item.Expiration = time.Unix(0, expiresNano)
-				}
-			}
+		// Check the TTL. DynamoDB deletes expired items on its own (typically within a couple days), so until then
+		// an expired item is simply treated as if it doesn't exist.
+		if item.TTL > 0 && item.Expiration.UnixNano() < time.Now().UnixNano() {
+			item = config.Item{Key: opts.Key}
+		}
+	}
 
-			// If cfgVersion and cfgModified are set because it's the root key "/" then set those too.
-			// This is only returned for the root key. no sense in making a separate get function because operations like
-			// exporting would then require more queries than necessary. However, it won't be displayed in the item's JSON output.
-			if val, ok := response.Items[0]["cfgVersion"]; ok {
-				item.CfgVersion, _ = strconv.ParseInt(*val.N, 10, 64)
-			}
-			if val, ok := response.Items[0]["cfgModified"]; ok {
-				item.CfgModifiedNanoseconds, _ = strconv.ParseInt(*val.N, 10, 64)
+	return item, err
+}
+
+// List the keys in DynamoDB that begin with the given prefix (opts.Key). An empty prefix lists every key.
+// Expired items that DynamoDB hasn't deleted yet are included, so they can be garbage collected.
+// NOTE: With a table per config this is a Scan and not a Query, so it reads the entire table. Configurations are
+// typically small, but this will consume more read capacity than getting individual keys. In a shared table, it's
+// a Query on the config's partition.
+func (db DynamoDB) List(opts config.Options) ([]config.Item, error) {
+	return db.ListWithContext(context.Background(), opts)
+}
+
+// ListWithContext is List with a context, which can cancel the request
+func (db DynamoDB) ListWithContext(ctx context.Context, opts config.Options) ([]config.Item, error) {
+	svc, err := db.svc(opts)
+	if err != nil {
+		return []config.Item{}, err
+	}
+	items := []config.Item{}
+	add := func(page []map[string]*dynamodb.AttributeValue) {
+		for _, attributes := range page {
+			key := ""
+			if val, ok := attributes["key"]; ok && val.S != nil {
+				key = *val.S
 			}
+			items = append(items, itemFromAttributes(key, attributes))
 		}
+	}
 
-		// Check the TTL
-		if item.TTL > 0 {
-			// If expired, return an empty item
-			if item.Expiration.UnixNano() < time.Now().UnixNano() {
-				item = config.Item{Key: opts.Key}
-				// Delete the now expired item
-				// NOTE: This does mean waiting on another DynamoDB request and that technically means slower performance in these situations, but is it a conern?
-				// A goroutine doesn't help because there's not guarantee there's time for it to complete.
-				db.Delete(opts)
-			}
+	if db.shared() {
+		names := map[string]*string{"#k": aws.String("key")}
+		values := map[string]*dynamodb.AttributeValue{":prefix": {S: aws.String(opts.Key)}}
+		condition := "begins_with(#k, :prefix)"
+		if opts.Key == "" {
+			// The partition alone, begins_with needs a non-empty prefix
+			names, values, condition = map[string]*string{}, map[string]*dynamodb.AttributeValue{}, ""
 		}
+		params := &dynamodb.QueryInput{
+			TableName:                 db.table(opts),
+			ExpressionAttributeNames:  names,
+			ExpressionAttributeValues: values,
+			KeyConditionExpression:    db.keyCondition(opts, names, values, condition),
+			ConsistentRead:            aws.Bool(opts.ConsistentRead),
+		}
+		err = svc.QueryPagesWithContext(ctx, params, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+			add(page.Items)
+			return true
+		})
+		return items, err
 	}
 
-	return item, err
+	params := &dynamodb.ScanInput{
+		TableName:      aws.String(opts.CfgName),
+		ConsistentRead: aws.Bool(opts.ConsistentRead),
+	}
+	if opts.Key != "" {
+		params.ExpressionAttributeNames = map[string]*string{
+			"#k": aws.String("key"),
+		}
+		params.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
+			":prefix": {
+				S: aws.String(opts.Key),
+			},
+		}
+		params.FilterExpression = aws.String("begins_with(#k, :prefix)")
+	}
+
+	err = svc.ScanPagesWithContext(ctx, params, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		add(page.Items)
+		return true
+	})
+
+	return items, err
+}
+
+// itemFromAttributes builds an Item from a DynamoDB item's attributes.
+func itemFromAttributes(key string, attributes map[string]*dynamodb.AttributeValue) config.Item {
+	item := config.Item{Key: key}
+
+	// Every field should now be checked because it's possible to have a response without a value or version.
+	// For example, the root key "/" may only hold information about the config version and modified time.
+	// It may not have a set value and therefore it also won't have a relative version either.
+	// TODO: Maybe it should? We can always version it as 1 even if empty value. Perhaps also an empty string value...
+	// But the update config version would need to have a compare for an empty value. See if DynamoDB can do that.
+	// For now, just check the existence of keys in the map.
+	if val, ok := attributes["value"]; ok {
+		item.Value = val.B
+		// Numbers (which can be incremented) are stored as DynamoDB numbers
+		if val.N != nil {
+			item.Value = []byte(*val.N)
+		}
+	}
+	if val, ok := attributes["version"]; ok {
+		item.Version, _ = strconv.ParseInt(*val.N, 10, 64)
+	}
+	if val, ok := attributes["encrypted"]; ok && val.BOOL != nil {
+		item.Encrypted = *val.BOOL
+	}
+	if val, ok := attributes["sensitive"]; ok && val.BOOL != nil {
+		item.Sensitive = *val.BOOL
+	}
+	if val, ok := attributes["type"]; ok && val.S != nil {
+		item.Type = *val.S
+	}
+
+	if val, ok := attributes["modified"]; ok && val.N != nil {
+		item.Modified, _ = strconv.ParseInt(*val.N, 10, 64)
+	}
+	if val, ok := attributes["modifiedBy"]; ok && val.S != nil {
+		item.ModifiedBy = *val.S
+	}
+	if val, ok := attributes["description"]; ok && val.S != nil {
+		item.Description = *val.S
+	}
+	if val, ok := attributes["tags"]; ok && len(val.M) > 0 {
+		item.Tags = map[string]string{}
+		for k, v := range val.M {
+			item.Tags[k] = aws.StringValue(v.S)
+		}
+	}
+
+	// Expiration/TTL (only set if > 0)
+	if val, ok := attributes["ttl"]; ok {
+		ttl, _ := strconv.ParseInt(*val.N, 10, 64)
+		if ttl > 0 {
+			item.TTL = ttl
+		}
+	}
+	if val, ok := attributes["expires"]; ok {
+		expiresNano, _ := strconv.ParseInt(*val.N, 10, 64)
+		if expiresNano > 0 {
+			item.Expiration = time.Unix(0, expiresNano)
+		}
+	}
+
+	// If cfgVersion and cfgModified are set because it's the root key "/" then set those too.
+	// This is only returned for the root key. no sense in making a separate get function because operations like
+	// exporting would then require more queries than necessary. However, it won't be displayed in the item's JSON output.
+	if val, ok := attributes["cfgVersion"]; ok {
+		item.CfgVersion, _ = strconv.ParseInt(*val.N, 10, 64)
+	}
+	if val, ok := attributes["cfgModified"]; ok {
+		item.CfgModifiedNanoseconds, _ = strconv.ParseInt(*val.N, 10, 64)
+	}
+	if val, ok := attributes["frozen"]; ok && val.BOOL != nil {
+		item.Frozen = *val.BOOL
+	}
+
+	return item
+}
+
+// tagsAttribute returns a key's tags as a DynamoDB map
+func tagsAttribute(tags map[string]string) *dynamodb.AttributeValue {
+	m := map[string]*dynamodb.AttributeValue{}
+	for k, v := range tags {
+		m[k] = &dynamodb.AttributeValue{S: aws.String(v)}
+	}
+	return &dynamodb.AttributeValue{M: m}
+}
+
+// valueCondition sets the conditional value and returns the condition comparing it to the value. Numbers may be stored
+// as binary data or as DynamoDB numbers (see Update), so numeric conditional values are compared as both.
+func valueCondition(values map[string]*dynamodb.AttributeValue, conditionalValue string) string {
+	values[":condition"] = &dynamodb.AttributeValue{B: []byte(conditionalValue)}
+	if config.NumberRegexp.MatchString(conditionalValue) {
+		values[":conditionNumber"] = &dynamodb.AttributeValue{N: aws.String(conditionalValue)}
+		return "#v IN (:condition, :conditionNumber)"
+	}
+	return "#v = :condition"
 }
 
 // Deprecated or at best delayed...
@@ -418,73 +897,258 @@ func getChildren(svc *dynamodb.DynamoDB, opts config.Options) ([]config.Item, er
 
 // Delete a key in DynamoDB
 func (db DynamoDB) Delete(opts config.Options) (config.Item, error) {
-	var err error
-	svc := Svc(opts)
-	item := config.Item{Key: opts.Key}
+	return db.DeleteWithContext(context.Background(), opts)
+}
 
-	params := &dynamodb.DeleteItemInput{
-		Key: map[string]*dynamodb.AttributeValue{
-			"key": {
-				S: aws.String(opts.Key),
-			},
-		},
-		TableName:    aws.String(opts.CfgName),
-		ReturnValues: aws.String("ALL_OLD"),
-		// TODO: think about this for statistics
-		// INDEXES | TOTAL | NONE
-		//ReturnConsumedCapacity: aws.String("ReturnConsumedCapacity"),
+// DeleteWithContext is Delete with a context, which can cancel the request
+func (db DynamoDB) DeleteWithContext(ctx context.Context, opts config.Options) (config.Item, error) {
+	var err error
+	svc, err := db.svc(opts)
+	if err != nil {
+		return config.Item{}, err
 	}
+	item := config.Item{Key: opts.Key}
 
 	// Conditional delete operation
+	change := itemChange{}
+	conditions := []string{}
 	if opts.ConditionalValue != "" {
 		// Alias value since it's a reserved word
-		params.ExpressionAttributeNames = make(map[string]*string)
-		params.ExpressionAttributeNames["#v"] = aws.String("value")
+		change.names = map[string]*string{"#v": aws.String("value")}
 		// Set the condition expression value and compare
-		params.ExpressionAttributeValues = make(map[string]*dynamodb.AttributeValue)
-		params.ExpressionAttributeValues[":condition"] = &dynamodb.AttributeValue{B: []byte(opts.ConditionalValue)}
-		params.ConditionExpression = aws.String("#v = :condition")
+		change.values = map[string]*dynamodb.AttributeValue{}
+		conditions = append(conditions, valueCondition(change.values, opts.ConditionalValue))
+	}
+	// Only when the key has expired (and is just waiting to be deleted)
+	if opts.ConditionalExpired {
+		if change.values == nil {
+			change.values = map[string]*dynamodb.AttributeValue{}
+		}
+		change.values[":now"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(time.Now().UnixNano(), 10))}
+		change.values[":zero"] = &dynamodb.AttributeValue{N: aws.String("0")}
+		conditions = append(conditions, "(expires > :zero AND expires < :now)")
 	}
+	change.condition = strings.Join(conditions, " AND ")
 
-	response, err := svc.DeleteItem(params)
+	old, err := db.write(ctx, svc, opts, db.versionUpdate(opts), change)
 	if err == nil {
-		if len(response.Attributes) > 0 {
-			item.Value = response.Attributes["value"].B
-			item.Version, _ = strconv.ParseInt(*response.Attributes["version"].N, 10, 64)
+		if len(old) > 0 {
+			item = itemFromAttributes(opts.Key, old)
 		}
 	}
 
 	return item, err
 }
 
-// UpdateConfigVersion updates the configuration's global version and modified timestamp (fields unique to the root key "/")
-func (db DynamoDB) UpdateConfigVersion(opts config.Options) error {
-	svc := Svc(opts)
+// Touch resets the TTL for a key in DynamoDB, only updating the ttl and expiration attributes (the value and version
+// are left alone). The key must exist and not be expired. If a version or value is given in the options, it must also match.
+func (db DynamoDB) Touch(opts config.Options) (config.Item, error) {
+	return db.TouchWithContext(context.Background(), opts)
+}
+
+// TouchWithContext is Touch with a context, which can cancel the request
+func (db DynamoDB) TouchWithContext(ctx context.Context, opts config.Options) (config.Item, error) {
+	svc, err := db.svc(opts)
+	if err != nil {
+		return config.Item{}, err
+	}
+	item := config.Item{Key: opts.Key}
 	now := time.Now()
+	expires := now.Add(time.Duration(opts.TTL) * time.Second)
+	expiresString := strconv.FormatInt(expires.UnixNano(), 10)
+	if opts.TTL == 0 {
+		expiresString = "0"
+	}
+
 	params := &dynamodb.UpdateItemInput{
-		Key: map[string]*dynamodb.AttributeValue{
-			"key": {
-				S: aws.String("/"),
+		Key:       db.itemKey(opts, opts.Key),
+		TableName: db.table(opts),
+		ExpressionAttributeNames: map[string]*string{
+			"#k": aws.String("key"),
+			"#t": aws.String("ttl"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":ttl": {
+				N: aws.String(strconv.FormatInt(opts.TTL, 10)),
+			},
+			":expires": {
+				N: aws.String(expiresString),
+			},
+			":now": {
+				N: aws.String(strconv.FormatInt(now.UnixNano(), 10)),
+			},
+			":zero": {
+				N: aws.String("0"),
 			},
 		},
-		TableName: aws.String(opts.CfgName),
+		// Without this condition, touching a key that doesn't exist would create an item without a value.
+		ConditionExpression: aws.String("attribute_exists(#k) AND (attribute_not_exists(expires) OR expires = :zero OR expires > :now)"),
+		ReturnValues:        aws.String("ALL_NEW"),
+	}
+
+	if opts.TTL > 0 {
+		params.ExpressionAttributeValues[":expiresAt"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(expires.Unix(), 10))}
+		params.UpdateExpression = aws.String("SET #t = :ttl, expires = :expires, " + TTLAttributeName + " = :expiresAt")
+	} else {
+		params.UpdateExpression = aws.String("SET #t = :ttl, expires = :expires REMOVE " + TTLAttributeName)
+	}
+
+	// Conditional on the current version
+	if opts.ConditionalVersion > 0 {
+		params.ExpressionAttributeValues[":version"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(opts.ConditionalVersion, 10))}
+		params.ConditionExpression = aws.String(*params.ConditionExpression + " AND version = :version")
+	}
+	// Conditional on the current value
+	if opts.ConditionalValue != "" {
+		params.ExpressionAttributeNames["#v"] = aws.String("value")
+		params.ConditionExpression = aws.String(*params.ConditionExpression + " AND " + valueCondition(params.ExpressionAttributeValues, opts.ConditionalValue))
+	}
+
+	// Touching doesn't change the config version, but it's still not allowed once the config is frozen
+	old, err := db.write(ctx, svc, opts, db.frozenCheck(opts), itemChange{
+		update:    *params.UpdateExpression,
+		condition: *params.ConditionExpression,
+		names:     params.ExpressionAttributeNames,
+		values:    params.ExpressionAttributeValues,
+	})
+	if err == nil {
+		old["ttl"] = params.ExpressionAttributeValues[":ttl"]
+		old["expires"] = params.ExpressionAttributeValues[":expires"]
+		item = itemFromAttributes(opts.Key, old)
+	}
+	return item, err
+}
+
+// Increment atomically adds to a key's value (a DynamoDB number) and returns the item with its new value. Keys that
+// don't exist start at 0. Values stored as binary data (values that weren't set as numbers) can't be incremented and
+// neither can expired keys. If a version is given in the options, it must match.
+func (db DynamoDB) Increment(opts config.Options, delta float64) (config.Item, error) {
+	return db.IncrementWithContext(context.Background(), opts, delta)
+}
+
+// IncrementWithContext is Increment with a context, which can cancel the request
+func (db DynamoDB) IncrementWithContext(ctx context.Context, opts config.Options, delta float64) (config.Item, error) {
+	svc, err := db.svc(opts)
+	if err != nil {
+		return config.Item{}, err
+	}
+	item := config.Item{Key: opts.Key}
+
+	params := &dynamodb.UpdateItemInput{
+		Key:       db.itemKey(opts, opts.Key),
+		TableName: db.table(opts),
 		ExpressionAttributeNames: map[string]*string{
-			"#m": aws.String("cfgModified"),
+			"#v":  aws.String("value"),
+			"#ty": aws.String("type"),
 		},
 		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
-			// modified timestamp (DynamoDB has no date type)
-			":modified": {
-				N: aws.String(strconv.FormatInt(now.UnixNano(), 10)),
+			":delta": {
+				N: aws.String(strconv.FormatFloat(delta, 'f', -1, 64)),
+			},
+			":type": {
+				S: aws.String(config.ValueTypeNumber),
 			},
-			// version increment
 			":i": {
 				N: aws.String("1"),
 			},
+			":now": {
+				N: aws.String(strconv.FormatInt(time.Now().UnixNano(), 10)),
+			},
+			":zero": {
+				N: aws.String("0"),
+			},
 		},
-		ReturnValues:     aws.String("NONE"),
-		UpdateExpression: aws.String("SET #m = :modified ADD cfgVersion :i"),
+		UpdateExpression:    aws.String("SET #ty = :type, modified = :now ADD #v :delta, version :i REMOVE modifiedBy"),
+		ConditionExpression: aws.String("attribute_not_exists(expires) OR expires = :zero OR expires > :now"),
+		ReturnValues:        aws.String("ALL_NEW"),
+	}
+	if opts.Author != "" {
+		params.ExpressionAttributeValues[":modifiedBy"] = &dynamodb.AttributeValue{S: aws.String(opts.Author)}
+		params.UpdateExpression = aws.String("SET #ty = :type, modified = :now, modifiedBy = :modifiedBy ADD #v :delta, version :i")
+	}
+
+	// Conditional on the current version
+	if opts.ConditionalVersion > 0 {
+		params.ExpressionAttributeValues[":version"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(opts.ConditionalVersion, 10))}
+		params.ConditionExpression = aws.String("(" + *params.ConditionExpression + ") AND version = :version")
+	}
+
+	old, err := db.write(ctx, svc, opts, db.versionUpdate(opts), itemChange{
+		update:    *params.UpdateExpression,
+		condition: *params.ConditionExpression,
+		names:     params.ExpressionAttributeNames,
+		values:    params.ExpressionAttributeValues,
+	})
+	if err == nil {
+		// The new item is the old one with the change made to it, as DynamoDB would have
+		n := "0"
+		if v, ok := old["value"]; ok && v.N != nil {
+			n = *v.N
+		}
+		old["value"] = &dynamodb.AttributeValue{N: aws.String(addNumbers(n, delta))}
+		version := "1"
+		if v, ok := old["version"]; ok && v.N != nil {
+			version = addNumbers(*v.N, 1)
+		}
+		old["version"] = &dynamodb.AttributeValue{N: aws.String(version)}
+		old["type"] = params.ExpressionAttributeValues[":type"]
+		old["modified"] = params.ExpressionAttributeValues[":now"]
+		delete(old, "modifiedBy")
+		if opts.Author != "" {
+			old["modifiedBy"] = params.ExpressionAttributeValues[":modifiedBy"]
+		}
+		item = itemFromAttributes(opts.Key, old)
+	}
+	return item, err
+}
+
+// UpdateConfigVersion updates the configuration's global version and modified timestamp (fields unique to the root key "/").
+// It's conditional on the config not being frozen. Changes to keys update the version themselves, in the same write.
+func (db DynamoDB) UpdateConfigVersion(opts config.Options) error {
+	return db.UpdateConfigVersionWithContext(context.Background(), opts)
+}
+
+// UpdateConfigVersionWithContext is UpdateConfigVersion with a context, which can cancel the request
+func (db DynamoDB) UpdateConfigVersionWithContext(ctx context.Context, opts config.Options) error {
+	svc, err := db.svc(opts)
+	if err != nil {
+		return err
+	}
+	u := db.versionUpdate(opts).Update
+	params := &dynamodb.UpdateItemInput{
+		Key:                       u.Key,
+		TableName:                 u.TableName,
+		ExpressionAttributeNames:  u.ExpressionAttributeNames,
+		ExpressionAttributeValues: u.ExpressionAttributeValues,
+		UpdateExpression:          u.UpdateExpression,
+		ConditionExpression:       u.ConditionExpression,
+	}
+	_, err = svc.UpdateItemWithContext(ctx, params)
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return config.ErrFrozen
+	}
+	return err
+}
+
+// FreezeWithContext freezes (or unfreezes) a config, setting the frozen flag on its root key. Keys can't be changed
+// while it's set (see write).
+func (db DynamoDB) FreezeWithContext(ctx context.Context, opts config.Options, frozen bool) error {
+	svc, err := db.svc(opts)
+	if err != nil {
+		return err
+	}
+	_, err = svc.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		Key:                       db.itemKey(opts, "/"),
+		TableName:                 db.table(opts),
+		ExpressionAttributeNames:  map[string]*string{"#k": aws.String("key"), "#f": aws.String("frozen")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":frozen": {BOOL: aws.Bool(frozen)}},
+		UpdateExpression:          aws.String("SET #f = :frozen"),
+		// Only configs that exist can be frozen, a shared table would otherwise gain an empty config
+		ConditionExpression: aws.String("attribute_exists(#k)"),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return errors.New("The configuration " + opts.CfgName + " doesn't exist")
 	}
-	_, err := svc.UpdateItem(params)
 	return err
 }
 
@@ -0,0 +1,311 @@
+package database
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/tmaiaroto/discfg/config"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// TestIntegration runs every Shipper method against a real endpoint; DynamoDB Local, LocalStack, ScyllaDB Alternator
+// or anything else speaking the DynamoDB API. It's skipped unless DISCFG_DYNAMODB_ENDPOINT is set, for example:
+//
+//	docker run -p 8000:8000 amazon/dynamodb-local
+//	DISCFG_DYNAMODB_ENDPOINT=http://localhost:8000 go test ./storage/dynamodb/
+func TestIntegration(t *testing.T) {
+	endpoint := os.Getenv(EndpointEnvVar)
+	if endpoint == "" {
+		t.Skip(EndpointEnvVar + " is not set")
+	}
+
+	opts := config.Options{CfgName: "discfg_integration_" + strconv.FormatInt(time.Now().UnixNano(), 10)}
+	opts.Storage.AWS.Region = "us-east-1"
+	opts.Storage.AWS.Endpoint = endpoint
+	// Local stand-ins accept any credentials, but there must be some
+	if os.Getenv("AWS_ACCESS_KEY_ID") == "" {
+		opts.Storage.AWS.AccessKeyID = "discfg"
+		opts.Storage.AWS.SecretAccessKey = "discfg"
+	}
+
+	shared := DynamoDB{Table: opts.CfgName + "_shared"}
+	testIntegration(t, DynamoDB{}, opts)
+	testIntegration(t, shared, opts)
+
+	Convey("A config should be migrated into a shared table", t, func() {
+		db := DynamoDB{}
+		_, err := db.CreateConfig(opts, map[string]interface{}{})
+		So(err, ShouldBeNil)
+		defer db.DeleteConfig(opts)
+		key := opts
+		key.Key = "greeting"
+		key.Value = []byte("hello")
+		_, err = db.Update(key)
+		So(err, ShouldBeNil)
+
+		n, err := db.Migrate(shared, opts)
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, 2)
+		item, err := shared.Get(key)
+		So(err, ShouldBeNil)
+		So(string(item.Value.([]byte)), ShouldEqual, "hello")
+
+		_, err = db.Migrate(shared, opts)
+		So(err, ShouldNotBeNil)
+		_, err = shared.DeleteConfig(opts)
+		So(err, ShouldBeNil)
+	})
+
+	Convey("Keys should be written without transactions when they're turned off", t, func() {
+		db := DynamoDB{Table: shared.Table, NoTransactions: true}
+		So(db.AtomicVersions(), ShouldBeFalse)
+		_, err := db.CreateConfig(opts, map[string]interface{}{})
+		So(err, ShouldBeNil)
+		defer db.DeleteConfig(opts)
+		key := opts
+		key.Key = "greeting"
+		key.Value = []byte("hello")
+		item, err := db.Update(key)
+		So(err, ShouldBeNil)
+		So(item.Value, ShouldBeNil)
+		key.Value = []byte("hi")
+		item, err = db.Update(key)
+		So(err, ShouldBeNil)
+		So(string(item.Value.([]byte)), ShouldEqual, "hello")
+
+		key.ConditionalValue = "hello"
+		_, err = db.Update(key)
+		So(err, ShouldNotBeNil)
+		key.ConditionalValue = ""
+		item, err = db.Delete(key)
+		So(err, ShouldBeNil)
+		So(string(item.Value.([]byte)), ShouldEqual, "hi")
+
+		So(db.FreezeWithContext(context.Background(), opts, true), ShouldBeNil)
+		root := opts
+		root.Key = "/"
+		root.Value = []byte("{}")
+		_, err = db.Update(root)
+		So(err, ShouldEqual, config.ErrFrozen)
+		So(db.FreezeWithContext(context.Background(), opts, false), ShouldBeNil)
+	})
+	svc(shared, opts).DeleteTable(&dynamodb.DeleteTableInput{TableName: aws.String(shared.Table)})
+}
+
+// testIntegration runs every Shipper method for a config in the given layout
+func testIntegration(t *testing.T, db DynamoDB, opts config.Options) {
+	keyOpts := func(key string, value string) config.Options {
+		o := opts
+		o.Key = key
+		if value != "" {
+			o.Value = []byte(value)
+		}
+		return o
+	}
+
+	Convey("A config should be created", t, func() {
+		_, err := db.CreateConfig(opts, map[string]interface{}{})
+		So(err, ShouldBeNil)
+	})
+	defer db.DeleteConfig(opts)
+
+	Convey("The config should have a name, options and a state", t, func() {
+		So(db.Name(opts), ShouldEqual, "DynamoDB")
+		So(db.Options(opts), ShouldNotBeEmpty)
+		state, err := db.ConfigState(opts)
+		So(err, ShouldBeNil)
+		So(state, ShouldEqual, "ACTIVE")
+	})
+
+	Convey("The config should be listed", t, func() {
+		cfgs, err := db.ListConfigs(opts)
+		So(err, ShouldBeNil)
+		found := false
+		for _, cfg := range cfgs {
+			if cfg.Name == opts.CfgName {
+				found = true
+				So(cfg.State, ShouldEqual, "ACTIVE")
+				So(cfg.Version, ShouldEqual, 0)
+			}
+		}
+		So(found, ShouldBeTrue)
+	})
+
+	Convey("The config should be updated", t, func() {
+		_, err := db.UpdateConfig(opts, map[string]interface{}{"ReadCapacityUnits": float64(3), "WriteCapacityUnits": float64(2)})
+		So(err, ShouldBeNil)
+	})
+
+	Convey("The config version should be updated", t, func() {
+		So(db.UpdateConfigVersion(opts), ShouldBeNil)
+		So(db.UpdateConfigVersion(opts), ShouldBeNil)
+		root, err := db.Get(keyOpts("/", ""))
+		So(err, ShouldBeNil)
+		So(root.CfgVersion, ShouldEqual, 2)
+	})
+
+	Convey("A frozen config's version shouldn't be updated", t, func() {
+		ctx := context.Background()
+		So(db.FreezeWithContext(ctx, opts, true), ShouldBeNil)
+		So(db.UpdateConfigVersion(opts), ShouldEqual, config.ErrFrozen)
+		root, _ := db.Get(keyOpts("/", ""))
+		So(root.Frozen, ShouldBeTrue)
+		So(root.CfgVersion, ShouldEqual, 2)
+
+		So(db.FreezeWithContext(ctx, opts, false), ShouldBeNil)
+		So(db.UpdateConfigVersion(opts), ShouldBeNil)
+	})
+
+	Convey("A frozen config's keys shouldn't change", t, func() {
+		ctx := context.Background()
+		_, err := db.Update(keyOpts("frozen", "1"))
+		So(err, ShouldBeNil)
+		So(db.FreezeWithContext(ctx, opts, true), ShouldBeNil)
+
+		_, err = db.Update(keyOpts("frozen", "2"))
+		So(err, ShouldEqual, config.ErrFrozen)
+		_, err = db.Update(keyOpts("/", "{}"))
+		So(err, ShouldEqual, config.ErrFrozen)
+		touch := keyOpts("frozen", "")
+		touch.TTL = 60
+		_, err = db.Touch(touch)
+		So(err, ShouldEqual, config.ErrFrozen)
+		_, err = db.Increment(keyOpts("frozen", ""), 1)
+		So(err, ShouldEqual, config.ErrFrozen)
+		_, err = db.Delete(keyOpts("frozen", ""))
+		So(err, ShouldEqual, config.ErrFrozen)
+		_, err = db.DeleteConfig(opts)
+		So(err, ShouldEqual, config.ErrFrozen)
+
+		// Keys that aren't part of the config (locks) still change
+		unversioned := keyOpts("frozen_lock", "held")
+		unversioned.Unversioned = true
+		_, err = db.Update(unversioned)
+		So(err, ShouldBeNil)
+		_, err = db.Delete(unversioned)
+		So(err, ShouldBeNil)
+
+		So(db.FreezeWithContext(ctx, opts, false), ShouldBeNil)
+		item, err := db.Delete(keyOpts("frozen", ""))
+		So(err, ShouldBeNil)
+		So(string(item.Value.([]byte)), ShouldEqual, "1")
+	})
+
+	Convey("A key should be set and updated, returning the previous item", t, func() {
+		item, err := db.Update(keyOpts("greeting", "hello"))
+		So(err, ShouldBeNil)
+		So(item.Value, ShouldBeNil)
+
+		item, err = db.Update(keyOpts("greeting", "hi"))
+		So(err, ShouldBeNil)
+		So(string(item.Value.([]byte)), ShouldEqual, "hello")
+		So(item.Version, ShouldEqual, 1)
+
+		conditional := keyOpts("greeting", "hey")
+		conditional.ConditionalValue = "hello"
+		_, err = db.Update(conditional)
+		So(err, ShouldNotBeNil)
+		conditional.ConditionalValue = ""
+		conditional.ConditionalVersion = 1
+		_, err = db.Update(conditional)
+		So(err, ShouldNotBeNil)
+		conditional.ConditionalVersion = 0
+		conditional.ConditionalUnset = true
+		_, err = db.Update(conditional)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("A key should record who set it and when, along with its description and tags", t, func() {
+		o := keyOpts("described", "a")
+		o.Author, o.Description, o.Tags = "ops", "A described key", map[string]string{"env": "prod"}
+		_, err := db.Update(o)
+		So(err, ShouldBeNil)
+		_, err = db.Update(keyOpts("described", "b"))
+		So(err, ShouldBeNil)
+
+		item, err := db.Get(keyOpts("described", ""))
+		So(err, ShouldBeNil)
+		So(item.Modified, ShouldBeGreaterThan, 0)
+		So(item.ModifiedBy, ShouldEqual, "")
+		So(item.Description, ShouldEqual, "A described key")
+		So(item.Tags, ShouldResemble, map[string]string{"env": "prod"})
+		_, err = db.Delete(keyOpts("described", ""))
+		So(err, ShouldBeNil)
+	})
+
+	Convey("A key should be returned", t, func() {
+		item, err := db.Get(keyOpts("greeting", ""))
+		So(err, ShouldBeNil)
+		So(string(item.Value.([]byte)), ShouldEqual, "hi")
+
+		item, err = db.Get(keyOpts("missing", ""))
+		So(err, ShouldBeNil)
+		So(item.Value, ShouldBeNil)
+	})
+
+	Convey("Keys should be listed by prefix", t, func() {
+		_, err := db.Update(keyOpts("app/one", "1"))
+		So(err, ShouldBeNil)
+		_, err = db.Update(keyOpts("app/two", "2"))
+		So(err, ShouldBeNil)
+
+		items, err := db.List(keyOpts("app/", ""))
+		So(err, ShouldBeNil)
+		So(len(items), ShouldEqual, 2)
+	})
+
+	Convey("A key's TTL should be reset", t, func() {
+		touch := keyOpts("greeting", "")
+		touch.TTL = 60
+		item, err := db.Touch(touch)
+		So(err, ShouldBeNil)
+		So(item.TTL, ShouldEqual, 60)
+		So(item.Expiration, ShouldHappenAfter, time.Now())
+
+		touch.Key = "missing"
+		_, err = db.Touch(touch)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("A number should be incremented", t, func() {
+		item, err := db.Increment(keyOpts("counter", ""), 2)
+		So(err, ShouldBeNil)
+		So(string(item.Value.([]byte)), ShouldEqual, "2")
+
+		item, err = db.Increment(keyOpts("counter", ""), 0.5)
+		So(err, ShouldBeNil)
+		So(string(item.Value.([]byte)), ShouldEqual, "2.5")
+		So(item.Type, ShouldEqual, config.ValueTypeNumber)
+	})
+
+	Convey("A key should be deleted", t, func() {
+		item, err := db.Delete(keyOpts("greeting", ""))
+		So(err, ShouldBeNil)
+		So(string(item.Value.([]byte)), ShouldEqual, "hi")
+
+		item, err = db.Get(keyOpts("greeting", ""))
+		So(err, ShouldBeNil)
+		So(item.Value, ShouldBeNil)
+	})
+
+	Convey("A canceled context should stop a request", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := db.GetWithContext(ctx, keyOpts("counter", ""))
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("The config should be deleted", t, func() {
+		_, err := db.DeleteConfig(opts)
+		So(err, ShouldBeNil)
+		if db.shared() {
+			state, err := db.ConfigState(opts)
+			So(err, ShouldBeNil)
+			So(state, ShouldEqual, config.CfgStateNotFound)
+		}
+	})
+}
@@ -0,0 +1,242 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/tmaiaroto/discfg/config"
+	"os"
+	"time"
+)
+
+// Configs are stored in one of two layouts. By default each config is its own table, keyed by the item key.
+// Alternatively, many configs share one table (see DynamoDB.Table) keyed by the config name (the partition key)
+// and the item key (the sort key). A shared table avoids the account's table limit and paying for capacity on
+// dozens of tiny tables, though settings such as billing mode then apply to every config in it.
+
+// DefaultSharedTable is the shared table used by the "dynamodb-shared" storage engine, unless the
+// DISCFG_DYNAMODB_TABLE environment variable names another
+const DefaultSharedTable = "discfg"
+
+// SharedTableEnvVar is the environment variable naming the shared table
+const SharedTableEnvVar = "DISCFG_DYNAMODB_TABLE"
+
+// cfgAttributeName is the partition key holding the config name in a shared table
+const cfgAttributeName = "cfg"
+
+// SharedTableName returns the shared table name from the environment, or the default
+func SharedTableName() string {
+	if name := os.Getenv(SharedTableEnvVar); name != "" {
+		return name
+	}
+	return DefaultSharedTable
+}
+
+// shared returns whether or not configs share a table
+func (db DynamoDB) shared() bool {
+	return db.Table != ""
+}
+
+// tableName returns the name of the table a config is stored in
+func (db DynamoDB) tableName(opts config.Options) string {
+	if db.shared() {
+		return db.Table
+	}
+	return opts.CfgName
+}
+
+// table returns the name of the table a config is stored in, for request params
+func (db DynamoDB) table(opts config.Options) *string {
+	return aws.String(db.tableName(opts))
+}
+
+// itemKey returns the primary key of an item in a config
+func (db DynamoDB) itemKey(opts config.Options, key string) map[string]*dynamodb.AttributeValue {
+	k := map[string]*dynamodb.AttributeValue{
+		"key": {S: aws.String(key)},
+	}
+	if db.shared() {
+		k[cfgAttributeName] = &dynamodb.AttributeValue{S: aws.String(opts.CfgName)}
+	}
+	return k
+}
+
+// keyCondition returns a query's key condition, limited to the config in a shared table. The condition's
+// attribute names and values are added to those given. An empty condition is the whole config.
+func (db DynamoDB) keyCondition(opts config.Options, names map[string]*string, values map[string]*dynamodb.AttributeValue, condition string) *string {
+	if !db.shared() {
+		return aws.String(condition)
+	}
+	names["#cfg"] = aws.String(cfgAttributeName)
+	values[":cfg"] = &dynamodb.AttributeValue{S: aws.String(opts.CfgName)}
+	if condition == "" {
+		return aws.String("#cfg = :cfg")
+	}
+	return aws.String("#cfg = :cfg AND " + condition)
+}
+
+// keySchema returns the attribute definitions and key schema for a new table
+func (db DynamoDB) keySchema() ([]*dynamodb.AttributeDefinition, []*dynamodb.KeySchemaElement) {
+	if db.shared() {
+		return []*dynamodb.AttributeDefinition{
+			{AttributeName: aws.String(cfgAttributeName), AttributeType: aws.String("S")},
+			{AttributeName: aws.String("key"), AttributeType: aws.String("S")},
+		}, []*dynamodb.KeySchemaElement{
+			{AttributeName: aws.String(cfgAttributeName), KeyType: aws.String("HASH")},
+			{AttributeName: aws.String("key"), KeyType: aws.String("RANGE")},
+		}
+	}
+	return []*dynamodb.AttributeDefinition{
+		{AttributeName: aws.String("key"), AttributeType: aws.String("S")},
+	}, []*dynamodb.KeySchemaElement{
+		{AttributeName: aws.String("key"), KeyType: aws.String("HASH")},
+	}
+}
+
+// batchSize is the most items BatchWriteItem takes at once
+const batchSize = 25
+
+// Retrying unprocessed items backs off from the first delay, doubling up to the max delay
+const (
+	batchFirstDelay = 50 * time.Millisecond
+	batchMaxDelay   = 5 * time.Second
+)
+
+// Migrate copies a config to another layout, for example from its own table into a shared table. The config is
+// created in the destination first, so it's an error should it already exist there. The source is left as is,
+// it can be deleted once the copy is checked. Returns the number of items copied.
+func (db DynamoDB) Migrate(to DynamoDB, opts config.Options) (int, error) {
+	return db.MigrateWithContext(context.Background(), to, opts)
+}
+
+// MigrateWithContext is Migrate with a context, which can cancel the requests
+func (db DynamoDB) MigrateWithContext(ctx context.Context, to DynamoDB, opts config.Options) (int, error) {
+	if db.tableName(opts) == to.tableName(opts) {
+		return 0, errors.New("The configuration " + opts.CfgName + " is already stored in " + to.tableName(opts))
+	}
+	if _, err := to.CreateConfigWithContext(ctx, opts, nil); err != nil {
+		return 0, err
+	}
+	return db.copyItems(ctx, to, opts, opts, false)
+}
+
+// CopyItems copies every item in a config, as stored (versions, TTLs and the root key included), to another
+// config in the same layout. The copy isn't frozen, even if the original is. Existing items in the destination are overwritten. Returns the number of items copied.
+func (db DynamoDB) CopyItems(from config.Options, to config.Options) (int, error) {
+	return db.CopyItemsWithContext(context.Background(), from, to)
+}
+
+// CopyItemsWithContext is CopyItems with a context, which can cancel the requests
+func (db DynamoDB) CopyItemsWithContext(ctx context.Context, from config.Options, to config.Options) (int, error) {
+	if from.CfgName == to.CfgName {
+		return 0, errors.New("A configuration can't be copied to itself")
+	}
+	return db.copyItems(ctx, db, from, to, true)
+}
+
+// copyItems copies the items of a config to a config in another (or the same) layout, unfreezing the copy if asked
+func (db DynamoDB) copyItems(ctx context.Context, dest DynamoDB, from config.Options, to config.Options, unfreeze bool) (int, error) {
+	svc, err := db.svc(from)
+	if err != nil {
+		return 0, err
+	}
+	items, err := db.items(ctx, svc, from)
+	if err != nil {
+		return 0, err
+	}
+	destSvc, err := dest.svc(to)
+	if err != nil {
+		return 0, err
+	}
+
+	requests := make([]*dynamodb.WriteRequest, len(items))
+	for i, attributes := range items {
+		delete(attributes, cfgAttributeName)
+		if unfreeze {
+			delete(attributes, "frozen")
+		}
+		if dest.shared() {
+			attributes[cfgAttributeName] = &dynamodb.AttributeValue{S: aws.String(to.CfgName)}
+		}
+		requests[i] = &dynamodb.WriteRequest{PutRequest: &dynamodb.PutRequest{Item: attributes}}
+	}
+	return len(items), batchWrite(ctx, destSvc, dest.tableName(to), requests)
+}
+
+// items returns every item in a config, as stored
+func (db DynamoDB) items(ctx context.Context, svc *dynamodb.DynamoDB, opts config.Options) ([]map[string]*dynamodb.AttributeValue, error) {
+	items := []map[string]*dynamodb.AttributeValue{}
+	if db.shared() {
+		names, values := map[string]*string{}, map[string]*dynamodb.AttributeValue{}
+		params := &dynamodb.QueryInput{
+			TableName:                 db.table(opts),
+			ExpressionAttributeNames:  names,
+			ExpressionAttributeValues: values,
+			KeyConditionExpression:    db.keyCondition(opts, names, values, ""),
+			ConsistentRead:            aws.Bool(opts.ConsistentRead),
+		}
+		err := svc.QueryPagesWithContext(ctx, params, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+			items = append(items, page.Items...)
+			return true
+		})
+		return items, err
+	}
+	err := svc.ScanPagesWithContext(ctx, &dynamodb.ScanInput{TableName: db.table(opts), ConsistentRead: aws.Bool(opts.ConsistentRead)}, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		items = append(items, page.Items...)
+		return true
+	})
+	return items, err
+}
+
+// deleteItems deletes every item in a config from a shared table. DeleteConfigWithContext has already deleted the
+// root key (checking the config isn't frozen), it's deleted again last in case a change to a key put it back. The
+// delete can be run again should it fail part way, though the config is no longer listed.
+func (db DynamoDB) deleteItems(ctx context.Context, svc *dynamodb.DynamoDB, opts config.Options) error {
+	items, err := db.items(ctx, svc, opts)
+	if err != nil {
+		return err
+	}
+	requests := []*dynamodb.WriteRequest{}
+	for _, attributes := range items {
+		key := aws.StringValue(attributes["key"].S)
+		if key != "/" {
+			requests = append(requests, &dynamodb.WriteRequest{DeleteRequest: &dynamodb.DeleteRequest{Key: db.itemKey(opts, key)}})
+		}
+	}
+	if err := batchWrite(ctx, svc, db.Table, requests); err != nil {
+		return err
+	}
+	_, err = svc.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{TableName: db.table(opts), Key: db.itemKey(opts, "/")})
+	return err
+}
+
+// batchWrite writes items in batches, retrying those DynamoDB didn't process (when throttled) with a backoff
+func batchWrite(ctx context.Context, svc *dynamodb.DynamoDB, table string, requests []*dynamodb.WriteRequest) error {
+	for len(requests) > 0 {
+		n := batchSize
+		if len(requests) < n {
+			n = len(requests)
+		}
+		batch := map[string][]*dynamodb.WriteRequest{table: requests[:n]}
+		requests = requests[n:]
+		delay := batchFirstDelay
+		for len(batch[table]) > 0 {
+			resp, err := svc.BatchWriteItemWithContext(ctx, &dynamodb.BatchWriteItemInput{RequestItems: batch})
+			if err != nil {
+				return err
+			}
+			batch = resp.UnprocessedItems
+			if len(batch[table]) > 0 {
+				if err := aws.SleepWithContext(ctx, delay); err != nil {
+					return err
+				}
+				delay *= 2
+				if delay > batchMaxDelay {
+					delay = batchMaxDelay
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package database
+
+import (
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/tmaiaroto/discfg/config"
+	"os"
+	"testing"
+)
+
+func TestLayout(t *testing.T) {
+	opts := config.Options{CfgName: "mycfg"}
+
+	Convey("A table per config should be keyed by the item key", t, func() {
+		db := DynamoDB{}
+		So(db.tableName(opts), ShouldEqual, "mycfg")
+		So(db.itemKey(opts, "a"), ShouldHaveLength, 1)
+		So(*db.itemKey(opts, "a")["key"].S, ShouldEqual, "a")
+
+		names, values := map[string]*string{}, map[string]*dynamodb.AttributeValue{}
+		So(*db.keyCondition(opts, names, values, "#k = :key"), ShouldEqual, "#k = :key")
+		So(names, ShouldBeEmpty)
+
+		_, schema := db.keySchema()
+		So(schema, ShouldHaveLength, 1)
+		So(*schema[0].KeyType, ShouldEqual, "HASH")
+		// Tables being created are listed as configs by their key schema
+		So(hasCfgKeySchema(&dynamodb.TableDescription{KeySchema: schema}), ShouldBeTrue)
+	})
+
+	Convey("A shared table should be keyed by the config name and the item key", t, func() {
+		db := DynamoDB{Table: "shared"}
+		So(db.tableName(opts), ShouldEqual, "shared")
+		So(*db.itemKey(opts, "a")["key"].S, ShouldEqual, "a")
+		So(*db.itemKey(opts, "a")[cfgAttributeName].S, ShouldEqual, "mycfg")
+
+		names, values := map[string]*string{}, map[string]*dynamodb.AttributeValue{}
+		So(*db.keyCondition(opts, names, values, "#k = :key"), ShouldEqual, "#cfg = :cfg AND #k = :key")
+		So(*names["#cfg"], ShouldEqual, cfgAttributeName)
+		So(*values[":cfg"].S, ShouldEqual, "mycfg")
+		So(*db.keyCondition(opts, names, values, ""), ShouldEqual, "#cfg = :cfg")
+
+		_, schema := db.keySchema()
+		So(schema, ShouldHaveLength, 2)
+		So(*schema[0].AttributeName, ShouldEqual, cfgAttributeName)
+		So(*schema[1].KeyType, ShouldEqual, "RANGE")
+		So(hasCfgKeySchema(&dynamodb.TableDescription{KeySchema: schema}), ShouldBeFalse)
+	})
+
+	Convey("The shared table name should come from the environment", t, func() {
+		defer os.Setenv(SharedTableEnvVar, os.Getenv(SharedTableEnvVar))
+		os.Setenv(SharedTableEnvVar, "")
+		So(SharedTableName(), ShouldEqual, DefaultSharedTable)
+		os.Setenv(SharedTableEnvVar, "configs")
+		So(SharedTableName(), ShouldEqual, "configs")
+	})
+
+	Convey("Transactions should be turned off by the option or the environment", t, func() {
+		defer os.Setenv(NoTransactionsEnvVar, os.Getenv(NoTransactionsEnvVar))
+		os.Setenv(NoTransactionsEnvVar, "")
+		So(DynamoDB{}.AtomicVersions(), ShouldBeTrue)
+		So(DynamoDB{NoTransactions: true}.AtomicVersions(), ShouldBeFalse)
+		os.Setenv(NoTransactionsEnvVar, "1")
+		So(DynamoDB{}.AtomicVersions(), ShouldBeFalse)
+	})
+
+	Convey("Incremented numbers should be added exactly", t, func() {
+		So(addNumbers("2", 0.5), ShouldEqual, "2.5")
+		So(addNumbers("0.1", 0.2), ShouldEqual, "0.3")
+		So(addNumbers("-1", 1), ShouldEqual, "0")
+		So(addNumbers("12345678901234567890", 1), ShouldEqual, "12345678901234567891")
+	})
+
+	Convey("Migrating to the same table should be an error", t, func() {
+		_, err := DynamoDB{Table: "shared"}.Migrate(DynamoDB{Table: "shared"}, opts)
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,256 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"sort"
+	"strings"
+)
+
+// Default provisioned throughput for new tables (and updates setting only one of the two)
+const (
+	defaultReadCapacityUnits  = int64(2)
+	defaultWriteCapacityUnits = int64(1)
+)
+
+// tableSettings are the settings a config can be created or updated with, for example:
+//
+//	{"BillingMode": "PAY_PER_REQUEST", "SSEKMSKeyId": "alias/discfg", "PointInTimeRecovery": true,
+//	 "Tags": {"team": "platform"}, "TableClass": "STANDARD_INFREQUENT_ACCESS"}
+//
+// Only settings that were given are set, nil means leave it alone (or the DynamoDB default for new tables).
+type tableSettings struct {
+	// PROVISIONED (the default) or PAY_PER_REQUEST
+	BillingMode *string
+	// Provisioned throughput, only for the PROVISIONED billing mode
+	ReadCapacityUnits  *int64
+	WriteCapacityUnits *int64
+	// Server side encryption with a KMS key, either the AWS managed key (SSE true) or a key id, ARN or alias
+	SSE         *bool
+	SSEKMSKeyID *string
+	// Continuous backups, allowing restores to any point in the last 35 days
+	PointInTimeRecovery *bool
+	// Tags on the table, for cost allocation for example
+	Tags map[string]string
+	// STANDARD (the default) or STANDARD_INFREQUENT_ACCESS
+	TableClass *string
+	// DynamoDB's native TTL, always enabled on new tables (configs created before that can enable it on update)
+	TimeToLive *bool
+}
+
+// settingNames are the names of the settings accepted in the settings map
+var settingNames = []string{
+	"BillingMode", "ReadCapacityUnits", "WriteCapacityUnits", "SSE", "SSEKMSKeyId", "PointInTimeRecovery", "Tags",
+	"TableClass", "TimeToLive",
+}
+
+// parseSettings validates the settings passed to CreateConfig or UpdateConfig
+func parseSettings(settings map[string]interface{}) (tableSettings, error) {
+	s := tableSettings{}
+	for name, val := range settings {
+		var err error
+		switch name {
+		case "BillingMode":
+			s.BillingMode, err = enumSetting(name, val, dynamodb.BillingModeProvisioned, dynamodb.BillingModePayPerRequest)
+		case "ReadCapacityUnits":
+			s.ReadCapacityUnits, err = unitsSetting(name, val)
+		case "WriteCapacityUnits":
+			s.WriteCapacityUnits, err = unitsSetting(name, val)
+		case "SSE":
+			s.SSE, err = boolSetting(name, val)
+		case "SSEKMSKeyId":
+			s.SSEKMSKeyID, err = stringSetting(name, val)
+		case "PointInTimeRecovery":
+			s.PointInTimeRecovery, err = boolSetting(name, val)
+		case "Tags":
+			s.Tags, err = tagsSetting(name, val)
+		case "TableClass":
+			s.TableClass, err = enumSetting(name, val, dynamodb.TableClassStandard, dynamodb.TableClassStandardInfrequentAccess)
+		case "TimeToLive":
+			s.TimeToLive, err = boolSetting(name, val)
+		default:
+			err = errors.New("Unknown setting " + name + ", settings are: " + strings.Join(settingNames, ", "))
+		}
+		if err != nil {
+			return s, err
+		}
+	}
+
+	if s.payPerRequest() && (s.ReadCapacityUnits != nil || s.WriteCapacityUnits != nil) {
+		return s, errors.New("ReadCapacityUnits and WriteCapacityUnits can't be set with the PAY_PER_REQUEST billing mode")
+	}
+	if s.TimeToLive != nil && !*s.TimeToLive {
+		return s, errors.New("TimeToLive can only be enabled, discfg relies on it to delete expired keys")
+	}
+	if s.SSE != nil && !*s.SSE && s.SSEKMSKeyID != nil {
+		return s, errors.New("SSEKMSKeyId can't be set when SSE is false")
+	}
+	return s, nil
+}
+
+// payPerRequest returns whether or not the settings use on-demand billing
+func (s tableSettings) payPerRequest() bool {
+	return s.BillingMode != nil && *s.BillingMode == dynamodb.BillingModePayPerRequest
+}
+
+// throughput returns the provisioned throughput, defaults filling in the units that weren't set. It's nil when
+// there's no throughput to set.
+func (s tableSettings) throughput() *dynamodb.ProvisionedThroughput {
+	if s.payPerRequest() || (s.BillingMode == nil && s.ReadCapacityUnits == nil && s.WriteCapacityUnits == nil) {
+		return nil
+	}
+	ru := defaultReadCapacityUnits
+	if s.ReadCapacityUnits != nil {
+		ru = *s.ReadCapacityUnits
+	}
+	wu := defaultWriteCapacityUnits
+	if s.WriteCapacityUnits != nil {
+		wu = *s.WriteCapacityUnits
+	}
+	return &dynamodb.ProvisionedThroughput{
+		ReadCapacityUnits:  aws.Int64(ru),
+		WriteCapacityUnits: aws.Int64(wu),
+	}
+}
+
+// sse returns the server side encryption specification, nil when encryption wasn't set
+func (s tableSettings) sse() *dynamodb.SSESpecification {
+	if s.SSE == nil && s.SSEKMSKeyID == nil {
+		return nil
+	}
+	if s.SSE != nil && !*s.SSE {
+		return &dynamodb.SSESpecification{Enabled: aws.Bool(false)}
+	}
+	spec := &dynamodb.SSESpecification{Enabled: aws.Bool(true), SSEType: aws.String(dynamodb.SSETypeKms)}
+	if s.SSEKMSKeyID != nil {
+		spec.KMSMasterKeyId = s.SSEKMSKeyID
+	}
+	return spec
+}
+
+// tags returns the tags in the form DynamoDB takes them, sorted by key
+func (s tableSettings) tags() []*dynamodb.Tag {
+	tags := []*dynamodb.Tag{}
+	for k, v := range s.Tags {
+		tags = append(tags, &dynamodb.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
+	sort.Slice(tags, func(i, j int) bool { return *tags[i].Key < *tags[j].Key })
+	return tags
+}
+
+// enablePointInTimeRecovery turns point in time recovery on or off once the table is active
+func enablePointInTimeRecovery(ctx context.Context, svc *dynamodb.DynamoDB, table string, enabled bool) error {
+	if err := waitUntilActive(ctx, svc, table); err != nil {
+		return err
+	}
+	_, err := svc.UpdateContinuousBackupsWithContext(ctx, &dynamodb.UpdateContinuousBackupsInput{
+		TableName: aws.String(table),
+		PointInTimeRecoverySpecification: &dynamodb.PointInTimeRecoverySpecification{
+			PointInTimeRecoveryEnabled: aws.Bool(enabled),
+		},
+	})
+	return err
+}
+
+// waitUntilActive waits for a table to exist and be active (not being created or updated)
+func waitUntilActive(ctx context.Context, svc *dynamodb.DynamoDB, table string) error {
+	return svc.WaitUntilTableExistsWithContext(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(table),
+	})
+}
+
+// describeSettings returns a table's settings in the same form they're given to CreateConfig and UpdateConfig
+func describeSettings(ctx context.Context, svc *dynamodb.DynamoDB, table *dynamodb.TableDescription) map[string]interface{} {
+	settings := map[string]interface{}{
+		"BillingMode": dynamodb.BillingModeProvisioned,
+		"TableClass":  dynamodb.TableClassStandard,
+		"SSE":         false,
+	}
+	if table.BillingModeSummary != nil && table.BillingModeSummary.BillingMode != nil {
+		settings["BillingMode"] = *table.BillingModeSummary.BillingMode
+	}
+	if settings["BillingMode"] == dynamodb.BillingModeProvisioned && table.ProvisionedThroughput != nil {
+		settings["ReadCapacityUnits"] = aws.Int64Value(table.ProvisionedThroughput.ReadCapacityUnits)
+		settings["WriteCapacityUnits"] = aws.Int64Value(table.ProvisionedThroughput.WriteCapacityUnits)
+	}
+	if table.TableClassSummary != nil && table.TableClassSummary.TableClass != nil {
+		settings["TableClass"] = *table.TableClassSummary.TableClass
+	}
+	if table.SSEDescription != nil && aws.StringValue(table.SSEDescription.Status) == dynamodb.SSEStatusEnabled {
+		settings["SSE"] = true
+		if table.SSEDescription.KMSMasterKeyArn != nil {
+			settings["SSEKMSKeyId"] = *table.SSEDescription.KMSMasterKeyArn
+		}
+	}
+
+	// Neither of these are part of the table description. Stores with the DynamoDB API don't all support them,
+	// so they're simply left out on error.
+	backups, err := svc.DescribeContinuousBackupsWithContext(ctx, &dynamodb.DescribeContinuousBackupsInput{TableName: table.TableName})
+	if err == nil && backups.ContinuousBackupsDescription != nil && backups.ContinuousBackupsDescription.PointInTimeRecoveryDescription != nil {
+		status := aws.StringValue(backups.ContinuousBackupsDescription.PointInTimeRecoveryDescription.PointInTimeRecoveryStatus)
+		settings["PointInTimeRecovery"] = status == dynamodb.PointInTimeRecoveryStatusEnabled
+	}
+	tags, err := svc.ListTagsOfResourceWithContext(ctx, &dynamodb.ListTagsOfResourceInput{ResourceArn: table.TableArn})
+	if err == nil {
+		m := map[string]string{}
+		for _, tag := range tags.Tags {
+			m[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+		}
+		settings["Tags"] = m
+	}
+	return settings
+}
+
+func enumSetting(name string, val interface{}, values ...string) (*string, error) {
+	s, ok := val.(string)
+	if ok {
+		for _, v := range values {
+			if strings.ToUpper(s) == v {
+				return aws.String(v), nil
+			}
+		}
+	}
+	return nil, errors.New(name + " must be one of: " + strings.Join(values, ", "))
+}
+
+func unitsSetting(name string, val interface{}) (*int64, error) {
+	f, ok := val.(float64)
+	if !ok || f < 1 || f != float64(int64(f)) {
+		return nil, errors.New(name + " must be a whole number of at least 1")
+	}
+	return aws.Int64(int64(f)), nil
+}
+
+func boolSetting(name string, val interface{}) (*bool, error) {
+	b, ok := val.(bool)
+	if !ok {
+		return nil, errors.New(name + " must be true or false")
+	}
+	return aws.Bool(b), nil
+}
+
+func stringSetting(name string, val interface{}) (*string, error) {
+	s, ok := val.(string)
+	if !ok || s == "" {
+		return nil, errors.New(name + " must be a non-empty string")
+	}
+	return aws.String(s), nil
+}
+
+func tagsSetting(name string, val interface{}) (map[string]string, error) {
+	m, ok := val.(map[string]interface{})
+	if !ok {
+		return nil, errors.New(name + " must be an object of tag names and string values")
+	}
+	tags := map[string]string{}
+	for k, v := range m {
+		s, ok := v.(string)
+		if !ok || k == "" {
+			return nil, errors.New(name + " must be an object of tag names and string values")
+		}
+		tags[k] = s
+	}
+	return tags, nil
+}
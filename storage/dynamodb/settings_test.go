@@ -0,0 +1,74 @@
+package database
+
+import (
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestParseSettings(t *testing.T) {
+	Convey("No settings should leave everything alone", t, func() {
+		s, err := parseSettings(map[string]interface{}{})
+		So(err, ShouldBeNil)
+		So(s.throughput(), ShouldBeNil)
+		So(s.sse(), ShouldBeNil)
+		So(s.tags(), ShouldBeEmpty)
+	})
+
+	Convey("Settings should be parsed", t, func() {
+		s, err := parseSettings(map[string]interface{}{
+			"BillingMode":         "pay_per_request",
+			"SSEKMSKeyId":         "alias/discfg",
+			"PointInTimeRecovery": true,
+			"Tags":                map[string]interface{}{"team": "platform", "env": "prod"},
+			"TableClass":          "STANDARD_INFREQUENT_ACCESS",
+		})
+		So(err, ShouldBeNil)
+		So(*s.BillingMode, ShouldEqual, dynamodb.BillingModePayPerRequest)
+		So(s.throughput(), ShouldBeNil)
+		So(*s.sse().SSEType, ShouldEqual, dynamodb.SSETypeKms)
+		So(*s.sse().KMSMasterKeyId, ShouldEqual, "alias/discfg")
+		So(*s.PointInTimeRecovery, ShouldBeTrue)
+		So(*s.tags()[0].Key, ShouldEqual, "env")
+		So(*s.TableClass, ShouldEqual, dynamodb.TableClassStandardInfrequentAccess)
+	})
+
+	Convey("Provisioned throughput should default the units not given", t, func() {
+		s, err := parseSettings(map[string]interface{}{"ReadCapacityUnits": float64(5)})
+		So(err, ShouldBeNil)
+		So(*s.throughput().ReadCapacityUnits, ShouldEqual, 5)
+		So(*s.throughput().WriteCapacityUnits, ShouldEqual, defaultWriteCapacityUnits)
+
+		s, err = parseSettings(map[string]interface{}{"BillingMode": "PROVISIONED"})
+		So(err, ShouldBeNil)
+		So(*s.throughput().ReadCapacityUnits, ShouldEqual, defaultReadCapacityUnits)
+	})
+
+	Convey("SSE should be able to be turned off", t, func() {
+		s, err := parseSettings(map[string]interface{}{"SSE": false})
+		So(err, ShouldBeNil)
+		So(*s.sse().Enabled, ShouldBeFalse)
+	})
+
+	Convey("Invalid settings should return an error", t, func() {
+		invalid := []map[string]interface{}{
+			{"Unknown": true},
+			{"BillingMode": "FREE"},
+			{"ReadCapacityUnits": float64(0)},
+			{"WriteCapacityUnits": 1.5},
+			{"WriteCapacityUnits": "1"},
+			{"SSE": "yes"},
+			{"SSEKMSKeyId": ""},
+			{"SSE": false, "SSEKMSKeyId": "alias/discfg"},
+			{"Tags": []interface{}{"team"}},
+			{"Tags": map[string]interface{}{"count": float64(1)}},
+			{"TableClass": "GLACIER"},
+			{"TimeToLive": false},
+			{"BillingMode": "PAY_PER_REQUEST", "ReadCapacityUnits": float64(1)},
+		}
+		for _, settings := range invalid {
+			_, err := parseSettings(settings)
+			So(err, ShouldNotBeNil)
+		}
+	})
+}
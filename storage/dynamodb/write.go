@@ -0,0 +1,321 @@
+package database
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/tmaiaroto/discfg/config"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// frozenCondition is the condition on a config's root key "/" that it isn't frozen (see notFrozen)
+const frozenCondition = "attribute_not_exists(#f) OR #f <> :frozen"
+
+// maxWriteAttempts is how many times a change to a key is made, should the item change between reading and writing
+// it or another transaction on the config conflict with it
+const maxWriteAttempts = 10
+
+// itemChange is a change to a key's item; an update when there's an update expression, otherwise a delete
+type itemChange struct {
+	update    string
+	condition string
+	names     map[string]*string
+	values    map[string]*dynamodb.AttributeValue
+}
+
+// NoTransactionsEnvVar is the environment variable that, when set, stops keys being written in transactions (see
+// DynamoDB.NoTransactions)
+const NoTransactionsEnvVar = "DISCFG_DYNAMODB_NO_TRANSACTIONS"
+
+// transactions returns whether or not keys are written in transactions with their config's root key
+func (db DynamoDB) transactions() bool {
+	return !db.NoTransactions && os.Getenv(NoTransactionsEnvVar) == ""
+}
+
+// AtomicVersions returns whether or not changes to keys update the config version in the same write (see write),
+// true unless transactions are turned off
+func (db DynamoDB) AtomicVersions() bool {
+	return db.transactions()
+}
+
+// notFrozen sets the names and values for frozenCondition, returning it
+func notFrozen(names map[string]*string, values map[string]*dynamodb.AttributeValue) string {
+	names["#f"] = aws.String("frozen")
+	values[":frozen"] = &dynamodb.AttributeValue{BOOL: aws.Bool(true)}
+	return frozenCondition
+}
+
+// versionUpdate returns the update to the root key "/" advancing the config version and modified timestamp,
+// conditional on the config not being frozen
+func (db DynamoDB) versionUpdate(opts config.Options) *dynamodb.TransactWriteItem {
+	names := map[string]*string{"#m": aws.String("cfgModified")}
+	values := map[string]*dynamodb.AttributeValue{
+		// modified timestamp (DynamoDB has no date type)
+		":modified": {N: aws.String(strconv.FormatInt(time.Now().UnixNano(), 10))},
+		// version increment
+		":i": {N: aws.String("1")},
+	}
+	condition := notFrozen(names, values)
+	return &dynamodb.TransactWriteItem{Update: &dynamodb.Update{
+		Key:                       db.itemKey(opts, "/"),
+		TableName:                 db.table(opts),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+		UpdateExpression:          aws.String("SET #m = :modified ADD cfgVersion :i"),
+		ConditionExpression:       aws.String(condition),
+	}}
+}
+
+// frozenCheck returns the check on the root key "/" that the config isn't frozen, for changes that don't advance
+// the config version
+func (db DynamoDB) frozenCheck(opts config.Options) *dynamodb.TransactWriteItem {
+	names, values := map[string]*string{}, map[string]*dynamodb.AttributeValue{}
+	condition := notFrozen(names, values)
+	return &dynamodb.TransactWriteItem{ConditionCheck: &dynamodb.ConditionCheck{
+		Key:                       db.itemKey(opts, "/"),
+		TableName:                 db.table(opts),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+		ConditionExpression:       aws.String(condition),
+	}}
+}
+
+// write makes a change to a key's item, returning the item's attributes from before the change. Unless the key is
+// unversioned, the change is made in one transaction with root (the version update or frozen check on the root key),
+// so it can't be made once the config is frozen. Changes to the root key itself can't be in the same transaction as
+// a check on it, so they have the frozen condition themselves.
+//
+// DynamoDB doesn't return old values from transactions, so the item is read first and the change is also conditional
+// on the item's version being the same, retrying should it have changed in between. The change's own condition
+// failing is a ConditionalCheckFailedException, as it would be from UpdateItem or DeleteItem. Still changing after
+// maxWriteAttempts is config.ErrContention.
+//
+// That's a consistent read and a transaction (which costs twice the capacity of a plain write) touching the root
+// key for every change, and every change to a config conflicts on its root key. Without transactions (see
+// DynamoDB.NoTransactions) the change is a plain conditional write instead, see writeItem.
+func (db DynamoDB) write(ctx context.Context, svc *dynamodb.DynamoDB, opts config.Options, root *dynamodb.TransactWriteItem, c itemChange) (map[string]*dynamodb.AttributeValue, error) {
+	if !db.transactions() {
+		return db.writeItem(ctx, svc, opts, c)
+	}
+	names, values := map[string]*string{}, map[string]*dynamodb.AttributeValue{}
+	for k, v := range c.names {
+		names[k] = v
+	}
+	for k, v := range c.values {
+		values[k] = v
+	}
+	conditions := []string{}
+	if c.condition != "" {
+		conditions = append(conditions, "("+c.condition+")")
+	}
+	items := []*dynamodb.TransactWriteItem{root}
+	if opts.Unversioned {
+		items = []*dynamodb.TransactWriteItem{}
+	} else if opts.Key == "/" {
+		items = []*dynamodb.TransactWriteItem{}
+		conditions = append(conditions, "("+notFrozen(names, values)+")")
+	}
+
+	var err error
+	for attempt := 1; attempt <= maxWriteAttempts; attempt++ {
+		var old *dynamodb.GetItemOutput
+		old, err = svc.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+			TableName:      db.table(opts),
+			Key:            db.itemKey(opts, opts.Key),
+			ConsistentRead: aws.Bool(true),
+		})
+		if err != nil {
+			return nil, err
+		}
+		guard := "attribute_not_exists(version)"
+		delete(values, ":oldVersion")
+		if v, ok := old.Item["version"]; ok && v.N != nil {
+			guard = "version = :oldVersion"
+			values[":oldVersion"] = v
+		}
+		item := db.changeItem(opts, c.update, strings.Join(append(conditions, guard), " AND "), names, values)
+
+		_, err = svc.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: append(items, item)})
+		if err == nil {
+			if old.Item == nil {
+				return map[string]*dynamodb.AttributeValue{}, nil
+			}
+			return old.Item, nil
+		}
+		tErr, ok := err.(*dynamodb.TransactionCanceledException)
+		if !ok || len(tErr.CancellationReasons) != len(items)+1 {
+			return nil, err
+		}
+		reasons := tErr.CancellationReasons
+		itemReason := reasons[len(reasons)-1]
+		switch {
+		case len(items) > 0 && aws.StringValue(reasons[0].Code) == "ConditionalCheckFailed":
+			return nil, config.ErrFrozen
+		case aws.StringValue(itemReason.Code) == "ConditionalCheckFailed":
+			if opts.Key == "/" && !opts.Unversioned && itemFromAttributes("/", itemReason.Item).Frozen {
+				return nil, config.ErrFrozen
+			}
+			// Only the item having changed since it was read is tried again
+			if sameVersion(old.Item, itemReason.Item) {
+				return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", err)
+			}
+		case hasReason(reasons, "TransactionConflict"):
+			if err := aws.SleepWithContext(ctx, time.Duration(attempt)*batchFirstDelay); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, err
+		}
+	}
+	return nil, config.ErrContention
+}
+
+// writeItem makes a change to a key's item on its own, returning the item's attributes from before the change. The
+// storage package checks the config isn't frozen beforehand and updates the config version afterwards (see
+// AtomicVersions), so a change can land as the config is frozen. Changes to the root key itself have the frozen
+// condition.
+func (db DynamoDB) writeItem(ctx context.Context, svc *dynamodb.DynamoDB, opts config.Options, c itemChange) (map[string]*dynamodb.AttributeValue, error) {
+	names, values := map[string]*string{}, map[string]*dynamodb.AttributeValue{}
+	for k, v := range c.names {
+		names[k] = v
+	}
+	for k, v := range c.values {
+		values[k] = v
+	}
+	conditions := []string{}
+	if c.condition != "" {
+		conditions = append(conditions, "("+c.condition+")")
+	}
+	if opts.Key == "/" && !opts.Unversioned {
+		conditions = append(conditions, "("+notFrozen(names, values)+")")
+	}
+	var condition *string
+	if len(conditions) > 0 {
+		condition = aws.String(strings.Join(conditions, " AND "))
+	}
+	// DynamoDB doesn't allow empty maps
+	if len(names) == 0 {
+		names = nil
+	}
+	if len(values) == 0 {
+		values = nil
+	}
+
+	var old map[string]*dynamodb.AttributeValue
+	var err error
+	if c.update == "" {
+		var resp *dynamodb.DeleteItemOutput
+		resp, err = svc.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+			Key:                       db.itemKey(opts, opts.Key),
+			TableName:                 db.table(opts),
+			ConditionExpression:       condition,
+			ExpressionAttributeNames:  names,
+			ExpressionAttributeValues: values,
+			ReturnValues:              aws.String(dynamodb.ReturnValueAllOld),
+		})
+		if err == nil {
+			old = resp.Attributes
+		}
+	} else {
+		var resp *dynamodb.UpdateItemOutput
+		resp, err = svc.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+			Key:                       db.itemKey(opts, opts.Key),
+			TableName:                 db.table(opts),
+			UpdateExpression:          aws.String(c.update),
+			ConditionExpression:       condition,
+			ExpressionAttributeNames:  names,
+			ExpressionAttributeValues: values,
+			ReturnValues:              aws.String(dynamodb.ReturnValueAllOld),
+		})
+		if err == nil {
+			old = resp.Attributes
+		}
+	}
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException && opts.Key == "/" && !opts.Unversioned {
+		// Which condition failed isn't returned, so the root key is read to tell
+		root, getErr := svc.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+			TableName:      db.table(opts),
+			Key:            db.itemKey(opts, "/"),
+			ConsistentRead: aws.Bool(true),
+		})
+		if getErr == nil && itemFromAttributes("/", root.Item).Frozen {
+			return nil, config.ErrFrozen
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if old == nil {
+		old = map[string]*dynamodb.AttributeValue{}
+	}
+	return old, nil
+}
+
+// changeItem returns the transaction item for a change to a key's item
+func (db DynamoDB) changeItem(opts config.Options, update string, condition string, names map[string]*string, values map[string]*dynamodb.AttributeValue) *dynamodb.TransactWriteItem {
+	// DynamoDB doesn't allow empty maps
+	if len(names) == 0 {
+		names = nil
+	}
+	if len(values) == 0 {
+		values = nil
+	}
+	if update == "" {
+		return &dynamodb.TransactWriteItem{Delete: &dynamodb.Delete{
+			Key:                                 db.itemKey(opts, opts.Key),
+			TableName:                           db.table(opts),
+			ConditionExpression:                 aws.String(condition),
+			ExpressionAttributeNames:            names,
+			ExpressionAttributeValues:           values,
+			ReturnValuesOnConditionCheckFailure: aws.String(dynamodb.ReturnValuesOnConditionCheckFailureAllOld),
+		}}
+	}
+	return &dynamodb.TransactWriteItem{Update: &dynamodb.Update{
+		Key:                                 db.itemKey(opts, opts.Key),
+		TableName:                           db.table(opts),
+		UpdateExpression:                    aws.String(update),
+		ConditionExpression:                 aws.String(condition),
+		ExpressionAttributeNames:            names,
+		ExpressionAttributeValues:           values,
+		ReturnValuesOnConditionCheckFailure: aws.String(dynamodb.ReturnValuesOnConditionCheckFailureAllOld),
+	}}
+}
+
+// sameVersion returns whether or not two versions of an item's attributes have the same version
+func sameVersion(a map[string]*dynamodb.AttributeValue, b map[string]*dynamodb.AttributeValue) bool {
+	av, aok := a["version"]
+	bv, bok := b["version"]
+	if !aok || !bok {
+		return aok == bok
+	}
+	return aws.StringValue(av.N) == aws.StringValue(bv.N)
+}
+
+// hasReason returns whether or not a transaction was canceled for the reason (a cancellation reason code)
+func hasReason(reasons []*dynamodb.CancellationReason, code string) bool {
+	for _, r := range reasons {
+		if aws.StringValue(r.Code) == code {
+			return true
+		}
+	}
+	return false
+}
+
+// addNumbers adds delta to a DynamoDB number exactly, as DynamoDB does (its numbers are decimal, up to 38 digits)
+func addNumbers(n string, delta float64) string {
+	sum, ok := new(big.Rat).SetString(n)
+	if !ok {
+		sum = new(big.Rat)
+	}
+	d, _ := new(big.Rat).SetString(strconv.FormatFloat(delta, 'f', -1, 64))
+	sum.Add(sum, d)
+	if sum.IsInt() {
+		return sum.Num().String()
+	}
+	return strings.TrimRight(sum.FloatString(38), "0")
+}
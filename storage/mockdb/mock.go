@@ -2,8 +2,13 @@
 package mockdb
 
 import (
+	"context"
 	"errors"
 	"github.com/tmaiaroto/discfg/config"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // MockCfg is just a map of mock records within a mock config.
@@ -26,7 +31,7 @@ var MockCfg = map[string]map[string]config.Item{
 			Version: int64(3),
 		},
 		"json_value": config.Item{
-			Key:     "initial_second",
+			Key:     "json_value",
 			Value:   []byte(`{"json": "string", "num": 4}`),
 			Version: int64(3),
 		},
@@ -61,18 +66,39 @@ func (m MockShipper) Options(opts config.Options) map[string]interface{} {
 	return map[string]interface{}{"example": "option"}
 }
 
-// CreateConfig creates a config
+// CreateConfig creates a config, with a root key, erroring should it exist
 func (m MockShipper) CreateConfig(opts config.Options, settings map[string]interface{}) (interface{}, error) {
 	var err error
+	if _, ok := MockCfg[opts.CfgName]; ok {
+		return "", errors.New("The configuration " + opts.CfgName + " already exists")
+	}
+	MockCfg[opts.CfgName] = map[string]config.Item{"/": {Key: "/"}}
 	return "", err
 }
 
-// DeleteConfig deletes a config
+// DeleteConfig deletes a config, unless it's frozen
 func (m MockShipper) DeleteConfig(opts config.Options) (interface{}, error) {
 	var err error
+	if MockCfg[opts.CfgName]["/"].Frozen && !opts.DeleteFrozen {
+		return "", config.ErrFrozen
+	}
+	delete(MockCfg, opts.CfgName)
 	return "", err
 }
 
+// CopyItemsWithContext copies every item in a config to another, which isn't frozen even if the original is
+func (m MockShipper) CopyItemsWithContext(ctx context.Context, from config.Options, to config.Options) (int, error) {
+	var err error
+	if _, ok := MockCfg[to.CfgName]; !ok {
+		return 0, errors.New("The configuration " + to.CfgName + " doesn't exist")
+	}
+	for k, item := range MockCfg[from.CfgName] {
+		item.Frozen = false
+		MockCfg[to.CfgName][k] = item
+	}
+	return len(MockCfg[from.CfgName]), err
+}
+
 // UpdateConfig updates a config
 func (m MockShipper) UpdateConfig(opts config.Options, settings map[string]interface{}) (interface{}, error) {
 	var err error
@@ -82,35 +108,180 @@ func (m MockShipper) UpdateConfig(opts config.Options, settings map[string]inter
 // ConfigState returns the state of the config
 func (m MockShipper) ConfigState(opts config.Options) (string, error) {
 	var err error
-	return "ACTIVE", err
+	if _, ok := MockCfg[opts.CfgName]; !ok {
+		return config.CfgStateNotFound, err
+	}
+	return config.CfgStateActive, err
+}
+
+// ListConfigs returns the mock configs, sorted by name
+func (m MockShipper) ListConfigs(opts config.Options) ([]config.CfgSummary, error) {
+	var err error
+	cfgs := []config.CfgSummary{}
+	for name, items := range MockCfg {
+		cfgs = append(cfgs, config.CfgSummary{
+			Name:                name,
+			State:               config.CfgStateActive,
+			Version:             items["/"].CfgVersion,
+			ModifiedNanoseconds: items["/"].CfgModifiedNanoseconds,
+			Meta:                config.ParseCfgMeta(items["/"].Value),
+			Frozen:              items["/"].Frozen,
+		})
+	}
+	sort.Slice(cfgs, func(i, j int) bool { return cfgs[i].Name < cfgs[j].Name })
+	return cfgs, err
 }
 
 // Update a Item (record)
 func (m MockShipper) Update(opts config.Options) (config.Item, error) {
 	var err error
-	if val, ok := MockCfg[opts.CfgName][opts.Key]; ok {
-		val.Version++
-	} else {
-		MockCfg[opts.CfgName][opts.Key] = config.Item{
-			Key:     opts.Key,
-			Value:   opts.Value,
-			Version: int64(1),
+	if frozen(opts) {
+		return config.Item{Key: opts.Key}, config.ErrFrozen
+	}
+	prev, ok := MockCfg[opts.CfgName][opts.Key]
+	if (opts.ConditionalVersion > 0 && prev.Version != opts.ConditionalVersion) || (opts.ConditionalUnset && prev.Version > 0) {
+		return config.Item{Key: opts.Key}, errors.New("The conditional request failed")
+	}
+	if opts.ConditionalValue != "" || opts.ConditionalNotExists {
+		valueMatches := opts.ConditionalValue != "" && ok && mockValueEquals(prev, opts.ConditionalValue)
+		notExists := opts.ConditionalNotExists && (!ok || isExpired(prev))
+		if !valueMatches && !notExists {
+			return config.Item{Key: opts.Key}, errors.New("The conditional request failed")
 		}
 	}
-	return MockCfg[opts.CfgName][opts.Key], err
+
+	item := config.Item{
+		Key:         opts.Key,
+		Value:       opts.Value,
+		Version:     prev.Version + 1,
+		Encrypted:   opts.Encrypt,
+		Sensitive:   prev.Sensitive,
+		Type:        opts.ValueType,
+		Modified:    time.Now().UnixNano(),
+		ModifiedBy:  opts.Author,
+		Description: prev.Description,
+		Tags:        prev.Tags,
+		// Like DynamoDB, updating the root key leaves the config version alone
+		CfgVersion:             prev.CfgVersion,
+		CfgModifiedNanoseconds: prev.CfgModifiedNanoseconds,
+		Frozen:                 prev.Frozen,
+	}
+	if opts.Sensitive != nil {
+		item.Sensitive = *opts.Sensitive
+	}
+	if opts.Description != "" {
+		item.Description = opts.Description
+	}
+	if len(opts.Tags) > 0 {
+		item.Tags = opts.Tags
+	}
+	if opts.TTL > 0 {
+		item.TTL = opts.TTL
+		item.Expiration = time.Now().Add(time.Duration(opts.TTL) * time.Second)
+	}
+	MockCfg[opts.CfgName][opts.Key] = item
+	versionChanged(opts)
+	return item, err
 }
 
-// Get a Item (record)
+// Get a Item (record), expired items are not returned
 func (m MockShipper) Get(opts config.Options) (config.Item, error) {
 	var err error
-	return MockCfg[opts.CfgName][opts.Key], err
+	item := MockCfg[opts.CfgName][opts.Key]
+	if isExpired(item) {
+		return config.Item{Key: opts.Key}, err
+	}
+	return item, err
+}
+
+// List Items (records) with keys beginning with a prefix
+func (m MockShipper) List(opts config.Options) ([]config.Item, error) {
+	var err error
+	keys := []string{}
+	for k := range MockCfg[opts.CfgName] {
+		if strings.HasPrefix(k, opts.Key) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	items := []config.Item{}
+	for _, k := range keys {
+		items = append(items, MockCfg[opts.CfgName][k])
+	}
+	return items, err
 }
 
 // Delete a Item (record)
 func (m MockShipper) Delete(opts config.Options) (config.Item, error) {
 	var err error
-	defer delete(MockCfg[opts.CfgName], opts.Key)
-	return MockCfg[opts.CfgName][opts.Key], err
+	if frozen(opts) {
+		return config.Item{Key: opts.Key}, config.ErrFrozen
+	}
+	item := MockCfg[opts.CfgName][opts.Key]
+	if opts.ConditionalValue != "" && !mockValueEquals(item, opts.ConditionalValue) {
+		return config.Item{Key: opts.Key}, errors.New("The conditional request failed")
+	}
+	if opts.ConditionalExpired && !isExpired(item) {
+		return config.Item{Key: opts.Key}, errors.New("The conditional request failed")
+	}
+	delete(MockCfg[opts.CfgName], opts.Key)
+	versionChanged(opts)
+	return item, err
+}
+
+// Touch a Item (record), resetting its TTL
+func (m MockShipper) Touch(opts config.Options) (config.Item, error) {
+	if frozen(opts) {
+		return config.Item{Key: opts.Key}, config.ErrFrozen
+	}
+	item, ok := MockCfg[opts.CfgName][opts.Key]
+	if !ok || isExpired(item) {
+		return config.Item{Key: opts.Key}, errors.New("The conditional request failed")
+	}
+	if opts.ConditionalVersion > 0 && item.Version != opts.ConditionalVersion {
+		return config.Item{Key: opts.Key}, errors.New("The conditional request failed")
+	}
+	if opts.ConditionalValue != "" && !mockValueEquals(item, opts.ConditionalValue) {
+		return config.Item{Key: opts.Key}, errors.New("The conditional request failed")
+	}
+	item.TTL = opts.TTL
+	item.Expiration = time.Time{}
+	if opts.TTL > 0 {
+		item.Expiration = time.Now().Add(time.Duration(opts.TTL) * time.Second)
+	}
+	MockCfg[opts.CfgName][opts.Key] = item
+	return item, nil
+}
+
+// Increment a Item's (record) numeric value
+func (m MockShipper) Increment(opts config.Options, delta float64) (config.Item, error) {
+	if frozen(opts) {
+		return config.Item{Key: opts.Key}, config.ErrFrozen
+	}
+	item, ok := MockCfg[opts.CfgName][opts.Key]
+	if ok && isExpired(item) {
+		return config.Item{Key: opts.Key}, errors.New("The conditional request failed")
+	}
+	if opts.ConditionalVersion > 0 && item.Version != opts.ConditionalVersion {
+		return config.Item{Key: opts.Key}, errors.New("The conditional request failed")
+	}
+	n := float64(0)
+	if ok {
+		b, _ := item.Value.([]byte)
+		var err error
+		if n, err = strconv.ParseFloat(string(b), 64); err != nil {
+			return config.Item{Key: opts.Key}, errors.New("An operand in the update expression has an incorrect data type")
+		}
+	}
+	item.Key = opts.Key
+	item.Value = []byte(strconv.FormatFloat(n+delta, 'f', -1, 64))
+	item.Type = config.ValueTypeNumber
+	item.Version++
+	item.Modified = time.Now().UnixNano()
+	item.ModifiedBy = opts.Author
+	MockCfg[opts.CfgName][opts.Key] = item
+	versionChanged(opts)
+	return item, nil
 }
 
 // UpdateConfigVersion updates the incremental counter/state of a configuration and should be called on each change
@@ -118,6 +289,9 @@ func (m MockShipper) UpdateConfigVersion(opts config.Options) error {
 	var err error
 	if opts.CfgName != "" {
 		n := MockCfg[opts.CfgName]["/"]
+		if n.Frozen {
+			return config.ErrFrozen
+		}
 		n.CfgVersion++
 		MockCfg[opts.CfgName]["/"] = n
 	} else {
@@ -125,3 +299,44 @@ func (m MockShipper) UpdateConfigVersion(opts config.Options) error {
 	}
 	return err
 }
+
+// FreezeWithContext sets the frozen flag on a config's root key
+func (m MockShipper) FreezeWithContext(ctx context.Context, opts config.Options, frozen bool) error {
+	root, ok := MockCfg[opts.CfgName]["/"]
+	if !ok {
+		return errors.New("The configuration " + opts.CfgName + " doesn't exist")
+	}
+	root.Frozen = frozen
+	MockCfg[opts.CfgName]["/"] = root
+	return nil
+}
+
+// AtomicVersions returns true, like DynamoDB the mock checks whether the config is frozen and updates its version
+// when a key changes
+func (m MockShipper) AtomicVersions() bool {
+	return true
+}
+
+// frozen returns whether or not a change to a key isn't allowed because its config is frozen
+func frozen(opts config.Options) bool {
+	return !opts.Unversioned && MockCfg[opts.CfgName]["/"].Frozen
+}
+
+// versionChanged updates the config version after a key changed, unless it's the root key or unversioned
+func versionChanged(opts config.Options) {
+	if opts.Key == "/" || opts.Unversioned {
+		return
+	}
+	root := MockCfg[opts.CfgName]["/"]
+	root.CfgVersion++
+	MockCfg[opts.CfgName]["/"] = root
+}
+
+func isExpired(item config.Item) bool {
+	return item.TTL > 0 && item.Expiration.Before(time.Now())
+}
+
+func mockValueEquals(item config.Item, value string) bool {
+	b, ok := item.Value.([]byte)
+	return ok && string(b) == value
+}
@@ -2,6 +2,7 @@
 package storage
 
 import (
+	"context"
 	"errors"
 	"github.com/tmaiaroto/discfg/config"
 	ddb "github.com/tmaiaroto/discfg/storage/dynamodb"
@@ -14,9 +15,13 @@ type Shipper interface {
 	DeleteConfig(config.Options) (interface{}, error)
 	UpdateConfig(config.Options, map[string]interface{}) (interface{}, error)
 	ConfigState(config.Options) (string, error)
+	ListConfigs(config.Options) ([]config.CfgSummary, error)
 	Update(config.Options) (config.Item, error)
 	Get(config.Options) (config.Item, error)
+	List(config.Options) ([]config.Item, error)
 	Delete(config.Options) (config.Item, error)
+	Touch(config.Options) (config.Item, error)
+	Increment(config.Options, float64) (config.Item, error)
 	UpdateConfigVersion(config.Options) error
 	Name(config.Options) string
 	Options(config.Options) map[string]interface{}
@@ -35,7 +40,8 @@ const (
 
 // A map of all Shipper interfaces available for use (with some defaults).
 var shippers = map[string]Shipper{
-	"dynamodb": ddb.DynamoDB{},
+	"dynamodb":        ddb.DynamoDB{},
+	"dynamodb-shared": ddb.DynamoDB{Table: ddb.SharedTableName()},
 }
 
 // RegisterShipper allows anyone importing discfg into their own project to register new shippers or overwrite the defaults.
@@ -64,80 +70,305 @@ func Options(opts config.Options) map[string]interface{} {
 	return map[string]interface{}{}
 }
 
+// Identifier is implemented by Shippers that can tell who they work with storage as
+type Identifier interface {
+	Identity(config.Options) (string, error)
+}
+
+// Identity returns who the shipper works with storage as (for DynamoDB, the ARN of the AWS identity the credentials
+// resolve to). It's empty for shippers that don't implement Identifier.
+func Identity(opts config.Options) (string, error) {
+	if s, ok := shippers[opts.StorageInterfaceName]; ok {
+		if i, ok := s.(Identifier); ok {
+			return i.Identity(opts)
+		}
+		return "", nil
+	}
+	return "", errors.New(errMsgInvalidShipper)
+}
+
+// ItemCopier is implemented by Shippers that can copy every item in a config to another config, as stored
+type ItemCopier interface {
+	CopyItemsWithContext(ctx context.Context, from config.Options, to config.Options) (int, error)
+}
+
+// CopyItems copies every item in a configuration (the root key included) to another configuration using the same
+// storage engine, returning the number of items copied
+func CopyItems(from config.Options, to config.Options) (int, error) {
+	s, ok := shippers[from.StorageInterfaceName]
+	if !ok || from.StorageInterfaceName != to.StorageInterfaceName {
+		return 0, errors.New(errMsgInvalidShipper)
+	}
+	if c, ok := s.(ItemCopier); ok {
+		return c.CopyItemsWithContext(from.ContextOrBackground(), from, to)
+	}
+	return 0, errors.New(s.Name(from) + " can't copy configurations")
+}
+
+// Freezer is implemented by Shippers that can freeze a config. A frozen config's version can't be updated and its
+// keys can't be changed (see AtomicVersioner).
+type Freezer interface {
+	FreezeWithContext(ctx context.Context, opts config.Options, frozen bool) error
+}
+
+// AtomicVersioner is implemented by Shippers whose changes to keys are conditional on the config not being frozen,
+// updating the config version in the same write (unless the key is unversioned or it's the root key "/"). For other
+// Shippers, the config is checked before a key is changed and its version updated after.
+type AtomicVersioner interface {
+	AtomicVersions() bool
+}
+
+// atomicVersions returns whether or not a Shipper checks whether the config is frozen and updates its version itself
+func atomicVersions(s Shipper) bool {
+	a, ok := s.(AtomicVersioner)
+	return ok && a.AtomicVersions()
+}
+
+// checkFrozen returns config.ErrFrozen for changes to a frozen config, for Shippers that don't check it themselves
+func checkFrozen(ctx context.Context, s Shipper, opts config.Options) error {
+	if opts.Unversioned || atomicVersions(s) {
+		return nil
+	}
+	rootOpts := opts
+	rootOpts.Key = "/"
+	if root, err := WithContext(s).GetWithContext(ctx, rootOpts); err == nil && root.Frozen {
+		return config.ErrFrozen
+	}
+	return nil
+}
+
+// versionChanged updates the config version after a key changed, for Shippers that don't update it themselves
+func versionChanged(ctx context.Context, s Shipper, opts config.Options, item config.Item, err error) (config.Item, error) {
+	if err != nil || opts.Key == "/" || opts.Unversioned || atomicVersions(s) {
+		return item, err
+	}
+	return item, WithContext(s).UpdateConfigVersionWithContext(ctx, opts)
+}
+
+// Freeze freezes (or unfreezes) a configuration. Changes to a frozen configuration return config.ErrFrozen.
+func Freeze(opts config.Options, frozen bool) error {
+	s, ok := shippers[opts.StorageInterfaceName]
+	if !ok {
+		return errors.New(errMsgInvalidShipper)
+	}
+	if f, ok := s.(Freezer); ok {
+		return f.FreezeWithContext(opts.ContextOrBackground(), opts, frozen)
+	}
+	return errors.New(s.Name(opts) + " can't freeze configurations")
+}
+
+// Wrapper is implemented by Shippers wrapping another (a cache for example), so what only the wrapped Shipper can
+// do (migrating) still works through them
+type Wrapper interface {
+	Unwrap() Shipper
+}
+
+// unwrap returns the Shipper wrapped by any Wrappers
+func unwrap(s Shipper) Shipper {
+	for {
+		w, ok := s.(Wrapper)
+		if !ok {
+			return s
+		}
+		s = w.Unwrap()
+	}
+}
+
+// Migrate copies a configuration from its storage engine to another, returning the number of items copied. Only
+// DynamoDB layouts (a table per config and a shared table) can be migrated between, cached or not.
+func Migrate(opts config.Options, to string) (int, error) {
+	from, fromOk := unwrap(shippers[opts.StorageInterfaceName]).(ddb.DynamoDB)
+	dest, toOk := unwrap(shippers[to]).(ddb.DynamoDB)
+	if !fromOk || !toOk {
+		return 0, errors.New("Configurations can only be migrated between DynamoDB storage engines")
+	}
+	return from.MigrateWithContext(opts.ContextOrBackground(), dest, opts)
+}
+
 // CreateConfig creates a new configuration returning success true/false along with any response and error.
 func CreateConfig(opts config.Options, settings map[string]interface{}) (interface{}, error) {
+	return CreateConfigWithContext(opts.ContextOrBackground(), opts, settings)
+}
+
+// CreateConfigWithContext is CreateConfig with a context for the storage calls
+func CreateConfigWithContext(ctx context.Context, opts config.Options, settings map[string]interface{}) (interface{}, error) {
 	if s, ok := shippers[opts.StorageInterfaceName]; ok {
-		return s.CreateConfig(opts, settings)
+		return WithContext(s).CreateConfigWithContext(ctx, opts, settings)
 	}
 	return nil, errors.New(errMsgInvalidShipper)
 }
 
 // DeleteConfig deletes an existing configuration
 func DeleteConfig(opts config.Options) (interface{}, error) {
+	return DeleteConfigWithContext(opts.ContextOrBackground(), opts)
+}
+
+// DeleteConfigWithContext is DeleteConfig with a context for the storage calls
+func DeleteConfigWithContext(ctx context.Context, opts config.Options) (interface{}, error) {
 	if s, ok := shippers[opts.StorageInterfaceName]; ok {
-		return s.DeleteConfig(opts)
+		// Frozen configs can't be deleted either, unless asked to
+		if !opts.DeleteFrozen {
+			if err := checkFrozen(ctx, s, opts); err != nil {
+				return nil, err
+			}
+		}
+		return WithContext(s).DeleteConfigWithContext(ctx, opts)
 	}
 	return nil, errors.New(errMsgInvalidShipper)
 }
 
 // UpdateConfig updates the options/settings for a configuration (may not be implementd by each interface)
 func UpdateConfig(opts config.Options, settings map[string]interface{}) (interface{}, error) {
+	return UpdateConfigWithContext(opts.ContextOrBackground(), opts, settings)
+}
+
+// UpdateConfigWithContext is UpdateConfig with a context for the storage calls
+func UpdateConfigWithContext(ctx context.Context, opts config.Options, settings map[string]interface{}) (interface{}, error) {
 	if s, ok := shippers[opts.StorageInterfaceName]; ok {
-		return s.UpdateConfig(opts, settings)
+		return WithContext(s).UpdateConfigWithContext(ctx, opts, settings)
 	}
 	return nil, errors.New(errMsgInvalidShipper)
 }
 
+// ListConfigs returns the configurations in storage (for DynamoDB, in the region). The config name in the options
+// is ignored.
+func ListConfigs(opts config.Options) ([]config.CfgSummary, error) {
+	return ListConfigsWithContext(opts.ContextOrBackground(), opts)
+}
+
+// ListConfigsWithContext is ListConfigs with a context for the storage calls
+func ListConfigsWithContext(ctx context.Context, opts config.Options) ([]config.CfgSummary, error) {
+	if s, ok := shippers[opts.StorageInterfaceName]; ok {
+		return WithContext(s).ListConfigsWithContext(ctx, opts)
+	}
+	return []config.CfgSummary{}, errors.New(errMsgInvalidShipper)
+}
+
 // ConfigState returns the config state (just a simple string message, could be "ACTIVE" for example)
 func ConfigState(opts config.Options) (string, error) {
+	return ConfigStateWithContext(opts.ContextOrBackground(), opts)
+}
+
+// ConfigStateWithContext is ConfigState with a context for the storage calls
+func ConfigStateWithContext(ctx context.Context, opts config.Options) (string, error) {
 	// TODO: May get more elaborate and have codes for this too, but will probably always have a string message
 	if s, ok := shippers[opts.StorageInterfaceName]; ok {
-		return s.ConfigState(opts)
+		return WithContext(s).ConfigStateWithContext(ctx, opts)
 	}
 	return "", errors.New(errMsgInvalidShipper)
 }
 
 // Update a key value in the configuration
 func Update(opts config.Options) (config.Item, error) {
+	return UpdateWithContext(opts.ContextOrBackground(), opts)
+}
+
+// UpdateWithContext is Update with a context for the storage calls
+func UpdateWithContext(ctx context.Context, opts config.Options) (config.Item, error) {
 	var item config.Item
 	if s, ok := shippers[opts.StorageInterfaceName]; ok {
-		err := UpdateConfigVersion(opts)
-		if err != nil {
+		// The root key holds the config's metadata, changing it doesn't change the config version (see versionChanged)
+		if err := checkFrozen(ctx, s, opts); err != nil {
 			return item, err
 		}
-		return s.Update(opts)
+		item, err := WithContext(s).UpdateWithContext(ctx, opts)
+		return versionChanged(ctx, s, opts, item, err)
 	}
 	return item, errors.New(errMsgInvalidShipper)
 }
 
 // Get a key value in the configuration
 func Get(opts config.Options) (config.Item, error) {
+	return GetWithContext(opts.ContextOrBackground(), opts)
+}
+
+// GetWithContext is Get with a context for the storage calls
+func GetWithContext(ctx context.Context, opts config.Options) (config.Item, error) {
 	var item config.Item
 	if s, ok := shippers[opts.StorageInterfaceName]; ok {
-		return s.Get(opts)
+		return WithContext(s).GetWithContext(ctx, opts)
 	}
 	return item, errors.New(errMsgInvalidShipper)
 }
 
+// List key values in the configuration that begin with a prefix (opts.Key)
+func List(opts config.Options) ([]config.Item, error) {
+	return ListWithContext(opts.ContextOrBackground(), opts)
+}
+
+// ListWithContext is List with a context for the storage calls
+func ListWithContext(ctx context.Context, opts config.Options) ([]config.Item, error) {
+	if s, ok := shippers[opts.StorageInterfaceName]; ok {
+		return WithContext(s).ListWithContext(ctx, opts)
+	}
+	return []config.Item{}, errors.New(errMsgInvalidShipper)
+}
+
 // Delete a key value in the configuration
 func Delete(opts config.Options) (config.Item, error) {
+	return DeleteWithContext(opts.ContextOrBackground(), opts)
+}
+
+// DeleteWithContext is Delete with a context for the storage calls
+func DeleteWithContext(ctx context.Context, opts config.Options) (config.Item, error) {
 	var item config.Item
 	if s, ok := shippers[opts.StorageInterfaceName]; ok {
-		err := UpdateConfigVersion(opts)
-		if err != nil {
+		if err := checkFrozen(ctx, s, opts); err != nil {
 			return item, err
 		}
-		return s.Delete(opts)
+		item, err := WithContext(s).DeleteWithContext(ctx, opts)
+		return versionChanged(ctx, s, opts, item, err)
+	}
+	return item, errors.New(errMsgInvalidShipper)
+}
+
+// Touch resets a key's TTL (opts.TTL) without changing its value or version
+func Touch(opts config.Options) (config.Item, error) {
+	return TouchWithContext(opts.ContextOrBackground(), opts)
+}
+
+// TouchWithContext is Touch with a context for the storage calls
+func TouchWithContext(ctx context.Context, opts config.Options) (config.Item, error) {
+	var item config.Item
+	if s, ok := shippers[opts.StorageInterfaceName]; ok {
+		// Note the config version isn't updated either. Only the expiration changed, not the config.
+		if err := checkFrozen(ctx, s, opts); err != nil {
+			return item, err
+		}
+		return WithContext(s).TouchWithContext(ctx, opts)
+	}
+	return item, errors.New(errMsgInvalidShipper)
+}
+
+// Increment atomically adds delta to a key's numeric value, returning the item with its new value
+func Increment(opts config.Options, delta float64) (config.Item, error) {
+	return IncrementWithContext(opts.ContextOrBackground(), opts, delta)
+}
+
+// IncrementWithContext is Increment with a context for the storage calls
+func IncrementWithContext(ctx context.Context, opts config.Options, delta float64) (config.Item, error) {
+	var item config.Item
+	if s, ok := shippers[opts.StorageInterfaceName]; ok {
+		if err := checkFrozen(ctx, s, opts); err != nil {
+			return item, err
+		}
+		item, err := WithContext(s).IncrementWithContext(ctx, opts, delta)
+		return versionChanged(ctx, s, opts, item, err)
 	}
 	return item, errors.New(errMsgInvalidShipper)
 }
 
 // UpdateConfigVersion updates the global discfg config version and modified timestamp (on the root key "/")
 func UpdateConfigVersion(opts config.Options) error {
+	return UpdateConfigVersionWithContext(opts.ContextOrBackground(), opts)
+}
+
+// UpdateConfigVersionWithContext is UpdateConfigVersion with a context for the storage calls
+func UpdateConfigVersionWithContext(ctx context.Context, opts config.Options) error {
 	// Technically, this modified timestamp won't be accurate. The config would have changed already by this point.
 	// TODO: Perhaps pass a timestamp to this function to get a little closer
 	if s, ok := shippers[opts.StorageInterfaceName]; ok {
-		return s.UpdateConfigVersion(opts)
+		return WithContext(s).UpdateConfigVersionWithContext(ctx, opts)
 	}
 	return errors.New(errMsgInvalidShipper)
 }
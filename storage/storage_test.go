@@ -101,6 +101,27 @@ func TestGet(t *testing.T) {
 	})
 }
 
+func TestList(t *testing.T) {
+	Convey("A Shipper should list the items with keys beginning with a prefix", t, func() {
+		RegisterShipper("mock", mockdb.MockShipper{})
+		opts := config.Options{
+			StorageInterfaceName: "mock",
+			CfgName:              "mockcfg",
+			Key:                  "json",
+		}
+		items, err := List(opts)
+
+		So(len(items), ShouldEqual, 1)
+		So(items[0].Key, ShouldEqual, "json_value")
+		So(err, ShouldBeNil)
+	})
+
+	Convey("A valid Shipper must be used", t, func() {
+		_, err := List(config.Options{StorageInterfaceName: ""})
+		So(err.Error(), ShouldEqual, errMsgInvalidShipper)
+	})
+}
+
 func TestDelete(t *testing.T) {
 	Convey("A Shipper should delete a key value and return the deleted item", t, func() {
 		RegisterShipper("mock", mockdb.MockShipper{})
@@ -139,3 +160,31 @@ func TestUpdateConfigVersion(t *testing.T) {
 		So(err.Error(), ShouldEqual, errMsgInvalidShipper)
 	})
 }
+
+func TestIdentity(t *testing.T) {
+	Convey("A Shipper that can't tell its identity should return an empty one", t, func() {
+		RegisterShipper("mock", mockdb.MockShipper{})
+		identity, err := Identity(config.Options{StorageInterfaceName: "mock"})
+		So(err, ShouldBeNil)
+		So(identity, ShouldEqual, "")
+	})
+
+	Convey("A valid Shipper must be used", t, func() {
+		_, err := Identity(config.Options{StorageInterfaceName: ""})
+		So(err.Error(), ShouldEqual, errMsgInvalidShipper)
+	})
+}
+
+func TestListConfigs(t *testing.T) {
+	Convey("A Shipper should list configs", t, func() {
+		RegisterShipper("mock", mockdb.MockShipper{})
+		cfgs, err := ListConfigs(config.Options{StorageInterfaceName: "mock"})
+		So(err, ShouldBeNil)
+		So(cfgs[0].Name, ShouldEqual, "mockcfg")
+	})
+
+	Convey("A valid Shipper must be used", t, func() {
+		_, err := ListConfigs(config.Options{StorageInterfaceName: ""})
+		So(err.Error(), ShouldEqual, errMsgInvalidShipper)
+	})
+}